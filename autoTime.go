@@ -0,0 +1,146 @@
+package grm
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// FuncNow 获取当前时间的函数,用于自动填充创建时间,更新时间和软删除时间,默认time.Now,测试时可以替换为固定的时间
+// FuncNow The function to get the current time, used to auto-fill create time, update time and soft delete time,
+// the default is time.Now, which can be replaced with a fixed time during testing
+var FuncNow func() time.Time = time.Now
+
+// 自动填充时间的精度
+// Precision of auto-filled time
+const (
+	autoTimeSecond = "second"
+	autoTimeMilli  = "milli"
+	autoTimeNano   = "nano"
+)
+
+// autoTimeNow 使用FuncNow获取当前时间,根据DBConfig.AutoTimeUTC转换为UTC或者本地时间
+// autoTimeNow Use FuncNow to get the current time, and convert it to UTC or local time according to DBConfig.AutoTimeUTC
+func autoTimeNow(config *DBConfig) time.Time {
+	now := FuncNow()
+	if config != nil && config.AutoTimeUTC {
+		return now.UTC()
+	}
+	return now.Local()
+}
+
+// getGrmTagOption 获取grm选项的参数,例如 grm:"autoCreateTime:milli" 返回 milli,没有参数返回空字符串
+// getGrmTagOption Get the parameter of the grm option, for example grm:"autoCreateTime:milli" returns milli, and returns an empty string if there is no parameter
+func getGrmTagOption(field *reflect.StructField, option string) string {
+	for _, tagOption := range strings.Split(field.Tag.Get(tagGrmName), ",") {
+		tagOption = strings.ToLower(strings.TrimSpace(tagOption))
+		i := strings.Index(tagOption, ":")
+		if i >= 0 && tagOption[:i] == option {
+			return strings.TrimSpace(tagOption[i+1:])
+		}
+	}
+	return ""
+}
+
+// autoTimeValue 根据字段的类型和精度获取自动填充的值
+// 时间类型截断到精度,默认不截断.整数类型是Unix时间戳,默认是秒
+// autoTimeValue Get the auto-filled value according to the type and precision of the field
+// Time types are truncated to the precision, not truncated by default. Integer types are Unix timestamps, the default is seconds
+func autoTimeValue(field *reflect.StructField, option string, now time.Time) (reflect.Value, error) {
+	precision := getGrmTagOption(field, option)
+	switch precision {
+	case "", autoTimeSecond, autoTimeMilli, autoTimeNano:
+	default:
+		return reflect.Value{}, errors.New("autoTimeValue-->字段" + field.Name + "不支持的时间精度" + precision + ",支持second,milli,nano")
+	}
+
+	fieldType := field.Type
+	if isIntKind(fieldType.Kind()) {
+		var timestamp int64
+		switch precision {
+		case autoTimeMilli:
+			timestamp = now.UnixNano() / int64(time.Millisecond)
+		case autoTimeNano:
+			timestamp = now.UnixNano()
+		default:
+			timestamp = now.Unix()
+		}
+		return reflect.ValueOf(timestamp).Convert(fieldType), nil
+	}
+
+	switch precision {
+	case autoTimeSecond:
+		now = now.Truncate(time.Second)
+	case autoTimeMilli:
+		now = now.Truncate(time.Millisecond)
+	}
+	switch fieldType {
+	case timeType:
+		return reflect.ValueOf(now), nil
+	case timePtrType:
+		return reflect.ValueOf(&now), nil
+	case nullTimeType:
+		return reflect.ValueOf(sql.NullTime{Time: now, Valid: true}), nil
+	}
+	return reflect.Value{}, errors.New("autoTimeValue-->自动填充时间的字段" + field.Name + "的类型" + fieldType.String() + "不支持,支持时间和整数类型")
+}
+
+// setStructAutoTime 设置struct自动填充的时间.保存时设置值为零值的创建时间和更新时间,更新时设置更新时间
+// setStructAutoTime Set the auto-filled time of the struct. When inserting, set the create time and update time whose value is zero,
+// and set the update time when updating
+func setStructAutoTime(entity IEntityStruct, now time.Time, insert bool) error {
+	typeOf, err := checkEntityKind(entity)
+	if err != nil {
+		return err
+	}
+	valueOf := reflect.ValueOf(entity).Elem()
+	options := []string{tagAutoUpdateTime}
+	if insert {
+		options = append(options, tagAutoCreateTime)
+	}
+	for _, option := range options {
+		field, has, err := getGrmTagField(&typeOf, option)
+		if err != nil {
+			return err
+		}
+		if !has {
+			continue
+		}
+		fieldValue := valueOf.FieldByName(field.Name)
+		//保存时不覆盖已经赋值的时间,例如导入历史数据
+		//Do not overwrite the assigned time when inserting, such as importing historical data
+		if insert && !fieldValue.IsZero() {
+			continue
+		}
+		value, err := autoTimeValue(&field, option, now)
+		if err != nil {
+			return err
+		}
+		fieldValue.Set(value)
+	}
+	return nil
+}
+
+// setMapAutoTime 设置IEntityMap自动填充的时间,需要实现IEntityMapAutoTime接口.保存时设置没有Set的创建时间和更新时间,更新时设置更新时间
+// setMapAutoTime Set the auto-filled time of IEntityMap, which needs to implement the IEntityMapAutoTime interface.
+// When inserting, set the create time and update time that are not Set, and set the update time when updating
+func setMapAutoTime(entity IEntityMap, now time.Time, insert bool) {
+	autoTime, ok := entity.(IEntityMapAutoTime)
+	if !ok {
+		return
+	}
+	createTimeColumn, updateTimeColumn := autoTime.AutoTimeColumns()
+	dbFieldMap := entity.FieldMap()
+	if insert && createTimeColumn != "" {
+		if _, has := dbFieldMap[createTimeColumn]; !has {
+			entity.Set(createTimeColumn, now)
+		}
+	}
+	if updateTimeColumn != "" {
+		if _, has := dbFieldMap[updateTimeColumn]; !has || !insert {
+			entity.Set(updateTimeColumn, now)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package grm
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// autoTimeDemo 自动填充时间测试的实体类
+// autoTimeDemo The entity of the auto time tests
+type autoTimeDemo struct {
+	EntityStruct
+	ID         int        `column:"id"`
+	CreateTime time.Time  `column:"create_time" grm:"autoCreateTime"`
+	UpdateTime *time.Time `column:"update_time" grm:"autoUpdateTime:second"`
+}
+
+func (entity *autoTimeDemo) TableName() string {
+	return "t_auto_time"
+}
+
+// autoStampDemo 使用时间戳和sql.NullTime自动填充时间的实体类
+// autoStampDemo The entity that auto-fills time with a timestamp and sql.NullTime
+type autoStampDemo struct {
+	EntityStruct
+	ID       int          `column:"id"`
+	CreateAt int64        `column:"create_at" grm:"autoCreateTime:milli"`
+	UpdateAt sql.NullTime `column:"update_at" grm:"autoUpdateTime:milli"`
+}
+
+func (entity *autoStampDemo) TableName() string {
+	return "t_auto_stamp"
+}
+
+func TestAutoTimeValue(t *testing.T) {
+	now := time.Date(2026, 10, 1, 8, 30, 15, 123456789, time.UTC)
+	tests := []struct {
+		name    string
+		field   reflect.StructField
+		option  string
+		want    interface{}
+		wantErr bool
+	}{
+		{"int default", reflect.StructField{Name: "A", Type: reflect.TypeOf(int64(0)), Tag: `grm:"autoCreateTime"`}, tagAutoCreateTime, now.Unix(), false},
+		{"int milli", reflect.StructField{Name: "A", Type: reflect.TypeOf(int64(0)), Tag: `grm:"autoCreateTime:milli"`}, tagAutoCreateTime, now.UnixNano() / int64(time.Millisecond), false},
+		{"int nano", reflect.StructField{Name: "A", Type: reflect.TypeOf(int64(0)), Tag: `grm:"autoUpdateTime:nano"`}, tagAutoUpdateTime, now.UnixNano(), false},
+		{"uint32 second", reflect.StructField{Name: "A", Type: reflect.TypeOf(uint32(0)), Tag: `grm:"autoUpdateTime:second"`}, tagAutoUpdateTime, uint32(now.Unix()), false},
+		{"time default", reflect.StructField{Name: "A", Type: timeType, Tag: `grm:"autoCreateTime"`}, tagAutoCreateTime, now, false},
+		{"time second", reflect.StructField{Name: "A", Type: timeType, Tag: `grm:"autoCreateTime:second"`}, tagAutoCreateTime, now.Truncate(time.Second), false},
+		{"null time milli", reflect.StructField{Name: "A", Type: nullTimeType, Tag: `grm:"autoCreateTime:milli"`}, tagAutoCreateTime, sql.NullTime{Time: now.Truncate(time.Millisecond), Valid: true}, false},
+		{"unknown precision", reflect.StructField{Name: "A", Type: timeType, Tag: `grm:"autoCreateTime:micro"`}, tagAutoCreateTime, nil, true},
+		{"unsupported type", reflect.StructField{Name: "A", Type: reflect.TypeOf(""), Tag: `grm:"autoCreateTime"`}, tagAutoCreateTime, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := autoTimeValue(&tt.field, tt.option, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(value.Interface(), tt.want) {
+				t.Errorf("value = %v, want %v", value.Interface(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSetStructAutoTime(t *testing.T) {
+	now := time.Date(2026, 10, 1, 8, 30, 15, 123456789, time.UTC)
+	//保存时设置创建时间和更新时间,不覆盖已经赋值的创建时间
+	//Set the create time and update time when inserting, and do not overwrite the assigned create time
+	history := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	demo := &autoTimeDemo{CreateTime: history}
+	if err := setStructAutoTime(demo, now, true); err != nil {
+		t.Fatal(err)
+	}
+	if !demo.CreateTime.Equal(history) {
+		t.Errorf("CreateTime = %v, want %v", demo.CreateTime, history)
+	}
+	if demo.UpdateTime == nil || !demo.UpdateTime.Equal(now.Truncate(time.Second)) {
+		t.Errorf("UpdateTime = %v, want %v", demo.UpdateTime, now.Truncate(time.Second))
+	}
+	stamp := &autoStampDemo{}
+	if err := setStructAutoTime(stamp, now, true); err != nil {
+		t.Fatal(err)
+	}
+	if stamp.CreateAt != now.UnixNano()/int64(time.Millisecond) {
+		t.Errorf("CreateAt = %d", stamp.CreateAt)
+	}
+	if !stamp.UpdateAt.Valid || !stamp.UpdateAt.Time.Equal(now.Truncate(time.Millisecond)) {
+		t.Errorf("UpdateAt = %v", stamp.UpdateAt)
+	}
+
+	//更新时只设置更新时间
+	//Only the update time is set when updating
+	later := now.Add(time.Hour)
+	demo = &autoTimeDemo{}
+	if err := setStructAutoTime(demo, later, false); err != nil {
+		t.Fatal(err)
+	}
+	if !demo.CreateTime.IsZero() {
+		t.Errorf("CreateTime = %v, want zero when updating", demo.CreateTime)
+	}
+	if demo.UpdateTime == nil || !demo.UpdateTime.Equal(later.Truncate(time.Second)) {
+		t.Errorf("UpdateTime = %v, want %v", demo.UpdateTime, later.Truncate(time.Second))
+	}
+}
+
+func TestSetMapAutoTime(t *testing.T) {
+	now := time.Date(2026, 10, 1, 8, 30, 15, 0, time.UTC)
+	entityMap := NewEntityMap("t_auto_time")
+	entityMap.AutoCreateTimeColumn = "create_time"
+	entityMap.AutoUpdateTimeColumn = "update_time"
+	history := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	entityMap.Set("create_time", history)
+	setMapAutoTime(entityMap, now, true)
+	if got := entityMap.FieldMap()["create_time"]; got != history {
+		t.Errorf("create_time = %v, want %v", got, history)
+	}
+	if got := entityMap.FieldMap()["update_time"]; got != now {
+		t.Errorf("update_time = %v, want %v", got, now)
+	}
+	//更新时覆盖更新时间
+	//The update time is overwritten when updating
+	later := now.Add(time.Hour)
+	setMapAutoTime(entityMap, later, false)
+	if got := entityMap.FieldMap()["update_time"]; got != later {
+		t.Errorf("update_time = %v, want %v", got, later)
+	}
+}
+
+func TestInsertAutoTime(t *testing.T) {
+	oldFunc := FuncNow
+	defer func() {
+		FuncNow = oldFunc
+	}()
+	now := time.Date(2026, 10, 1, 8, 30, 15, 0, time.FixedZone("CST", 8*3600))
+	FuncNow = func() time.Time {
+		return now
+	}
+	//AutoTimeUTC转换为UTC时间
+	//AutoTimeUTC converts to UTC time
+	ctx, _, _ := newFakeDao(t, &DBConfig{Dialect: "mysql", AutoTimeUTC: true})
+	demo := &autoTimeDemo{}
+	_, err := Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		return Insert(ctx, demo)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !demo.CreateTime.Equal(now) || demo.CreateTime.Location() != time.UTC {
+		t.Errorf("CreateTime = %v, want %v in UTC", demo.CreateTime, now.UTC())
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strconv"
 	"time"
 )
 
@@ -19,8 +21,26 @@ type dataSource struct {
 type DBConfig struct {
 	//DSN DataSourceName Database connection string
 	DSN string //
-	//Database Type:mysql,postgresql,oracle,mssql,sqlite,clickhouse corresponds to Driver,A database may have multiple drivers
+	//Driver sql.Open使用的驱动名称,例如mysql,postgres,pgx,sqlite3,sqlserver,godror
+	//Driver The driver name used by sql.Open, such as mysql, postgres, pgx, sqlite3, sqlserver, godror
 	Driver string
+	//Dialect 数据库方言:mysql,postgresql,oracle,mssql,sqlite,clickhouse,dm,kingbase,opengauss,用于生成SQL,参见grm.Dialect.为空时根据Driver在DriverDialectMap中获取,没有对应的值使用Driver
+	//Dialect Database dialect: mysql, postgresql, oracle, mssql, sqlite, clickhouse, dm, kingbase, opengauss, used to generate SQL, see grm.Dialect.
+	//When it is empty, it is obtained from DriverDialectMap according to Driver, and Driver is used if there is no corresponding value
+	Dialect string
+	//DialectVersion 数据库的主版本号,用于兼容旧版本的语法,例如oracle 11g是11,sqlserver 2008是10,分页使用ROWNUM和ROW_NUMBER()
+	//为0时NewDao查询数据库的版本,目前用于oracle和mssql
+	//DialectVersion The major version of the database, used to be compatible with the syntax of the old version,
+	//for example, oracle 11g is 11, sqlserver 2008 is 10, paging uses ROWNUM and ROW_NUMBER().
+	//When it is 0, NewDao queries the version of the database, currently used for oracle and mssql
+	DialectVersion int
+	//QuoteIdentifier 生成的INSERT,UPDATE,DELETE语句是否按照方言转义表名和字段名,例如mysql的`order`,postgresql的"userName",mssql的[user],默认false
+	//oracle和达梦不转义时名称会转为大写,转义后区分大小写,开启前请确认表名和字段名的大小写和数据库一致.手动编写的Finder语句不转义
+	//QuoteIdentifier Whether the generated INSERT, UPDATE, DELETE statements quote the table name and column name according to the dialect,
+	//such as `order` of mysql, "userName" of postgresql, [user] of mssql, default false.
+	//Oracle and dm convert unquoted names to uppercase, and quoted names are case-sensitive. Please confirm that the case of
+	//the table name and column name is consistent with the database before enabling. Manually written Finder statements are not quoted
+	QuoteIdentifier bool
 	//ShowSQL Whether to print SQL, use grm.ShowSQL record sql
 	ShowSQL bool
 	//MaxOpenConns Maximum number of database connections, Default 50
@@ -36,10 +56,55 @@ type DBConfig struct {
 	//事务隔离级别的默认配置,默认为nil
 	DefaultTxOptions *sql.TxOptions
 
+	//事务遇到死锁或者序列化失败时的默认重试策略,默认为nil,不重试
+	//DefaultTxRetryPolicy The default retry policy when the transaction encounters deadlock or serialization failure, the default is nil, no retry
+	DefaultTxRetryPolicy *TxRetryPolicy
+
+	//多租户的字段名,例如tenant_id,默认为空,不开启多租户.开启后需要使用grm.BindCtxTenant绑定租户,参见BindCtxTenant
+	//TenantColumn The column name of the multi-tenant, such as tenant_id, the default is empty, multi-tenancy is not enabled.
+	//After enabling, you need to use grm.BindCtxTenant to bind the tenant, see BindCtxTenant
+	TenantColumn string
+
+	//自动填充的时间是否使用UTC时间,默认false,使用本地时间(time.Local),需要和数据库的时区一致.参见FuncNow
+	//AutoTimeUTC Whether the auto-filled time uses UTC time, the default is false, using local time (time.Local),
+	//which needs to be consistent with the time zone of the database. See FuncNow
+	AutoTimeUTC bool
+
 	//使用现有的数据库连接,优先级高于DSN
 	SQLDB *sql.DB
 }
 
+// DriverDialectMap 驱动名称和数据库方言的对应关系,key是sql.Open使用的驱动名称,value是Dialect.Name().一般是放到init方法里进行添加
+// DriverDialectMap The mapping between the driver name and the database dialect, the key is the driver name used by sql.Open,
+// and the value is Dialect.Name(). Generally added in the init method
+var DriverDialectMap = map[string]string{
+	"mysql":      "mysql",
+	"postgres":   "postgresql",
+	"pgx":        "postgresql",
+	"postgresql": "postgresql",
+	"sqlite3":    "sqlite",
+	"sqlite":     "sqlite",
+	"sqlserver":  "mssql",
+	"mssql":      "mssql",
+	"godror":     "oracle",
+	"oci8":       "oracle",
+	"oracle":     "oracle",
+	"clickhouse": "clickhouse",
+	"chhttp":     "clickhouse",
+	"dm":         "dm",
+	"kingbase":   "kingbase",
+	"opengauss":  "opengauss",
+}
+
+// driverDialect 根据驱动名称获取数据库方言,没有对应的值返回驱动名称
+// driverDialect Get the database dialect according to the driver name, and return the driver name if there is no corresponding value
+func driverDialect(driver string) string {
+	if dialect, ok := DriverDialectMap[driver]; ok {
+		return dialect
+	}
+	return driver
+}
+
 // newDataSource 创建一个新的datasource,内部调用,避免外部直接使用datasource
 // newDAtaSource Create a new datasource and call it internally to avoid direct external use of the datasource
 func newDataSource(config *DBConfig) (*dataSource, error) {
@@ -51,6 +116,9 @@ func newDataSource(config *DBConfig) (*dataSource, error) {
 	if config.Driver == "" {
 		return nil, errors.New("Driver cannot be empty")
 	}
+	if config.Dialect == "" {
+		config.Dialect = driverDialect(config.Driver)
+	}
 	var db *sql.DB
 	var errSQLOpen error
 
@@ -106,11 +174,38 @@ type dbConnection struct {
 	tx *sql.Tx
 	// 数据库配置
 	cfg *DBConfig
+	// savepoint的序号,用于生成嵌套事务的savepoint名称
+	// savepoint sequence number, used to generate the savepoint name of nested transaction
+	savepointSeq int
+	// 事务提交成功后执行的函数,由OnCommit注册
+	// The functions executed after the transaction is committed successfully, registered by OnCommit
+	commitFuncs []func(ctx context.Context)
+	// 事务回滚后执行的函数,由OnRollback注册
+	// The functions executed after the transaction is rolled back, registered by OnRollback
+	rollbackFuncs []func(ctx context.Context)
+	// XA分支事务使用的数据库连接,XA的语句必须在同一个连接上执行
+	// The database connection used by the XA branch transaction, the XA statements must be executed on the same connection
+	xaConn *sql.Conn
+	// XA分支事务的xid,不为空代表处于XA分支事务中
+	// The xid of the XA branch transaction, not empty means it is in the XA branch transaction
+	xid string
+	// 是否以只读方式开启事务
+	// Whether to start the transaction in read-only mode
+	readOnly bool
+	// 嵌套事务panic的错误,不为nil时开启方不再提交,回滚整个事务
+	// The error of the nested transaction panic, when it is not nil, the opener no longer commits and rolls back the entire transaction
+	rollbackOnly error
 
 	//commitSign   int8    // 提交标记,控制是否提交事务
 	//rollbackSign bool    // 回滚标记,控制是否回滚事务
 }
 
+// inTx 是否处于事务中,包括本地事务和XA分支事务
+// inTx Whether it is in a transaction, including local transaction and XA branch transaction
+func (dbConn *dbConnection) inTx() bool {
+	return dbConn.tx != nil || dbConn.xid != ""
+}
+
 // beginTx Open transaction
 func (dbConn *dbConnection) beginTx(ctx context.Context) error {
 	//s.rollbackSign = true
@@ -124,6 +219,15 @@ func (dbConn *dbConnection) beginTx(ctx context.Context) error {
 		} else {
 			txOptions = dbConn.cfg.DefaultTxOptions
 		}
+		//只读事务,保留隔离级别
+		//Read-only transaction, keep the isolation level
+		if dbConn.readOnly {
+			readOnlyOptions := sql.TxOptions{ReadOnly: true}
+			if txOptions != nil {
+				readOnlyOptions.Isolation = txOptions.Isolation
+			}
+			txOptions = &readOnlyOptions
+		}
 
 		tx, err := dbConn.db.BeginTx(ctx, txOptions)
 		if err != nil {
@@ -131,6 +235,7 @@ func (dbConn *dbConnection) beginTx(ctx context.Context) error {
 			return err
 		}
 		dbConn.tx = tx
+		registerTx(ctx, dbConn)
 		//s.commitSign = beginStatus
 		return nil
 	}
@@ -143,11 +248,14 @@ func (dbConn *dbConnection) rollback() error {
 	//if s.tx != nil && s.rollbackSign == true {
 	if dbConn.tx != nil {
 		err := dbConn.tx.Rollback()
+		//无论回滚是否成功,事务都已经结束,不能再使用
+		//Whether the rollback is successful or not, the transaction has ended and can no longer be used
+		dbConn.tx = nil
+		unregisterTx(dbConn)
 		if err != nil {
 			err = errors.New("rollback事务回滚失败: " + err.Error())
 			return err
 		}
-		dbConn.tx = nil
 		return nil
 	}
 	return nil
@@ -160,14 +268,111 @@ func (dbConn *dbConnection) commit() error {
 		return errors.New("commit事务为空")
 	}
 	err := dbConn.tx.Commit()
+	//无论提交是否成功,事务都已经结束,不能再使用
+	//Whether the commit is successful or not, the transaction has ended and can no longer be used
+	dbConn.tx = nil
+	unregisterTx(dbConn)
 	if err != nil {
 		err = errors.New("commit事务提交失败: " + err.Error())
 		return err
 	}
-	dbConn.tx = nil
 	return nil
 }
 
+// savepoint 在当前事务中创建savepoint,返回savepoint的名称.如果数据库不支持savepoint,返回""
+// savepoint Create a savepoint in the current transaction and return its name. If the database does not support savepoint, return ""
+func (dbConn *dbConnection) savepoint(ctx context.Context) (string, error) {
+	if !dbConn.inTx() {
+		return "", errors.New("savepoint事务为空")
+	}
+	name := "grm_sp_" + strconv.Itoa(dbConn.savepointSeq+1)
+	spSQL, err := wrapSavepointSQL(dbConn.cfg, savepointCreate, name)
+	if err != nil || spSQL == "" {
+		return "", err
+	}
+	if _, err = dbConn.execCtx(ctx, &spSQL, nil); err != nil {
+		return "", errors.New("savepoint创建失败: " + err.Error())
+	}
+	dbConn.savepointSeq++
+	return name, nil
+}
+
+// rollbackTo 回滚到指定的savepoint,外层事务不受影响
+// rollbackTo Roll back to the specified savepoint, the outer transaction is not affected
+func (dbConn *dbConnection) rollbackTo(ctx context.Context, name string) error {
+	if !dbConn.inTx() {
+		return errors.New("rollbackTo事务为空")
+	}
+	spSQL, err := wrapSavepointSQL(dbConn.cfg, savepointRollback, name)
+	if err != nil || spSQL == "" {
+		return err
+	}
+	if _, err = dbConn.execCtx(ctx, &spSQL, nil); err != nil {
+		return errors.New("rollbackTo回滚savepoint失败: " + err.Error())
+	}
+	return nil
+}
+
+// releaseSavepoint 释放savepoint,oracle和mssql没有释放语法,不做处理
+// releaseSavepoint Release the savepoint, oracle and mssql have no release syntax and are not processed
+func (dbConn *dbConnection) releaseSavepoint(ctx context.Context, name string) error {
+	if !dbConn.inTx() {
+		return errors.New("releaseSavepoint事务为空")
+	}
+	spSQL, err := wrapSavepointSQL(dbConn.cfg, savepointRelease, name)
+	if err != nil || spSQL == "" {
+		return err
+	}
+	if _, err = dbConn.execCtx(ctx, &spSQL, nil); err != nil {
+		return errors.New("releaseSavepoint释放savepoint失败: " + err.Error())
+	}
+	return nil
+}
+
+// afterCommit 事务提交成功后,执行OnCommit注册的函数,清空注册的函数
+// afterCommit After the transaction is committed successfully, execute the functions registered by OnCommit and clear the registered functions
+func (dbConn *dbConnection) afterCommit(ctx context.Context) {
+	funcs := dbConn.commitFuncs
+	dbConn.commitFuncs = nil
+	dbConn.rollbackFuncs = nil
+	runTxFuncs(ctx, funcs)
+}
+
+// afterRollback 事务回滚后,执行OnRollback注册的函数,清空注册的函数
+// afterRollback After the transaction is rolled back, execute the functions registered by OnRollback and clear the registered functions
+func (dbConn *dbConnection) afterRollback(ctx context.Context) {
+	funcs := dbConn.rollbackFuncs
+	dbConn.commitFuncs = nil
+	dbConn.rollbackFuncs = nil
+	runTxFuncs(ctx, funcs)
+}
+
+// afterRollbackTo 回滚到savepoint后,丢弃savepoint之后注册的OnCommit函数.savepoint之后注册的OnRollback函数留在外层事务中,开启方最终回滚时才执行
+// commitLen是创建savepoint时已经注册的OnCommit函数数量
+// afterRollbackTo After rolling back to the savepoint, discard the OnCommit functions registered after the savepoint.
+// The OnRollback functions registered after the savepoint stay in the outer transaction and are executed only when the opener finally rolls back.
+// commitLen is the number of OnCommit functions registered when the savepoint was created
+func (dbConn *dbConnection) afterRollbackTo(commitLen int) {
+	if len(dbConn.commitFuncs) > commitLen {
+		dbConn.commitFuncs = dbConn.commitFuncs[:commitLen]
+	}
+}
+
+// runTxFuncs 依次执行事务结束后的函数,单个函数的异常不影响其他函数
+// runTxFuncs Execute the functions after the transaction ends in sequence, the panic of a single function does not affect other functions
+func runTxFuncs(ctx context.Context, funcs []func(ctx context.Context)) {
+	for _, fn := range funcs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					LogErr(fmt.Sprintf("runTxFuncs-->recover error: %v", r))
+				}
+			}()
+			fn(ctx)
+		}()
+	}
+}
+
 // execContext 执行sql语句,如果已经开启事务,就以事务方式执行,如果没有开启事务,就以非事务方式执行
 // execContext Execute sql statement,If the transaction has been opened,it will be executed in transaction mode, if the transaction is not opened,it will be executed in non-transactional mode
 func (dbConn *dbConnection) execCtx(ctx context.Context, execSql *string, args []interface{}) (*sql.Result, error) {
@@ -183,6 +388,10 @@ func (dbConn *dbConnection) execCtx(ctx context.Context, execSql *string, args [
 		res, resErr := dbConn.tx.ExecContext(ctx, *execSql, args...)
 		return &res, resErr
 	}
+	if dbConn.xaConn != nil {
+		res, resErr := dbConn.xaConn.ExecContext(ctx, *execSql, args...)
+		return &res, resErr
+	}
 	res, resErr := dbConn.db.ExecContext(ctx, *execSql, args...)
 	return &res, resErr
 }
@@ -198,6 +407,9 @@ func (dbConn *dbConnection) queryRowCtx(ctx context.Context, query *string, args
 	if dbConn.tx != nil {
 		return dbConn.tx.QueryRowContext(ctx, *query, args...)
 	}
+	if dbConn.xaConn != nil {
+		return dbConn.xaConn.QueryRowContext(ctx, *query, args...)
+	}
 	return dbConn.db.QueryRowContext(ctx, *query, args...)
 }
 
@@ -213,6 +425,9 @@ func (dbConn *dbConnection) queryCtx(ctx context.Context, query *string, args []
 	if dbConn.tx != nil {
 		return dbConn.tx.QueryContext(ctx, *query, args...)
 	}
+	if dbConn.xaConn != nil {
+		return dbConn.xaConn.QueryContext(ctx, *query, args...)
+	}
 	return dbConn.db.QueryContext(ctx, *query, args...)
 }
 
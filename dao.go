@@ -17,6 +17,8 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // FuncReadWriteStrategy 单个数据库的读写分离的策略,用于外部复写实现自定义的逻辑,rwType=0 read,rwType=1 write
@@ -26,6 +28,17 @@ import (
 // "BindCtxDBConn" is already a connection to the specified database and will conflict with this function. As a single database read and write separation of processing
 var FuncReadWriteStrategy func(rwType int) *DBDao = getDefaultDao
 
+// FuncReadWriteStrategyCtx 根据ctx的读写分离策略,不为nil时优先于FuncReadWriteStrategy,例如根据BindCtxMaxReplicaLag跳过延迟高的从库,rwType=0 read,rwType=1 write
+// FuncReadWriteStrategyCtx Read-write separation strategy according to ctx, when not nil, it takes precedence over FuncReadWriteStrategy,
+// for example, skip replicas with high lag according to BindCtxMaxReplicaLag, rwType=0 read, rwType=1 write
+var FuncReadWriteStrategyCtx func(ctx context.Context, rwType int) *DBDao = nil
+
+// FuncTableNameResolver 根据ctx和实体类获取物理表名,用于按月分表等动态表名的场景,默认使用TableName()
+// entity是IEntityStruct或者IEntityMap,Insert,Update,Delete等方法和NewSelectFinderByEntity都使用这个函数获取表名
+// FuncTableNameResolver Get the physical table name according to ctx and entity, used for scenarios with dynamic table names such as monthly tables, TableName() is used by default.
+// entity is IEntityStruct or IEntityMap, Insert, Update, Delete and other methods and NewSelectFinderByEntity all use this function to get the table name
+var FuncTableNameResolver func(ctx context.Context, entity interface{}) (string, error) = resolveTableName
+
 // wrapCtxKey 包装context的key,不直接使用string类型,避免外部直接注入使用
 type wrapCtxKey string
 
@@ -36,6 +49,14 @@ const ctxConnKey = wrapCtxKey("ctxConnKey")
 //事务选项设置TxOptions,主要是设置事务的隔离级别
 const ctxTxOptKey = wrapCtxKey("ctxTxOptKey")
 
+//只读事务的dbConn,在只读事务函数内禁止更新操作
+//The dbConn of the read-only transaction, update operations are prohibited in the read-only transaction function
+const ctxReadOnlyKey = wrapCtxKey("ctxReadOnlyKey")
+
+//读己之写,写操作之后的查询在时间窗口内使用主库
+//Read your writes, queries after write operations use the primary within the time window
+const ctxReadYourWritesKey = wrapCtxKey("ctxReadYourWritesKey")
+
 // NewContextDBConnectionValueKey 创建context中存放DBConnection的key
 // 故意使用一个公开方法,返回私有类型wrapCtxKey,多库时禁止自定义contextKey,只能调用这个方法,不能接收也不能改变
 // 例如:ctx = context.WithValue(ctx, grm.NewContextDBConnectionValueKey(), dbConn)
@@ -68,11 +89,24 @@ var defaultDao *DBDao = nil
 // NewDao Creates dbDao, a database must be executed only once, and the business is controlled by itself
 // The first database to be executed is defaultDao, and the subsequent grm.xxx method is defaultDao by default
 func NewDao(config *DBConfig) (*DBDao, error) {
+	//没有注册的方言无法生成SQL语句,不创建dataSource
+	//Unregistered dialects cannot generate SQL statements, the dataSource is not created
+	if config != nil && config.Driver != "" {
+		if config.Dialect == "" {
+			config.Dialect = driverDialect(config.Driver)
+		}
+		if _, err := getDialect(config.Dialect); err != nil {
+			return nil, errors.New("NewDao-->" + err.Error())
+		}
+	}
 	dataSource, err := newDataSource(config)
 
 	if err != nil {
 		return nil, LogErr("NewDao创建dataSource失败: " + err.Error())
 	}
+	//查询数据库的版本,兼容旧版本的语法,例如oracle 11g的分页
+	//Query the version of the database to be compatible with the syntax of the old version, such as paging of oracle 11g
+	detectDialectVersion(config, dataSource.DB)
 
 	if FuncReadWriteStrategy(1) == nil {
 		defaultDao = &DBDao{config, dataSource}
@@ -81,6 +115,15 @@ func NewDao(config *DBConfig) (*DBDao, error) {
 	return &DBDao{config, dataSource}, nil
 }
 
+// resolveTableName 默认的表名,使用实体类的TableName()
+// resolveTableName The default table name, use TableName() of the entity
+func resolveTableName(ctx context.Context, entity interface{}) (string, error) {
+	if entityTable, ok := entity.(interface{ TableName() string }); ok {
+		return entityTable.TableName(), nil
+	}
+	return "", errors.New("resolveTableName-->实体类必须有TableName方法")
+}
+
 // getDefaultDao 获取默认的Dao,用于隔离读写的Dao
 // getDefaultDao Get the default Dao, used to isolate Dao for reading and writing
 func getDefaultDao(rwType int) *DBDao {
@@ -128,6 +171,116 @@ func (dbDao *DBDao) BindCtxTxOptions(parent context.Context, txOptions *sql.TxOp
 	return ctx, nil
 }
 
+// readYourWrites 记录ctx最后一次写操作的时间,子context中的写操作也会更新,所以使用指针
+// readYourWrites Record the time of the last write operation of ctx, write operations in the sub-context will also update it, so use a pointer
+type readYourWrites struct {
+	window time.Duration
+	// 最后一次写操作的时间,UnixNano,原子操作
+	// The time of the last write operation, UnixNano, atomic operation
+	lastWrite int64
+}
+
+// BindCtxReadYourWrites 绑定读己之写到ctx,ctx(包括子context)执行Insert,Update,Delete,UpdateFinder等写操作之后,
+// window时间内的Query,QueryRow等查询使用FuncReadWriteStrategy(1)的主库,避免从库延迟读到旧数据.parent不能为空
+// 一般在请求开始时调用,例如web框架的中间件
+// BindCtxReadYourWrites Bind read-your-writes to ctx, after ctx (including sub-contexts) performs write operations such as Insert, Update, Delete, UpdateFinder,
+// queries such as Query and QueryRow within window use the primary of FuncReadWriteStrategy(1) to avoid reading stale data from a lagging replica. parent cannot be nil
+// Generally called at the beginning of the request, such as the middleware of the web framework
+func BindCtxReadYourWrites(parent context.Context, window time.Duration) (context.Context, error) {
+	if parent == nil {
+		return nil, errors.New("BindCtxReadYourWrites context的parent不能为nil")
+	}
+	ctx := context.WithValue(parent, ctxReadYourWritesKey, &readYourWrites{window: window})
+	return ctx, nil
+}
+
+// markWrite 写操作执行成功后记录ctx的写操作时间.事务内的写操作在事务提交成功后记录,事务回滚或者回滚到savepoint不记录
+// 从提交的时间开始计算读己之写的时间窗口,避免长事务提交时窗口已经过期
+// markWrite Record the write operation time of ctx after the write operation succeeds. The write operation in the transaction is recorded
+// after the transaction is committed successfully, and is not recorded if the transaction is rolled back or rolled back to the savepoint.
+// The read-your-writes time window starts from the commit time, to avoid the window having expired when a long transaction commits
+func markWrite(ctx context.Context, dbConn *dbConnection) {
+	ryw, ok := ctx.Value(ctxReadYourWritesKey).(*readYourWrites)
+	if !ok {
+		return
+	}
+	stamp := func(ctx context.Context) {
+		atomic.StoreInt64(&ryw.lastWrite, time.Now().UnixNano())
+	}
+	if dbConn.inTx() {
+		dbConn.commitFuncs = append(dbConn.commitFuncs, stamp)
+		return
+	}
+	stamp(ctx)
+}
+
+// getReadWriteDao 根据读写类型获取DBDao,ctx在读己之写的时间窗口内,读操作也使用主库
+// getReadWriteDao Get DBDao according to the read-write type, if ctx is within the read-your-writes time window, read operations also use the primary
+func getReadWriteDao(ctx context.Context, rwType int) *DBDao {
+	if rwType == 0 {
+		if ryw, ok := ctx.Value(ctxReadYourWritesKey).(*readYourWrites); ok {
+			lastWrite := atomic.LoadInt64(&ryw.lastWrite)
+			if lastWrite > 0 && time.Since(time.Unix(0, lastWrite)) < ryw.window {
+				rwType = 1
+			}
+		}
+	}
+	if FuncReadWriteStrategyCtx != nil {
+		return FuncReadWriteStrategyCtx(ctx, rwType)
+	}
+	return FuncReadWriteStrategy(rwType)
+}
+
+// getDBConfig 获取数据库的配置,dbConn为nil时使用getReadWriteDao的配置
+// getDBConfig Get the configuration of the database, use the configuration of getReadWriteDao when dbConn is nil
+func getDBConfig(ctx context.Context, dbConn *dbConnection, rwType int) *DBConfig {
+	if dbConn != nil {
+		return dbConn.cfg
+	}
+	if dbDao := getReadWriteDao(ctx, rwType); dbDao != nil {
+		return dbDao.config
+	}
+	return nil
+}
+
+// Driver 返回sql.Open使用的驱动名称,生成方言语句请使用Dialect
+// Driver Returns the driver name used by sql.Open, please use Dialect to generate dialect statements
+func (dbDao *DBDao) Driver() string {
+	if dbDao == nil || dbDao.config == nil {
+		return ""
+	}
+	return dbDao.config.Driver
+}
+
+// Dialect 返回数据库方言,例如mysql,postgresql,用于扩展包根据数据库方言生成语句.参见DBConfig.Dialect
+// Dialect Returns the database dialect, such as mysql, postgresql, used by extension packages to generate statements according to the dialect. See DBConfig.Dialect
+func (dbDao *DBDao) Dialect() string {
+	if dbDao == nil || dbDao.config == nil {
+		return ""
+	}
+	return dbDao.config.Dialect
+}
+
+// LockSkipSQL 生成锁定查询的行并跳过其他事务已经锁定的行的语句,用于多个实例并发消费同一个表,例如outbox的Relay
+// 方言没有实现LockSkipDialect返回错误,参见LockSkipDialect
+// LockSkipSQL Generate the statement that locks the queried rows and skips the rows already locked by other transactions,
+// used for multiple instances to consume the same table concurrently, such as the Relay of outbox.
+// Return an error if the dialect does not implement LockSkipDialect, see LockSkipDialect
+func (dbDao *DBDao) LockSkipSQL(tableName string, alias string, columns string, where string, orderBy string, limit int) (string, error) {
+	if dbDao == nil || dbDao.config == nil {
+		return "", errors.New("LockSkipSQL-->dbDao和config不能为nil")
+	}
+	dialect, err := getConfigDialect(dbDao.config)
+	if err != nil {
+		return "", err
+	}
+	lockDialect, ok := dialect.(LockSkipDialect)
+	if !ok {
+		return "", errors.New("LockSkipSQL-->数据库方言没有实现LockSkipDialect:" + dbDao.config.Dialect)
+	}
+	return lockDialect.LockSkipSQL(tableName, alias, columns, where, orderBy, limit), nil
+}
+
 // CloseDB 关闭所有数据库连接
 //请谨慎调用这个方法,会关闭所有数据库连接,用于处理特殊场景,正常使用无需手动关闭数据库连接
 func (dbDao *DBDao) CloseDB() error {
@@ -153,20 +306,23 @@ Transaction 的示例代码
 // 如果入参ctx中没有dbConn,使用defaultDao开启事务并最后提交
 // 如果入参ctx有dbConn且没有事务,调用dbConn.begin()开启事务并最后提交
 // 如果入参ctx有dbConn且有事务,只使用不提交,有开启方提交事务
-// 但是如果遇到错误或者异常,虽然不是事务的开启方,也会回滚事务,让事务尽早回滚
+// 嵌套的Transaction会创建savepoint,如果遇到错误或者异常,只回滚到savepoint,外层事务不受影响,由外层决定提交还是回滚
+// 如果数据库不支持savepoint,例如clickhouse,虽然不是事务的开启方,也会回滚事务,让事务尽早回滚
 // 在多库的场景,手动获取dbConn,然后绑定到一个新的context,传入进来
 // 不要去掉匿名函数的context参数,因为如果Transaction的context中没有dbConn,会新建一个context并放入dbConn,此时的context指针已经变化,不能直接使用Transaction的context参数
 // bug(springrain)如果有大神修改了匿名函数内的参数名,例如改为ctx2,这样业务代码实际使用的是Transaction的context参数,如果为没有dbConn,会抛异常,如果有dbConn,实际就是一个对象.影响有限.也可以把匿名函数抽到外部
 // 如果全局DefaultTxOptions配置不满足需求,可以在grm.Transaction事务方法前设置事务的隔离级别,例如 ctx, _ := dbDao.BindCtxTxOptions(ctx, &sql.TxOptions{Isolation: sql.LevelDefault}),如果txOptions为nil,使用全局DefaultTxOptions
 // return的error如果不为nil,事务就会回滚
-// 分布式事务需要传递XID,接收方context.WithValue(ctx, "XID", XID)绑定到ctx
+// 多个数据库的分布式事务使用XACoordinator.Transaction,分支ctx内的Transaction加入XA分支事务,由XACoordinator两阶段提交
 // 如果分支事务出现异常或者回滚,会立即回滚分布式事务
 // Transaction method, isolate db Connection related API. This method must be used for transaction processing and unified transaction mode
 // If there is no db Connection in the input ctx, use default Dao to start the transaction and submit it finally
 // If the input ctx has db Connection and no transaction, call db Connection.begin() to start the transaction and finally commit
 // If the input ctx has a db Connection and a transaction, only use non-commit, and the open party submits the transaction
-// If you encounter an error or exception, although it is not the initiator of the transaction, the transaction will be rolled back,
-// so that the transaction can be rolled back as soon as possible
+// The nested Transaction creates a savepoint. If you encounter an error or exception, only roll back to the savepoint,
+// the outer transaction is not affected, and the outer layer decides to commit or roll back
+// If the database does not support savepoint, such as clickhouse, although it is not the initiator of the transaction,
+// the transaction will be rolled back, so that the transaction can be rolled back as soon as possible
 // In a multi-database scenario, manually obtain db Connection, then bind it to a new context and pass in
 // Do not drop the anonymous function's context parameter, because if the Transaction context does not have a DBConnection,
 // then a new context will be created and placed in the DBConnection
@@ -176,10 +332,157 @@ Transaction 的示例代码
 // an exception will be thrown. If there is a db Connection, the actual It is an object
 // The impact is limited. Anonymous functions can also be extracted outside
 // If the return error is not nil, the transaction will be rolled back
+// Distributed transactions across multiple databases use XACoordinator.Transaction, Transaction in the branch ctx joins the XA branch transaction,
+// and XACoordinator commits in two phases
 func Transaction(ctx context.Context, doTransaction func(ctx context.Context) (interface{}, error)) (interface{}, error) {
-	//是否是dbConn的开启方,如果是开启方,才可以提交事务
-	// Whether it is the opener of db Connection, if it is the opener, the transaction can be submitted
-	localTxOpen := false
+	return transaction(ctx, nil, doTransaction)
+}
+
+// Propagation 事务的传播行为,默认是PropagationRequired,和Transaction方法的行为一致
+// Propagation The propagation behavior of the transaction, the default is PropagationRequired, which is consistent with the Transaction method
+type Propagation int
+
+const (
+	// PropagationRequired 如果ctx中有事务就加入(嵌套时创建savepoint),没有就开启新事务
+	// PropagationRequired Join the transaction if ctx has one (create a savepoint when nested), otherwise start a new transaction
+	PropagationRequired Propagation = iota
+	// PropagationRequiresNew 挂起ctx中的事务,使用新的dbConn开启事务,独立提交或回滚,例如审计日志不受业务回滚的影响.在只读事务内依然是只读的
+	// PropagationRequiresNew Suspend the transaction in ctx, start a transaction with a new dbConn, commit or roll back independently.
+	// It is still read-only inside a read-only transaction
+	PropagationRequiresNew
+	// PropagationMandatory ctx中必须有事务,没有就返回错误
+	// PropagationMandatory There must be a transaction in ctx, otherwise an error is returned
+	PropagationMandatory
+	// PropagationNotSupported 挂起ctx中的事务,使用没有事务的dbConn执行
+	// PropagationNotSupported Suspend the transaction in ctx and execute with a dbConn without transaction
+	PropagationNotSupported
+	// PropagationNever ctx中不能有事务,有就返回错误,以非事务方式执行
+	// PropagationNever There must be no transaction in ctx, otherwise an error is returned, execute in non-transactional mode
+	PropagationNever
+)
+
+// TransactionOption 事务的配置项,用于TransactionWithOptions
+// TransactionOption Transaction configuration, used for TransactionWithOptions
+type TransactionOption struct {
+	// Propagation 事务的传播行为,默认PropagationRequired
+	// Propagation The propagation behavior of the transaction, default PropagationRequired
+	Propagation Propagation
+	// Retry 死锁或者序列化失败时的重试策略,为nil时使用DBConfig.DefaultTxRetryPolicy.只有事务的开启方才会重试
+	// Retry The retry policy for deadlock or serialization failure, when nil, use DBConfig.DefaultTxRetryPolicy. Only the opener of the transaction will retry
+	Retry *TxRetryPolicy
+	// ReadOnly 只读事务,使用FuncReadWriteStrategy(0)获取数据库,以sql.TxOptions{ReadOnly: true}开启事务,事务函数内禁止更新操作
+	// 如果ctx中已经有事务,加入外层事务,事务函数内依然禁止更新操作
+	// ReadOnly Read-only transaction, use FuncReadWriteStrategy(0) to obtain the database, start the transaction with sql.TxOptions{ReadOnly: true},
+	// update operations are prohibited in the transaction function. If there is already a transaction in ctx, join the outer transaction,
+	// update operations are still prohibited in the transaction function
+	ReadOnly bool
+}
+
+// TransactionReadOnly 只读事务,用于报表等需要在从库上多次查询,并且数据是一致快照的场景,事务函数内禁止更新操作
+// TransactionReadOnly Read-only transaction, used for scenarios such as reports that need to query multiple times on the replica with a consistent snapshot,
+// update operations are prohibited in the transaction function
+func TransactionReadOnly(ctx context.Context, doTransaction func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	return TransactionWithOptions(ctx, &TransactionOption{ReadOnly: true}, doTransaction)
+}
+
+// TransactionWithOptions 根据TransactionOption开启事务,option为nil时和Transaction方法一致
+// 事务的隔离级别依然使用BindCtxTxOptions或者DBConfig.DefaultTxOptions设置
+// TransactionWithOptions Start the transaction according to TransactionOption, when option is nil, it is consistent with the Transaction method
+// The isolation level of the transaction is still set by BindCtxTxOptions or DBConfig.DefaultTxOptions
+func TransactionWithOptions(ctx context.Context, option *TransactionOption, doTransaction func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	propagation := PropagationRequired
+	var retryPolicy *TxRetryPolicy
+	readOnly := false
+	if option != nil {
+		propagation = option.Propagation
+		retryPolicy = option.Retry
+		readOnly = option.ReadOnly
+	}
+	if propagation == PropagationRequired && !readOnly {
+		return transaction(ctx, retryPolicy, doTransaction)
+	}
+
+	//从context中获取数据库连接,可能为nil
+	//Get database connection from context, may be nil
+	dbConn, err := getDBConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hasTx := dbConn != nil && dbConn.inTx()
+
+	switch propagation {
+	case PropagationRequired:
+	case PropagationMandatory:
+		if !hasTx {
+			return nil, errors.New("TransactionWithOptions-->PropagationMandatory要求ctx中必须有事务")
+		}
+	case PropagationNever:
+		if hasTx {
+			return nil, errors.New("TransactionWithOptions-->PropagationNever要求ctx中不能有事务")
+		}
+	case PropagationRequiresNew, PropagationNotSupported:
+		//挂起外层事务,使用同一个数据库新的dbConn,外层的dbConn不受影响
+		//Suspend the outer transaction, use a new dbConn of the same database, the outer dbConn is not affected
+		if hasTx {
+			ctx = suspendDBConn(ctx, dbConn)
+		}
+	default:
+		return nil, errors.New("TransactionWithOptions-->不支持的事务传播行为:" + strconv.Itoa(int(propagation)))
+	}
+
+	if readOnly {
+		ctx, err = bindCtxReadOnly(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if propagation == PropagationNever || propagation == PropagationNotSupported {
+		return doTransaction(ctx)
+	}
+	return transaction(ctx, retryPolicy, doTransaction)
+}
+
+// bindCtxReadOnly 绑定只读的dbConn到ctx.ctx中没有dbConn时,使用FuncReadWriteStrategy(0)创建,有dbConn没有事务时,使用同一个数据库创建
+// ctx中已经有事务时,加入事务.ctx中记录只读的dbConn,禁止更新操作
+// bindCtxReadOnly Bind the read-only dbConn to ctx. When there is no dbConn in ctx, use FuncReadWriteStrategy(0) to create it,
+// when there is a dbConn without a transaction, create it with the same database. When there is already a transaction in ctx, join the transaction.
+// The read-only dbConn is recorded in ctx, and update operations are prohibited
+func bindCtxReadOnly(ctx context.Context) (context.Context, error) {
+	dbConn, err := getDBConn(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	if dbConn == nil {
+		dbConn, err = getReadWriteDao(ctx, 0).newDBConn()
+		if err != nil {
+			return ctx, err
+		}
+		dbConn.readOnly = true
+		ctx = context.WithValue(ctx, ctxConnKey, dbConn)
+	} else if !dbConn.inTx() {
+		dbConn = &dbConnection{db: dbConn.db, cfg: dbConn.cfg, readOnly: true}
+		ctx = context.WithValue(ctx, ctxConnKey, dbConn)
+	}
+	return context.WithValue(ctx, ctxReadOnlyKey, dbConn), nil
+}
+
+// suspendDBConn 挂起dbConn的事务,绑定同一个数据库没有事务的dbConn到ctx.只读事务挂起后依然是只读的,
+// 外层的只读事务一般使用从库,不能在从库上开启读写事务
+// suspendDBConn Suspend the transaction of dbConn and bind a dbConn of the same database without transaction to ctx.
+// The read-only transaction is still read-only after being suspended, the outer read-only transaction generally uses the replica,
+// and a read-write transaction cannot be started on the replica
+func suspendDBConn(ctx context.Context, dbConn *dbConnection) context.Context {
+	newConn := &dbConnection{db: dbConn.db, cfg: dbConn.cfg, readOnly: dbConn.readOnly}
+	ctx = context.WithValue(ctx, ctxConnKey, newConn)
+	if readOnlyConn, ok := ctx.Value(ctxReadOnlyKey).(*dbConnection); ok && readOnlyConn == dbConn {
+		ctx = context.WithValue(ctx, ctxReadOnlyKey, newConn)
+	}
+	return ctx
+}
+
+// transaction 事务的实现,加入ctx中的事务或者开启新事务
+// transaction The implementation of the transaction, join the transaction in ctx or start a new transaction
+func transaction(ctx context.Context, retryPolicy *TxRetryPolicy, doTransaction func(ctx context.Context) (interface{}, error)) (interface{}, error) {
 	//如果dbConn不存在,则会用默认的datasource开启事务
 	// If db Connection does not exist, the default datasource will be used to start the transaction
 	var checkErr error
@@ -189,48 +492,103 @@ func Transaction(ctx context.Context, doTransaction func(ctx context.Context) (i
 		return nil, checkErr
 	}
 
+	//是否是dbConn的开启方,只有开启方才可以重试
+	// Whether it is the opener of db Connection, only the opener can retry
+	localTxOpen := !dbConn.inTx()
+	if retryPolicy == nil {
+		retryPolicy = dbConn.cfg.DefaultTxRetryPolicy
+	}
+	for attempt := 1; ; attempt++ {
+		info, err, cause := transactionOnce(ctx, dbConn, doTransaction)
+		//乐观锁失败返回ErrStaleEntity本身,方便调用方判断
+		//Return ErrStaleEntity itself when the optimistic lock fails, which is convenient for the caller to judge
+		if cause == ErrStaleEntity {
+			return info, cause
+		}
+		//panic不重试
+		//Panic is not retried
+		if _, isPanic := cause.(*txPanicError); isPanic {
+			return info, err
+		}
+		//不是开启方,或者不是死锁/序列化失败,不再重试.err是LogErr的返回值,可能为nil,使用cause判断
+		//Not the opener, or not a deadlock/serialization failure, no more retries. err is the return value of LogErr and may be nil, use cause to judge
+		if cause == nil || !localTxOpen || dbConn.inTx() || retryPolicy == nil || attempt >= retryPolicy.MaxAttempts || !FuncTxRetryable(dbConn.cfg.Dialect, cause) {
+			return info, err
+		}
+		LogErr("Transaction-->第" + strconv.Itoa(attempt) + "次执行失败,重试事务: " + cause.Error())
+		if waitErr := retryPolicy.wait(ctx, attempt); waitErr != nil {
+			return info, err
+		}
+	}
+}
+
+// transactionOnce 执行一次事务,返回业务结果,错误信息和原始的错误原因,原始的错误用于判断是否需要重试
+// transactionOnce Execute the transaction once, return the business result, the error and the original cause, the cause is used to determine whether to retry
+func transactionOnce(ctx context.Context, dbConn *dbConnection, doTransaction func(ctx context.Context) (interface{}, error)) (info interface{}, err error, cause error) {
+	//是否是dbConn的开启方,如果是开启方,才可以提交事务
+	// Whether it is the opener of db Connection, if it is the opener, the transaction can be submitted
+	localTxOpen := false
+
 	//如果没有事务,并且事务没有被禁用,开启事务
 	//开启本地事务前,需要拿到分布式事务对象
 	//if dbConn.tx == nil && (!dbConn.cfg.DisableTransaction) {
-	if dbConn.tx == nil {
+	if !dbConn.inTx() {
 		beginErr := dbConn.beginTx(ctx)
 		if beginErr != nil {
-			return nil, LogErr("Transaction start error: " + beginErr.Error())
+			return nil, LogErr("Transaction start error: " + beginErr.Error()), beginErr
 		}
 		//本方法开启的事务,由本方法提交
 		//The transaction opened by this method is submitted by this method
 		localTxOpen = true
+		dbConn.rollbackOnly = nil
+	}
+
+	//已经存在事务,创建savepoint,嵌套事务出现错误只回滚到savepoint
+	//The transaction already exists, create a savepoint, the nested transaction only rolls back to the savepoint when an error occurs
+	savepointName := ""
+	if !localTxOpen {
+		var spErr error
+		savepointName, spErr = dbConn.savepoint(ctx)
+		if spErr != nil {
+			return nil, errors.New("Transaction-->savepoint创建savepoint失败: " + spErr.Error()), spErr
+		}
 	}
+	//savepoint之前已经注册的OnCommit函数数量,回滚到savepoint时,丢弃之后注册的函数
+	//The number of OnCommit functions registered before the savepoint, the functions registered later are discarded when rolling back to the savepoint
+	commitFuncsLen := len(dbConn.commitFuncs)
 
 	defer func() {
 		if r := recover(); r != nil {
-			//err = errors.New("事务开启失败:%w ", err)
-			//记录异常日志
-			//if _, ok := r.(runtime.Error); ok {
-			//	panic(r)
-			//}
-			err, errOk := r.(error)
-			if errOk {
-				LogErr("recover: " + err.Error())
+			//记录异常日志,panic转换为错误返回,外层事务收到错误后回滚,重试也不会把panic当作成功
+			//Record the exception log, the panic is converted to an error and returned,
+			//the outer transaction rolls back after receiving the error, and the retry will not treat the panic as success
+			if panicErr, ok := r.(error); ok {
+				LogErr("recover: " + panicErr.Error())
 			} else {
 				LogErr(fmt.Sprintf("recover error: %v", r))
 			}
-			//if !txOpen { //如果不是开启方,也应该回滚事务,虽然可能造成日志不准确,但是回滚要尽早
-			//	return
-			//}
-			//如果全局禁用了事务
-			//if dbConn.cfg.DisableTransaction {
-			//	return
-			//}
-
-			if err = dbConn.rollback(); err != nil {
-				LogErr("recover内事务回滚失败 " + err.Error())
+			cause = &txPanicError{value: r}
+			err = cause
+
+			if savepointName != "" {
+				if rbErr := dbConn.rollbackTo(ctx, savepointName); rbErr != nil {
+					LogErr("recover内savepoint回滚失败 " + rbErr.Error())
+				}
+				dbConn.afterRollbackTo(commitFuncsLen)
+				//即使外层忽略了错误,开启方也回滚整个事务
+				//Even if the outer layer ignores the error, the opener rolls back the entire transaction
+				dbConn.rollbackOnly = cause
+			} else {
+				if rbErr := dbConn.rollback(); rbErr != nil {
+					LogErr("recover内事务回滚失败 " + rbErr.Error())
+				}
+				dbConn.afterRollback(ctx)
 			}
 		}
 	}()
 
 	//执行业务的事务函数
-	info, err := doTransaction(ctx)
+	info, err = doTransaction(ctx)
 
 	if err != nil {
 		//如果全局禁用了事务
@@ -238,24 +596,105 @@ func Transaction(ctx context.Context, doTransaction func(ctx context.Context) (i
 		//	return info, err
 		//}
 
+		//嵌套事务只回滚到savepoint,由外层事务决定提交还是回滚
+		//The nested transaction only rolls back to the savepoint, and the outer transaction decides to commit or roll back
+		if savepointName != "" {
+			rbErr := dbConn.rollbackTo(ctx, savepointName)
+			if rbErr != nil {
+				LogErr("Transaction-->rollbackTo回滚savepoint失败 " + rbErr.Error())
+			}
+			dbConn.afterRollbackTo(commitFuncsLen)
+			return info, LogErr("Transaction-->doTransaction业务执行异常: " + err.Error()), err
+		}
+
 		//不是开启方回滚事务,有可能造成日志记录不准确,但是回滚最重要了,尽早回滚
 		//It is not the start party to roll back the transaction, which may cause inaccurate log records,but rollback is the most important, roll back as soon as possible
 		rbErr := dbConn.rollback()
 		if rbErr != nil {
 			LogErr("Transaction-->rollback事务回滚失败 " + rbErr.Error())
 		}
-		return info, LogErr("Transaction-->doTransaction业务执行异常: " + err.Error())
+		dbConn.afterRollback(ctx)
+		return info, LogErr("Transaction-->doTransaction业务执行异常: " + err.Error()), err
+	}
+	//嵌套事务执行成功,释放savepoint
+	//The nested transaction is executed successfully, release the savepoint
+	if savepointName != "" {
+		releaseErr := dbConn.releaseSavepoint(ctx, savepointName)
+		if releaseErr != nil {
+			return info, errors.New("Transaction-->releaseSavepoint释放savepoint失败 " + releaseErr.Error()), releaseErr
+		}
 	}
 	//如果是事务开启方,提交事务
 	//If it is the transaction opener, commit the transaction
 	if localTxOpen {
+		//嵌套事务panic了,回滚整个事务
+		//The nested transaction panicked, roll back the entire transaction
+		if dbConn.rollbackOnly != nil {
+			cause = dbConn.rollbackOnly
+			dbConn.rollbackOnly = nil
+			if rbErr := dbConn.rollback(); rbErr != nil {
+				LogErr("Transaction-->rollback事务回滚失败 " + rbErr.Error())
+			}
+			dbConn.afterRollback(ctx)
+			return info, errors.New("Transaction-->嵌套事务panic,回滚事务: " + cause.Error()), cause
+		}
 		commitError := dbConn.commit()
 		if commitError != nil {
-			return info, LogErr("Transaction-->commit事务提交失败 " + commitError.Error())
+			//提交失败,事务已经结束,按照回滚处理
+			//The commit failed, the transaction has ended, and it is treated as a rollback
+			dbConn.afterRollback(ctx)
+			return info, LogErr("Transaction-->commit事务提交失败 " + commitError.Error()), commitError
 		}
+		dbConn.afterCommit(ctx)
 	}
 
-	return info, err
+	return info, nil, nil
+}
+
+// OnCommit 注册事务提交成功后执行的函数,必须在grm.Transaction的事务函数内调用
+// 只有事务的开启方(最外层)提交成功后才会执行,如果事务回滚,或者所在的嵌套事务回滚到了savepoint,函数不会执行
+// 一般用于数据持久化之后发布领域事件或者清理缓存.函数按照注册的顺序执行,panic会被记录,不影响其他函数
+// OnCommit Register the function executed after the transaction is committed successfully, it must be called in the transaction function of grm.Transaction
+// It will only be executed after the opener (outermost) of the transaction is committed successfully.
+// If the transaction is rolled back, or the nested transaction is rolled back to the savepoint, the function will not be executed
+// Generally used to publish domain events or clear the cache after the data is durable. The functions are executed in the order of registration
+func OnCommit(ctx context.Context, fn func(ctx context.Context)) error {
+	dbConn, err := getTxDBConn(ctx, fn)
+	if err != nil {
+		return errors.New("OnCommit-->" + err.Error())
+	}
+	dbConn.commitFuncs = append(dbConn.commitFuncs, fn)
+	return nil
+}
+
+// OnRollback 注册事务回滚后执行的函数,必须在grm.Transaction的事务函数内调用
+// 只有事务的开启方(最外层)回滚后才会执行,嵌套事务回滚到savepoint时不执行.事务提交成功,函数不会执行
+// OnRollback Register the function executed after the transaction is rolled back, it must be called in the transaction function of grm.Transaction
+// It will only be executed after the opener (outermost) of the transaction rolls back, not when the nested transaction is rolled back to the savepoint.
+// If the transaction is committed successfully, the function will not be executed
+func OnRollback(ctx context.Context, fn func(ctx context.Context)) error {
+	dbConn, err := getTxDBConn(ctx, fn)
+	if err != nil {
+		return errors.New("OnRollback-->" + err.Error())
+	}
+	dbConn.rollbackFuncs = append(dbConn.rollbackFuncs, fn)
+	return nil
+}
+
+// getTxDBConn 获取ctx中有事务的dbConn,用于注册事务函数
+// getTxDBConn Get the dbConn with transaction in ctx, used to register transaction functions
+func getTxDBConn(ctx context.Context, fn func(ctx context.Context)) (*dbConnection, error) {
+	if fn == nil {
+		return nil, errors.New("注册的函数不能为nil")
+	}
+	dbConn, err := getDBConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if dbConn == nil || !dbConn.inTx() {
+		return nil, errors.New("ctx中没有事务,请在grm.Transaction的事务函数内调用")
+	}
+	return dbConn, nil
 }
 
 // QueryRow 不要偷懒调用Query返回第一条,问题1.需要构建一个slice,问题2.调用方传递的对象其他值会被抛弃或者覆盖.
@@ -272,39 +711,30 @@ func QueryRow(ctx context.Context, finder *Finder, entity interface{}) (bool, er
 	if checkErr != nil {
 		return false, LogErr("QueryRow-->checkEntityKind类型检查错误 " + checkErr.Error())
 	}
-	//从context中获取数据库连接,可能为nil
-	//Get database connection from context, may be nil
-	dbConn, errFromCtx := getDBConn(ctx)
-	if errFromCtx != nil {
-		return false, errFromCtx
-	}
-	//自己构建的dbConn
-	//dbConn built by yourself
-	if dbConn != nil && dbConn.db == nil {
-		return false, errDBConn
+	//检查dbConn,ctx中没有dbConn时只调用一次读写分离策略,生成语句和查询使用同一个数据库
+	//Check dbConn, when there is no dbConn in ctx, the read-write strategy is called only once,
+	//and the same database is used to generate the statement and query
+	ctx, dbConn, err := checkDBConn(ctx, false, 0)
+	if err != nil {
+		return false, err
 	}
 
-	var drv string = ""
-	//dbConn为nil,使用defaultDao
-	//dbConn is nil, use default Dao
-	if dbConn == nil {
-		drv = FuncReadWriteStrategy(0).config.Driver
-	} else {
-		drv = dbConn.cfg.Driver
+	//默认排除软删除的数据
+	//Exclude soft deleted data by default
+	finder, err = softDeleteQueryFinder(finder, typeOf)
+	if err != nil {
+		return false, err
 	}
 
 	//获取到sql语句
 	//Get the sql statement
-	sqlStr, err := wrapQuerySQL(drv, finder, nil)
+	sqlStr, err := wrapQuerySQL(dbConn.cfg, finder, nil)
 	if err != nil {
 		return false, LogErr("QueryRow-->wrapQuerySQL: " + err.Error())
 	}
 
-	//检查dbConn.有可能会创建dbConn或者开启事务,所以要尽可能的接近执行时检查
-	//Check db Connection. It is possible to create a db Connection or start a transaction, so check it as close as possible to the execution
-	ctx, dbConn, err = checkDBConn(ctx, false, 0)
-	if err != nil {
-		return false, err
+	if err = checkTenantFinder(ctx, dbConn, 0, finder); err != nil {
+		return false, errors.New("QueryRow-->checkTenantFinder多租户检查错误: " + err.Error())
 	}
 
 	//根据语句和参数查询
@@ -433,6 +863,14 @@ func QueryRow(ctx context.Context, finder *Finder, entity interface{}) (bool, er
 		}
 	}
 
+	//查询结果读取完之后调用AfterFind钩子
+	//Call the AfterFind hook after the query result is read
+	if has {
+		if err = callAfterFind(ctx, entity); err != nil {
+			return has, err
+		}
+	}
+
 	return has, nil
 }
 
@@ -477,35 +915,28 @@ func Query(ctx context.Context, finder *Finder, rowsSlicePtr interface{}, page *
 	//if !(sliceElementType.Kind() == reflect.Struct || allowBaseTypeMap[sliceElementType.Kind()]) {
 	//	return errors.New("Query数组必须是*[]struct类型或者*[]*struct或者基础类型数组的指针")
 	//}
-	//从context中获取数据库连接,可能为nil
-	//Get database connection from context, may be nil
-	dbConn, errFromCtx := getDBConn(ctx)
-	if errFromCtx != nil {
-		return errFromCtx
-	}
-	//自己构建的dbConn
-	//dbConn built by yourself
-	if dbConn != nil && dbConn.db == nil {
-		return errDBConn
+	//检查dbConn,ctx中没有dbConn时只调用一次读写分离策略,生成语句和查询使用同一个数据库
+	//Check dbConn, when there is no dbConn in ctx, the read-write strategy is called only once,
+	//and the same database is used to generate the statement and query
+	ctx, dbConn, err := checkDBConn(ctx, false, 0)
+	if err != nil {
+		return err
 	}
 
-	var drv string = ""
-	if dbConn == nil { //dbConn为nil,使用defaultDao
-		drv = FuncReadWriteStrategy(0).config.Driver
-	} else {
-		drv = dbConn.cfg.Driver
+	//默认排除软删除的数据
+	//Exclude soft deleted data by default
+	finder, err = softDeleteQueryFinder(finder, sliceElementType)
+	if err != nil {
+		return err
 	}
 
-	sqlStr, err := wrapQuerySQL(drv, finder, page)
+	sqlStr, err := wrapQuerySQL(dbConn.cfg, finder, page)
 	if err != nil {
 		return LogErr("Query-->wrapQuerySQL获取查询SQL语句错误: " + err.Error())
 	}
 
-	//检查dbConn.有可能会创建dbConn或者开启事务,所以要尽可能的接近执行时检查
-	//Check db Connection. It is possible to create a db Connection or start a transaction, so check it as close as possible to the execution
-	ctx, dbConn, err = checkDBConn(ctx, false, 0)
-	if err != nil {
-		return err
+	if err = checkTenantFinder(ctx, dbConn, 0, finder); err != nil {
+		return errors.New("Query-->checkTenantFinder多租户检查错误: " + err.Error())
 	}
 
 	//根据语句和参数查询
@@ -528,10 +959,14 @@ func Query(ctx context.Context, finder *Finder, rowsSlicePtr interface{}, page *
 
 	cdvMapHasBool := len(CustomDriverValueMap) > 0
 
+	//旧版本数据库的分页语句会多返回行号列,例如oracle 11g
+	//The paging statement of the old version database returns an extra row number column, such as oracle 11g
+	hasPageRowNumber := len(columnTypes) == 2 && strings.EqualFold(columnTypes[1].Name(), pageRowNumberColumn)
+
 	//如果是基础类型,就查询一个字段
 	//If it is a basic type, query a field
 	//if allowBaseTypeMap[sliceElementType.Kind()] {
-	if len(columnTypes) == 1 {
+	if len(columnTypes) == 1 || hasPageRowNumber {
 
 		//循环遍历结果集
 		//Loop through the result set
@@ -577,7 +1012,12 @@ func Query(ctx context.Context, finder *Finder, rowsSlicePtr interface{}, page *
 
 			//把数据库值赋给指针
 			//Assign database value to pointer
-			scanErr := rows.Scan(pv.Interface())
+			var scanErr error
+			if hasPageRowNumber {
+				scanErr = rows.Scan(pv.Interface(), new(interface{}))
+			} else {
+				scanErr = rows.Scan(pv.Interface())
+			}
 
 			if scanErr != nil {
 				return LogErr("Query-->rows.Scan异常 " + scanErr.Error())
@@ -622,6 +1062,10 @@ func Query(ctx context.Context, finder *Finder, rowsSlicePtr interface{}, page *
 		return LogErr("Query-->getDBColumnFieldMap获取字段缓存错误 " + dbe.Error())
 	}
 
+	//调用AfterFind钩子的开始位置,此方法只Append元素
+	//The start position to call the AfterFind hook, this method only appends elements
+	afterFindStart := sliceValue.Len()
+
 	//循环遍历结果集
 	//Loop through the result set
 	for rows.Next() {
@@ -649,6 +1093,12 @@ func Query(ctx context.Context, finder *Finder, rowsSlicePtr interface{}, page *
 		}
 	}
 
+	//查询结果读取完之后调用AfterFind钩子
+	//Call the AfterFind hook after the query result is read
+	if err = callSliceAfterFind(ctx, sliceValue, afterFindStart); err != nil {
+		return err
+	}
+
 	//查询总条数
 	//Query total number
 	if page != nil && finder.SelectTotalCount {
@@ -695,37 +1145,21 @@ func QueryMap(ctx context.Context, finder *Finder, page *Page) ([]map[string]int
 	if finder == nil {
 		return nil, errors.New("QueryMap-->finder参数不能为nil")
 	}
-	//从context中获取数据库连接,可能为nil
-	//Get database connection from context, may be nil
-	dbConn, errFromCtx := getDBConn(ctx)
-	if errFromCtx != nil {
-		return nil, errFromCtx
-	}
-	//自己构建的dbConn
-	//dbConn built by yourself
-	if dbConn != nil && dbConn.db == nil {
-		return nil, errDBConn
-	}
-
-	var drv string = ""
-	//dbConn为nil,使用defaultDao
-	//db Connection is nil, use default Dao
-	if dbConn == nil {
-		drv = FuncReadWriteStrategy(0).config.Driver
-	} else {
-		drv = dbConn.cfg.Driver
+	//检查dbConn,ctx中没有dbConn时只调用一次读写分离策略,生成语句和查询使用同一个数据库
+	//Check dbConn, when there is no dbConn in ctx, the read-write strategy is called only once,
+	//and the same database is used to generate the statement and query
+	ctx, dbConn, err := checkDBConn(ctx, false, 0)
+	if err != nil {
+		return nil, err
 	}
 
-	sqlStr, err := wrapQuerySQL(drv, finder, page)
+	sqlStr, err := wrapQuerySQL(dbConn.cfg, finder, page)
 	if err != nil {
 		return nil, LogErr("QueryMap -->wrapQuerySQL查询SQL语句错误: " + err.Error())
 	}
 
-	//检查dbConn.有可能会创建dbConn或者开启事务,所以要尽可能的接近执行时检查
-	//Check db Connection. It is possible to create a db Connection or start a transaction, so check it as close as possible to the execution
-	ctx, dbConn, err = checkDBConn(ctx, false, 0)
-	if err != nil {
-		return nil, err
+	if err = checkTenantFinder(ctx, dbConn, 0, finder); err != nil {
+		return nil, errors.New("QueryMap-->checkTenantFinder多租户检查错误: " + err.Error())
 	}
 
 	//根据语句和参数查询
@@ -831,6 +1265,11 @@ func QueryMap(ctx context.Context, finder *Finder, page *Page) ([]map[string]int
 		//获取每一列的值
 		//Get the value of each column
 		for i, columnType := range columnTypes {
+			//忽略旧版本数据库分页语句的行号列
+			//Ignore the row number column of the paging statement of the old version database
+			if strings.EqualFold(columnType.Name(), pageRowNumberColumn) {
+				continue
+			}
 
 			//取到指针下的值,[]byte格式
 			//Get the value under the pointer, []byte format
@@ -869,6 +1308,9 @@ func QueryMap(ctx context.Context, finder *Finder, page *Page) ([]map[string]int
 // ctx cannot be nil, refer to grm.Transaction method to pass in ctx. Don't build DB Connection yourself
 // The number of rows affected by affected, if it is abnormal or the driver does not support it, return-1
 func UpdateFinder(ctx context.Context, finder *Finder) (int, error) {
+	if err := checkWriteCtx(ctx); err != nil {
+		return -1, err
+	}
 	affected := -1
 	if finder == nil {
 		return affected, errors.New("UpdateFinder-->finder不能为空")
@@ -891,16 +1333,20 @@ func UpdateFinder(ctx context.Context, finder *Finder) (int, error) {
 		return affected, errDBConn
 	}
 
-	var drv string = ""
+	var config *DBConfig
 	//dbConn为nil,使用defaultDao
 	//dbConn is nil, use default Dao
 	if dbConn == nil {
-		drv = FuncReadWriteStrategy(1).config.Driver
+		config = FuncReadWriteStrategy(1).config
 	} else {
-		drv = dbConn.cfg.Driver
+		config = dbConn.cfg
+	}
+
+	if err = checkTenantFinder(ctx, dbConn, 1, finder); err != nil {
+		return affected, errors.New("UpdateFinder-->checkTenantFinder多租户检查错误: " + err.Error())
 	}
 
-	sqlStr, err = reBindSQL(drv, sqlStr)
+	sqlStr, err = reBindSQL(config, sqlStr)
 	if err != nil {
 		return affected, LogErr("UpdateFinder-->reBindSQL获取SQL语句错误: " + err.Error())
 	}
@@ -921,16 +1367,25 @@ func UpdateFinder(ctx context.Context, finder *Finder) (int, error) {
 // ctx cannot be nil, refer to grm.Transaction method to pass in ctx. Don't build dbConn yourself
 // The number of rows affected by affected, if it is abnormal or the driver does not support it, return -1
 func Insert(ctx context.Context, entity IEntityStruct) (int, error) {
-	affected := -1
-	if entity == nil {
-		return affected, errors.New("对象不能为空")
+	if err := checkWriteCtx(ctx); err != nil {
+		return -1, err
 	}
-	typeOf, columns, values, err := columnAndValue(entity)
+	if err := callBeforeInsert(ctx, entity); err != nil {
+		return -1, err
+	}
+	affected, err := insertStructFunc(ctx, entity)
 	if err != nil {
-		return affected, LogErr("Insert-->columnAndValue获取实体类的列和值异常: " + err.Error())
+		return affected, err
 	}
-	if len(columns) < 1 {
-		return affected, errors.New("no tag info")
+	return affected, callAfterInsert(ctx, entity)
+}
+
+// insertStructFunc 保存Struct对象,不调用钩子
+// insertStructFunc Save the Struct object without calling hooks
+func insertStructFunc(ctx context.Context, entity IEntityStruct) (int, error) {
+	affected := -1
+	if entity == nil {
+		return affected, errors.New("对象不能为空")
 	}
 	//Get database connection from context, may be nil
 	dbConn, errFromCtx := getDBConn(ctx)
@@ -942,17 +1397,44 @@ func Insert(ctx context.Context, entity IEntityStruct) (int, error) {
 	if dbConn != nil && dbConn.db == nil {
 		return affected, errDBConn
 	}
+	//多租户,设置租户字段的值
+	//Multi-tenant, set the value of the tenant column
+	tenant, err := structTenantCondition(ctx, dbConn, entity)
+	if err != nil {
+		return affected, errors.New("Insert-->structTenantCondition获取租户条件错误: " + err.Error())
+	}
+	if tenant != nil {
+		if err = setStructTenant(entity, tenant); err != nil {
+			return affected, errors.New("Insert-->setStructTenant设置租户字段错误: " + err.Error())
+		}
+	}
+	//自动填充创建时间和更新时间
+	//Auto-fill create time and update time
+	if err = setStructAutoTime(entity, autoTimeNow(getDBConfig(ctx, dbConn, 1)), true); err != nil {
+		return affected, errors.New("Insert-->setStructAutoTime自动填充时间错误: " + err.Error())
+	}
+	typeOf, columns, values, err := columnAndValue(entity)
+	if err != nil {
+		return affected, LogErr("Insert-->columnAndValue获取实体类的列和值异常: " + err.Error())
+	}
+	if len(columns) < 1 {
+		return affected, errors.New("no tag info")
+	}
 
-	var drv string = ""
+	var config *DBConfig
 	//dbConn为nil,使用defaultDao
 	//dbConn is nil, use default Dao
 	if dbConn == nil {
-		drv = FuncReadWriteStrategy(1).config.Driver
+		config = FuncReadWriteStrategy(1).config
 	} else {
-		drv = dbConn.cfg.Driver
+		config = dbConn.cfg
 	}
 
-	sqlStr, autoIncrement, pkType, err := wrapInsertSQL(drv, &typeOf, entity, &columns, &values)
+	tableName, err := FuncTableNameResolver(ctx, entity)
+	if err != nil {
+		return affected, errors.New("Insert-->FuncTableNameResolver获取表名错误: " + err.Error())
+	}
+	sqlStr, autoIncrement, pkType, err := wrapInsertSQL(config, tableName, &typeOf, entity, &columns, &values)
 	if err != nil {
 		return affected, LogErr("Insert-->wrapInsertSQL获取保存语句错误: " + err.Error())
 	}
@@ -961,18 +1443,11 @@ func Insert(ctx context.Context, entity IEntityStruct) (int, error) {
 	//处理序列产生的自增主键,例如oracle,postgresql等
 	var lastInsertID *int64
 	var grmSQLOutReturningID *int64
-	//如果是postgresql的SERIAL自增,需要使用 RETURNING 返回主键的值
+	//根据方言获取自增主键,例如postgresql的SERIAL自增,需要使用 RETURNING 返回主键的值
 	if autoIncrement > 0 {
-		if drv == "postgresql" {
-			var p int64 = 0
-			lastInsertID = &p
-			sqlStr = sqlStr + " RETURNING " + entity.PK()
-		} else if drv == "oracle" {
-			var p int64 = 0
-			grmSQLOutReturningID = &p
-			sqlStr = sqlStr + " RETURNING " + entity.PK() + " INTO :grmSQLOutReturningID "
-			v := sql.Named("grmSQLOutReturningID", sql.Out{Dest: grmSQLOutReturningID})
-			values = append(values, v)
+		lastInsertID, grmSQLOutReturningID, err = wrapReturningID(config, entity.PK(), &sqlStr, &values)
+		if err != nil {
+			return affected, errors.New("Insert-->wrapReturningID获取自增主键语句错误: " + err.Error())
 		}
 	}
 
@@ -1033,18 +1508,17 @@ func Insert(ctx context.Context, entity IEntityStruct) (int, error) {
 //ctx不能为nil,参照使用grm.Transaction方法传入ctx.也不要自己构建DBConnection
 //affected影响的行数,如果异常或者驱动不支持,返回-1
 func InsertSlice(ctx context.Context, entityStructSlice []IEntityStruct) (int, error) {
+	if err := checkWriteCtx(ctx); err != nil {
+		return -1, err
+	}
 	affected := -1
 	if entityStructSlice == nil || len(entityStructSlice) < 1 {
 		return affected, errors.New("InsertSlice对象数组不能为空")
 	}
-	//第一个对象,获取第一个Struct对象,用于获取数据库字段,也获取了值
-	entity := entityStructSlice[0]
-	typeOf, columns, values, err := columnAndValue(entity)
-	if err != nil {
-		return affected, LogErr("InsertSlice-->columnAndValue获取实体类的列和值异常 " + err.Error())
-	}
-	if len(columns) < 1 {
-		return affected, errors.New("InsertSlice没有tag信息,请检查struct中 column 的tag")
+	for _, entityStruct := range entityStructSlice {
+		if err := callBeforeInsert(ctx, entityStruct); err != nil {
+			return affected, err
+		}
 	}
 	//从context中获取数据库连接,可能为nil
 	dbConn, err := getDBConn(ctx)
@@ -1055,16 +1529,61 @@ func InsertSlice(ctx context.Context, entityStructSlice []IEntityStruct) (int, e
 	if dbConn != nil && dbConn.db == nil {
 		return affected, errDBConn
 	}
+	//多租户,设置所有对象租户字段的值
+	//Multi-tenant, set the value of the tenant column of all objects
+	tenant, err := structTenantCondition(ctx, dbConn, entityStructSlice[0])
+	if err != nil {
+		return affected, errors.New("InsertSlice-->structTenantCondition获取租户条件错误: " + err.Error())
+	}
+	if tenant != nil {
+		for _, entityStruct := range entityStructSlice {
+			if err = setStructTenant(entityStruct, tenant); err != nil {
+				return affected, errors.New("InsertSlice-->setStructTenant设置租户字段错误: " + err.Error())
+			}
+		}
+	}
+	//自动填充创建时间和更新时间,所有对象使用相同的时间
+	//Auto-fill create time and update time, all objects use the same time
+	now := autoTimeNow(getDBConfig(ctx, dbConn, 1))
+	for _, entityStruct := range entityStructSlice {
+		if err = setStructAutoTime(entityStruct, now, true); err != nil {
+			return affected, errors.New("InsertSlice-->setStructAutoTime自动填充时间错误: " + err.Error())
+		}
+	}
+	//第一个对象,获取第一个Struct对象,用于获取数据库字段,也获取了值
+	entity := entityStructSlice[0]
+	typeOf, columns, values, err := columnAndValue(entity)
+	if err != nil {
+		return affected, LogErr("InsertSlice-->columnAndValue获取实体类的列和值异常 " + err.Error())
+	}
+	if len(columns) < 1 {
+		return affected, errors.New("InsertSlice没有tag信息,请检查struct中 column 的tag")
+	}
 
-	var drv string = ""
+	var config *DBConfig
 	if dbConn == nil { //dbConn为nil,使用defaultDao
-		drv = FuncReadWriteStrategy(1).config.Driver
+		config = FuncReadWriteStrategy(1).config
 	} else {
-		drv = dbConn.cfg.Driver
+		config = dbConn.cfg
 	}
 
+	//一条语句只能保存到一个表,每个对象的表名必须一致,例如按月分表时不能跨月批量保存
+	//One statement can only be saved to one table, the table name of each object must be the same, for example, it cannot be saved across months when tables are split by month
+	tableName, err := FuncTableNameResolver(ctx, entityStructSlice[0])
+	if err != nil {
+		return affected, errors.New("InsertSlice-->FuncTableNameResolver获取表名错误: " + err.Error())
+	}
+	for i := 1; i < len(entityStructSlice); i++ {
+		name, err := FuncTableNameResolver(ctx, entityStructSlice[i])
+		if err != nil {
+			return affected, errors.New("InsertSlice-->FuncTableNameResolver获取表名错误: " + err.Error())
+		}
+		if name != tableName {
+			return affected, errors.New("InsertSlice-->对象的表名不一致,不能批量保存:" + tableName + "," + name)
+		}
+	}
 	//SQL语句
-	sqlStr, _, err := wrapInsertSliceSQL(drv, &typeOf, entityStructSlice, &columns, &values)
+	sqlStr, _, err := wrapInsertSliceSQL(config, tableName, &typeOf, entityStructSlice, &columns, &values)
 	if err != nil {
 		return affected, LogErr("InsertSlice-->wrapInsertSliceSQL获取保存语句错误: " + err.Error())
 	}
@@ -1072,49 +1591,119 @@ func InsertSlice(ctx context.Context, entityStructSlice []IEntityStruct) (int, e
 	_, err = wrapExecUpdateValuesAffected(ctx, &affected, &sqlStr, values, nil)
 	if err != nil {
 		LogErr("InsertSlice-->wrapExecUpdateValuesAffected执行保存错误 " + err.Error())
+		return affected, err
+	}
+	for _, entityStruct := range entityStructSlice {
+		if err = callAfterInsert(ctx, entityStruct); err != nil {
+			return affected, err
+		}
 	}
 
-	return affected, err
+	return affected, nil
 }
 
 //Update 更新struct所有属性,必须是IEntityStruct类型
+//有 grm:"version" 的字段时使用乐观锁,版本号不一致返回ErrStaleEntity,更新成功后entity的版本号加1
 //ctx不能为nil,参照使用grm.Transaction方法传入ctx.也不要自己构建DBConnection
 func Update(ctx context.Context, entity IEntityStruct) (int, error) {
+	if err := checkWriteCtx(ctx); err != nil {
+		return -1, err
+	}
+	if err := callBeforeUpdate(ctx, entity); err != nil {
+		return -1, err
+	}
 	affected, err := updateStructFunc(ctx, entity, false)
+	if err == ErrStaleEntity {
+		return affected, err
+	}
 	if err != nil {
 		return affected, errors.New("Update-->updateStructFunc更新错误: " + err.Error())
 	}
-	return affected, nil
+	return affected, callAfterUpdate(ctx, entity)
 }
 
 //UpdateNotZeroValue 更新struct不为默认零值的属性,必须是IEntityStruct类型,主键必须有值
+//乐观锁和Update一样,参见Update
 //ctx不能为nil,参照使用grm.Transaction方法传入ctx.也不要自己构建DBConnection
 func UpdateNotZeroValue(ctx context.Context, entity IEntityStruct) (int, error) {
+	if err := checkWriteCtx(ctx); err != nil {
+		return -1, err
+	}
+	if err := callBeforeUpdate(ctx, entity); err != nil {
+		return -1, err
+	}
 	affected, err := updateStructFunc(ctx, entity, true)
+	if err == ErrStaleEntity {
+		return affected, err
+	}
 	if err != nil {
 		return affected, errors.New("UpdateNotZeroValue-->updateStructFunc更新错误: " + err.Error())
 	}
-	return affected, nil
+	return affected, callAfterUpdate(ctx, entity)
 }
 
 //Delete 根据主键删除一个对象.必须是IEntityStruct类型
+//如果有 grm:"softDelete" 的字段,是软删除,更新软删除字段的值,不删除数据,参见HardDelete和Restore
 //ctx不能为nil,参照使用grm.Transaction方法传入ctx.也不要自己构建DBConnection
 //affected影响的行数,如果异常或者驱动不支持,返回-1
 func Delete(ctx context.Context, entity IEntityStruct) (int, error) {
-	affected := -1
+	if err := checkWriteCtx(ctx); err != nil {
+		return -1, err
+	}
 	typeOf, err := checkEntityKind(entity)
+	if err != nil {
+		return -1, err
+	}
+	softDeleteField, hasSoftDelete, err := getGrmTagField(&typeOf, tagSoftDelete)
+	if err != nil {
+		return -1, errors.New("Delete-->getGrmTagField获取软删除字段错误 " + err.Error())
+	}
+	if err = callBeforeDelete(ctx, entity); err != nil {
+		return -1, err
+	}
+	var affected int
+	if hasSoftDelete {
+		affected, err = softDeleteStructFunc(ctx, entity, &softDeleteField, false)
+	} else {
+		affected, err = hardDeleteStructFunc(ctx, entity)
+	}
+	if err != nil {
+		return affected, err
+	}
+	return affected, callAfterDelete(ctx, entity)
+}
+
+//HardDelete 根据主键物理删除一个对象,忽略 grm:"softDelete" 的字段.必须是IEntityStruct类型
+//ctx不能为nil,参照使用grm.Transaction方法传入ctx.也不要自己构建DBConnection
+//affected影响的行数,如果异常或者驱动不支持,返回-1
+func HardDelete(ctx context.Context, entity IEntityStruct) (int, error) {
+	if err := checkWriteCtx(ctx); err != nil {
+		return -1, err
+	}
+	if err := callBeforeDelete(ctx, entity); err != nil {
+		return -1, err
+	}
+	affected, err := hardDeleteStructFunc(ctx, entity)
 	if err != nil {
 		return affected, err
 	}
+	return affected, callAfterDelete(ctx, entity)
+}
 
-	pkName, err := entityPKFieldName(entity, &typeOf)
+//hardDeleteStructFunc 根据主键物理删除一个对象,不调用钩子
+//hardDeleteStructFunc Physically delete an object according to the primary key without calling hooks
+func hardDeleteStructFunc(ctx context.Context, entity IEntityStruct) (int, error) {
+	affected := -1
+	typeOf, err := checkEntityKind(entity)
 	if err != nil {
-		return affected, LogErr("Delete-->entityPKFieldName获取主键名称错误 " + err.Error())
+		return affected, err
 	}
 
-	value, err := structFieldValue(entity, pkName)
+	//主键的值,支持联合主键
+	//The values of the primary key, composite primary keys are supported
+	pkValues, err := entityPKValues(entity, &typeOf)
 	if err != nil {
-		return affected, LogErr("Delete-->structFieldValue获取主键值错误 " + err.Error())
+		return affected, errors.New("HardDelete-->entityPKValues获取主键值错误 " + err.Error())
 	}
 	//从context中获取数据库连接,可能为nil
 	dbConn, err := getDBConn(ctx)
@@ -1126,24 +1715,34 @@ func Delete(ctx context.Context, entity IEntityStruct) (int, error) {
 		return affected, errDBConn
 	}
 
-	var drv string = ""
+	var config *DBConfig
 	if dbConn == nil { //dbConn为nil,使用defaultDao
-		drv = FuncReadWriteStrategy(1).config.Driver
+		config = FuncReadWriteStrategy(1).config
 	} else {
-		drv = dbConn.cfg.Driver
+		config = dbConn.cfg
 	}
 
+	tableName, err := FuncTableNameResolver(ctx, entity)
+	if err != nil {
+		return affected, errors.New("HardDelete-->FuncTableNameResolver获取表名错误: " + err.Error())
+	}
+	tenant, err := structTenantCondition(ctx, dbConn, entity)
+	if err != nil {
+		return affected, errors.New("HardDelete-->structTenantCondition获取租户条件错误: " + err.Error())
+	}
 	//SQL语句
-	sqlStr, err := wrapDeleteSQL(drv, entity)
+	sqlStr, err := wrapDeleteSQL(config, tableName, entity, tenant)
 	if err != nil {
-		return affected, LogErr("Delete-->wrapDeleteSQL获取SQL语句错误: " + err.Error())
+		return affected, LogErr("HardDelete-->wrapDeleteSQL获取SQL语句错误: " + err.Error())
 	}
 	//包装update执行,赋值给影响的函数指针变量,返回*sql.Result
-	values := make([]interface{}, 1)
-	values[0] = value
+	values := pkValues
+	if tenant != nil {
+		values = append(values, tenant.value)
+	}
 	_, execErr := wrapExecUpdateValuesAffected(ctx, &affected, &sqlStr, values, nil)
 	if execErr != nil {
-		LogErr("Delete-->wrapExecUpdateValuesAffected执行删除错误 " + execErr.Error())
+		LogErr("HardDelete-->wrapExecUpdateValuesAffected执行删除错误 " + execErr.Error())
 	}
 
 	return affected, execErr
@@ -1153,6 +1752,9 @@ func Delete(ctx context.Context, entity IEntityStruct) (int, error) {
 //ctx不能为nil,参照使用grm.Transaction方法传入ctx.也不要自己构建DBConnection
 //affected影响的行数,如果异常或者驱动不支持,返回-1
 func InsertEntityMap(ctx context.Context, entity IEntityMap) (int, error) {
+	if err := checkWriteCtx(ctx); err != nil {
+		return -1, err
+	}
 	affected := -1
 	//检查是否是指针对象
 	_, checkErr := checkEntityKind(entity)
@@ -1171,15 +1773,31 @@ func InsertEntityMap(ctx context.Context, entity IEntityMap) (int, error) {
 		return affected, errDBConn
 	}
 
-	var drv string = ""
+	var config *DBConfig
 	if dbConn == nil { //dbConn为nil,使用defaultDao
-		drv = FuncReadWriteStrategy(1).config.Driver
+		config = FuncReadWriteStrategy(1).config
 	} else {
-		drv = dbConn.cfg.Driver
+		config = dbConn.cfg
 	}
 
+	//多租户,设置租户字段为ctx绑定的租户ID
+	//Multi-tenant, set the tenant column to the tenant ID bound to ctx
+	tenant, err := mapTenantCondition(ctx, dbConn, entity)
+	if err != nil {
+		return affected, errors.New("InsertEntityMap-->mapTenantCondition获取租户条件错误: " + err.Error())
+	}
+	if tenant != nil {
+		entity.Set(tenant.column, tenant.value)
+	}
+	//自动填充创建时间和更新时间
+	//Auto-fill create time and update time
+	setMapAutoTime(entity, autoTimeNow(getDBConfig(ctx, dbConn, 1)), true)
+	tableName, err := FuncTableNameResolver(ctx, entity)
+	if err != nil {
+		return affected, errors.New("InsertEntityMap-->FuncTableNameResolver获取表名错误: " + err.Error())
+	}
 	//SQL语句
-	sqlStr, values, autoIncrement, err := wrapInsertEntityMapSQL(drv, entity)
+	sqlStr, values, autoIncrement, err := wrapInsertEntityMapSQL(config, tableName, entity)
 	if err != nil {
 		return affected, LogErr("InsertEntityMap-->wrapInsertEntityMapSQL获取SQL语句错误: " + err.Error())
 	}
@@ -1187,18 +1805,11 @@ func InsertEntityMap(ctx context.Context, entity IEntityMap) (int, error) {
 	//处理序列产生的自增主键,例如oracle,postgresql等
 	var lastInsertID *int64
 	var grmSQLOutReturningID *int64
-	//如果是postgresql的SERIAL自增,需要使用 RETURNING 返回主键的值
+	//根据方言获取自增主键,例如postgresql的SERIAL自增,需要使用 RETURNING 返回主键的值
 	if autoIncrement && entity.PK() != "" {
-		if drv == "postgresql" {
-			var p int64 = 0
-			lastInsertID = &p
-			sqlStr = sqlStr + " RETURNING " + entity.PK()
-		} else if drv == "oracle" {
-			var p int64 = 0
-			grmSQLOutReturningID = &p
-			sqlStr = sqlStr + " RETURNING " + entity.PK() + " INTO :grmSQLOutReturningID "
-			v := sql.Named("grmSQLOutReturningID", sql.Out{Dest: grmSQLOutReturningID})
-			values = append(values, v)
+		lastInsertID, grmSQLOutReturningID, err = wrapReturningID(config, entity.PK(), &sqlStr, &values)
+		if err != nil {
+			return affected, errors.New("InsertEntityMap-->wrapReturningID获取自增主键语句错误: " + err.Error())
 		}
 	}
 
@@ -1245,6 +1856,9 @@ func InsertEntityMap(ctx context.Context, entity IEntityMap) (int, error) {
 // ctx cannot be nil, refer to grm.Transaction method to pass in ctx. Don't build DB Connection yourself
 // The number of rows affected by "affected", if it is abnormal or the driver does not support it, return -1
 func UpdateEntityMap(ctx context.Context, entity IEntityMap) (int, error) {
+	if err := checkWriteCtx(ctx); err != nil {
+		return -1, err
+	}
 	affected := -1
 	//检查是否是指针对象
 	//Check if it is a pointer
@@ -1264,18 +1878,29 @@ func UpdateEntityMap(ctx context.Context, entity IEntityMap) (int, error) {
 		return affected, errDBConn
 	}
 
-	var drv string = ""
+	var config *DBConfig
 	//dbConn为nil,使用defaultDao
 	//dbConn is nil, use default Dao
 	if dbConn == nil {
-		drv = FuncReadWriteStrategy(1).config.Driver
+		config = FuncReadWriteStrategy(1).config
 	} else {
-		drv = dbConn.cfg.Driver
+		config = dbConn.cfg
 	}
 
 	//SQL语句
 	//SQL statement
-	sqlStr, values, err := wrapUpdateEntityMapSQL(drv, entity)
+	tableName, err := FuncTableNameResolver(ctx, entity)
+	if err != nil {
+		return affected, errors.New("UpdateEntityMap-->FuncTableNameResolver获取表名错误: " + err.Error())
+	}
+	tenant, err := mapTenantCondition(ctx, dbConn, entity)
+	if err != nil {
+		return affected, errors.New("UpdateEntityMap-->mapTenantCondition获取租户条件错误: " + err.Error())
+	}
+	//自动填充更新时间
+	//Auto-fill update time
+	setMapAutoTime(entity, autoTimeNow(getDBConfig(ctx, dbConn, 1)), false)
+	sqlStr, values, err := wrapUpdateEntityMapSQL(config, tableName, entity, tenant)
 	if err != nil {
 		return affected, LogErr("UpdateEntityMap-->wrapUpdateEntityMapSQL获取SQL语句错误: " + err.Error())
 	}
@@ -1311,22 +1936,35 @@ func updateStructFunc(ctx context.Context, entity IEntityStruct, onlyUpdateNotZe
 		return affected, errDBConn
 	}
 
-	var drv string
+	var config *DBConfig
 	//dbConn is nil, use default Dao
 	if dbConn == nil {
-		drv = FuncReadWriteStrategy(1).config.Driver
+		config = FuncReadWriteStrategy(1).config
 	} else {
-		drv = dbConn.cfg.Driver
+		config = dbConn.cfg
 	}
 
+	//自动填充更新时间
+	//Auto-fill update time
+	if err := setStructAutoTime(entity, autoTimeNow(getDBConfig(ctx, dbConn, 1)), false); err != nil {
+		return affected, errors.New("updateStructFunc-->setStructAutoTime自动填充时间错误: " + err.Error())
+	}
 	typeOf, columns, values, columnAndValueErr := columnAndValue(entity)
 	if columnAndValueErr != nil {
 		return affected, columnAndValueErr
 	}
 
+	tableName, err := FuncTableNameResolver(ctx, entity)
+	if err != nil {
+		return affected, errors.New("updateStructFunc-->FuncTableNameResolver获取表名错误: " + err.Error())
+	}
+	tenant, err := structTenantCondition(ctx, dbConn, entity)
+	if err != nil {
+		return affected, errors.New("updateStructFunc-->structTenantCondition获取租户条件错误: " + err.Error())
+	}
 	//SQL语句
 	//SQL statement
-	sqlStr, err := wrapUpdateSQL(drv, &typeOf, entity, &columns, &values, onlyUpdateNotZero)
+	sqlStr, err := wrapUpdateSQL(config, tableName, &typeOf, entity, &columns, &values, onlyUpdateNotZero, tenant)
 	if err != nil {
 		return affected, err
 	}
@@ -1337,6 +1975,25 @@ func updateStructFunc(ctx context.Context, entity IEntityStruct, onlyUpdateNotZe
 		return affected, LogErr("updateStruct-->wrapExecUpdateValuesAffected执行更新错误 " + execErr.Error())
 	}
 
+	//乐观锁,没有更新到数据说明版本号已经被修改.更新成功后entity的版本号加1
+	//Optimistic lock, no data is updated, indicating that the version has been modified. After the update is successful, the version of entity is incremented by 1
+	versionField, hasVersion, err := getGrmTagField(&typeOf, tagVersion)
+	if err != nil {
+		return affected, err
+	}
+	if hasVersion {
+		if affected == 0 {
+			return affected, ErrStaleEntity
+		}
+		versionValue := reflect.ValueOf(entity).Elem().FieldByName(versionField.Name)
+		switch versionValue.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			versionValue.SetInt(versionValue.Int() + 1)
+		default:
+			versionValue.SetUint(versionValue.Uint() + 1)
+		}
+	}
+
 	return affected, execErr
 }
 
@@ -1352,6 +2009,11 @@ func selectCount(ctx context.Context, finder *Finder) (int, error) {
 	//自定义的查询总条数Finder,主要是为了在group by等复杂情况下,为了性能,手动编写总条数语句
 	//Customized query total number Finder,mainly for the sake of performance in complex situations such as group by, manually write the total number of statements
 	if finder.CountFinder != nil {
+		//跨租户的语句,自定义的总条数语句也是跨租户的
+		//Cross-tenant statement, the custom total number statement is also cross-tenant
+		if finder.CrossTenant {
+			finder.CountFinder.CrossTenant = true
+		}
 		count := -1
 		_, err := QueryRow(ctx, finder.CountFinder, &count)
 		if err != nil {
@@ -1396,6 +2058,8 @@ func selectCount(ctx context.Context, finder *Finder) (int, error) {
 	countFinder := NewFinder()
 	countFinder.Append(countSql)
 	countFinder.values = finder.values
+	countFinder.CrossTenant = finder.CrossTenant
+	countFinder.tenantPredicate = finder.tenantPredicate
 
 	count := -1
 	if _, err := QueryRow(ctx, countFinder, &count); err != nil {
@@ -1425,6 +2089,22 @@ func getDBConn(ctx context.Context) (*dbConnection, error) {
 
 //变量名建议errFoo这样的驼峰
 //The variable name suggests a hump like "errFoo"
+var errReadOnlyTx = errors.New("只读事务内不能执行UpdateFinder,Insert,Update,Delete等更新操作,请使用grm.Transaction开启读写事务")
+
+// checkWriteCtx 更新操作的入口检查,在修改实体和执行钩子之前调用.检查dbConn和事务,只读事务内禁止更新操作
+// checkWriteCtx The entry check of update operations, called before modifying the entity and calling hooks.
+// Check the dbConn and transaction, update operations are prohibited in read-only transactions
+func checkWriteCtx(ctx context.Context) error {
+	_, dbConn, err := checkDBConn(ctx, true, 1)
+	if err != nil {
+		return err
+	}
+	if readOnlyConn, ok := ctx.Value(ctxReadOnlyKey).(*dbConnection); ok && dbConn == readOnlyConn {
+		return errReadOnlyTx
+	}
+	return nil
+}
+
 var errDBConn = errors.New("更新操作需要使用grm.Transaction开启事务.  读取操作如果ctx没有dbConn,使用FuncReadWriteStrategy(rwType).newDBConn(),如果dbConn有事务,就使用事务查询")
 
 // checkDBConn 检查dbConn.有可能会创建dbConn或者开启事务,所以要尽可能的接近执行时检查
@@ -1450,7 +2130,7 @@ func checkDBConn(ctx context.Context, hasTx bool, rwType int) (context.Context,
 
 		//如果要求没有事务,实例化一个默认的dbConn
 		//If no transaction is required, instantiate a default db Connection
-		dbConn, err = FuncReadWriteStrategy(rwType).newDBConn()
+		dbConn, err = getReadWriteDao(ctx, rwType).newDBConn()
 		if err != nil {
 			return ctx, nil, err
 		}
@@ -1463,7 +2143,7 @@ func checkDBConn(ctx context.Context, hasTx bool, rwType int) (context.Context,
 			return ctx, dbConn, errDBConn
 		}
 		//if dbConn.tx == nil && hasTx && (!dbConn.cfg.DisableTransaction) {
-		if dbConn.tx == nil && hasTx { //如果要求有事务,事务需要手动grm.Transaction显示开启.如果自动开启,就会为了偷懒,每个操作都自动开启,事务就失去意义了
+		if !dbConn.inTx() && hasTx { //如果要求有事务,事务需要手动grm.Transaction显示开启.如果自动开启,就会为了偷懒,每个操作都自动开启,事务就失去意义了
 			return ctx, dbConn, errDBConn
 		}
 	}
@@ -1480,9 +2160,8 @@ func wrapExecUpdateValuesAffected(ctx context.Context, affected *int, sqlStrptr
 	if err != nil {
 		return nil, err
 	}
-
 	// 数据库语法兼容处理
-	sqlStr, err := reUpdateFinderSQL(dbConn.cfg.Driver, sqlStrptr)
+	sqlStr, err := reUpdateFinderSQL(dbConn.cfg, sqlStrptr)
 	if err != nil {
 		return nil, LogErr("wrapExecUpdateValuesAffected-->reUpdateFinderSQL获取SQL语句错误 " + err.Error())
 	}
@@ -1492,6 +2171,7 @@ func wrapExecUpdateValuesAffected(ctx context.Context, affected *int, sqlStrptr
 		err = dbConn.queryRowCtx(ctx, sqlStr, values).Scan(lastInsertID)
 		if err == nil { //如果插入成功,返回
 			*affected = 1
+			markWrite(ctx, dbConn)
 			return res, err
 		}
 	} else {
@@ -1501,6 +2181,9 @@ func wrapExecUpdateValuesAffected(ctx context.Context, affected *int, sqlStrptr
 	if err != nil {
 		return res, err
 	}
+	//记录写操作的时间,用于读己之写
+	//Record the time of the write operation for read-your-writes
+	markWrite(ctx, dbConn)
 	//影响的行数
 	//Number of rows affected
 
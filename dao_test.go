@@ -0,0 +1,59 @@
+package grm
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestInsertSliceTableName(t *testing.T) {
+	oldFunc := FuncTableNameResolver
+	defer func() {
+		FuncTableNameResolver = oldFunc
+	}()
+	//按照ID的奇偶分表
+	//Split tables by the parity of the ID
+	FuncTableNameResolver = func(ctx context.Context, entity interface{}) (string, error) {
+		return "t_demo_" + strconv.Itoa(entity.(*txDemo).ID%2), nil
+	}
+	tests := []struct {
+		name    string
+		ids     []int
+		want    string
+		wantErr bool
+	}{
+		{"same table", []int{1, 3}, "INSERT INTO t_demo_1", false},
+		{"different tables", []int{1, 2}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+			entities := make([]IEntityStruct, 0, len(tt.ids))
+			for _, id := range tt.ids {
+				entities = append(entities, &txDemo{ID: id, Name: "grm"})
+			}
+			var insertErr error
+			_, err := Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+				_, insertErr = InsertSlice(ctx, entities)
+				return nil, insertErr
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (insertErr != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", insertErr, tt.wantErr)
+			}
+			statements := db.take()
+			if tt.wantErr {
+				//表名不一致时不执行保存语句
+				//The insert statement is not executed when the table names are different
+				assertStatements(t, statements, []string{"BEGIN", "ROLLBACK"})
+				return
+			}
+			if len(statements) != 3 || !strings.HasPrefix(strings.TrimSpace(statements[1]), tt.want) {
+				t.Errorf("statements = %q, want %q", statements, tt.want)
+			}
+		})
+	}
+}
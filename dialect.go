@@ -14,7 +14,7 @@ import (
 
 //wrapPageSQL 包装分页的SQL语句
 //wrapPageSQL SQL statement for wrapping paging
-func wrapPageSQL(drv string, sqlStr string, page *Page) (string, error) {
+func wrapPageSQL(config *DBConfig, sqlStr string, page *Page) (string, error) {
 	//新的分页方法都已经不需要order by了,不再强制检查
 	//The new paging method does not require 'order by' anymore, no longer mandatory check.
 	//
@@ -24,40 +24,34 @@ func wrapPageSQL(drv string, sqlStr string, page *Page) (string, error) {
 			return "", errors.New("分页语句必须有 order by")
 		}
 	*/
-	var sqlbuilder SQLBuilder
-	sqlbuilder.WriteString(sqlStr)
-	if drv == "mysql" || drv == "sqlite" || drv == "clickhouse" { //MySQL,sqlite3,dm数据库,南大通用,clickhouse
-		sqlbuilder.WriteString(" LIMIT ")
-		sqlbuilder.WriteInt(page.PageSize * (page.PageNo - 1))
-		sqlbuilder.WriteString(",")
-		sqlbuilder.WriteInt(page.PageSize)
-	} else if drv == "postgresql" { //postgresql
-		sqlbuilder.WriteString(" LIMIT ")
-		sqlbuilder.WriteInt(page.PageSize)
-		sqlbuilder.WriteString(" OFFSET ")
-		sqlbuilder.WriteInt(page.PageSize * (page.PageNo - 1))
-	} else if drv == "mssql" || drv == "oracle" { //sqlserver 2012+,oracle 12c+
-		sqlbuilder.WriteString(" OFFSET ")
-		sqlbuilder.WriteInt(page.PageSize * (page.PageNo - 1))
-		sqlbuilder.WriteString(" ROWS FETCH NEXT ")
-		sqlbuilder.WriteInt(page.PageSize)
-		sqlbuilder.WriteString(" ROWS ONLY ")
-	} else {
-		return "", errors.New("wrapPageSQL()-->不支持的数据库类型:" + drv)
+	limitSQL, err := wrapLimitSQL(config, sqlStr, page.PageSize*(page.PageNo-1), page.PageSize)
+	if err != nil {
+		return "", err
+	}
+	return reBindSQL(config, limitSQL)
+}
+
+//wrapLimitSQL 包装从offset开始,最多limit条的查询语句,没有reBindSQL,用于分片查询等offset不是PageSize整数倍的场景
+//wrapLimitSQL Wrap the query statement starting from offset, up to limit rows, without reBindSQL,
+//used for scenarios such as shard query where offset is not an integer multiple of PageSize
+func wrapLimitSQL(config *DBConfig, sqlStr string, offset int, limit int) (string, error) {
+	dialect, err := getConfigDialect(config)
+	if err != nil {
+		return "", err
 	}
-	return reBindSQL(drv, sqlbuilder.String())
+	return dialect.LimitSQL(sqlStr, offset, limit)
 }
 
 //wrapInsertSQL  包装保存Struct语句.返回语句,是否自增,错误信息
 //数组传递,如果外部方法有调用append的逻辑，append会破坏指针引用，所以传递指针
 //wrapInsertSQL Pack and save 'Struct' statement. Return  SQL statement, whether it is incremented, error message
 //Array transfer, if the external method has logic to call append, append will destroy the pointer reference, so the pointer is passed
-func wrapInsertSQL(drv string, typeOf *reflect.Type, entity IEntityStruct, columns *[]reflect.StructField, values *[]interface{}) (string, int, string, error) {
-	sqlStr, autoIncrement, pkType, err := wrapInsertSQLNOreBuild(drv, typeOf, entity, columns, values)
+func wrapInsertSQL(config *DBConfig, tableName string, typeOf *reflect.Type, entity IEntityStruct, columns *[]reflect.StructField, values *[]interface{}) (string, int, string, error) {
+	sqlStr, autoIncrement, pkType, err := wrapInsertSQLNOreBuild(config, tableName, typeOf, entity, columns, values)
 	if err != nil {
 		return sqlStr, autoIncrement, pkType, err
 	}
-	saveSql, err := reBindSQL(drv, sqlStr)
+	saveSql, err := reBindSQL(config, sqlStr)
 	return saveSql, autoIncrement, pkType, err
 }
 
@@ -65,11 +59,15 @@ func wrapInsertSQL(drv string, typeOf *reflect.Type, entity IEntityStruct, colum
 //数组传递,如果外部方法有调用append的逻辑,传递指针,因为append会破坏指针引用
 //Pack and save Struct statement. Return  SQL statement, no rebuild, return original SQL, whether it is self-increment, error message
 //Array transfer, if the external method has logic to call append, append will destroy the pointer reference, so the pointer is passed
-func wrapInsertSQLNOreBuild(drv string, typeOf *reflect.Type, entity IEntityStruct, columns *[]reflect.StructField, values *[]interface{}) (string, int, string, error) {
+func wrapInsertSQLNOreBuild(config *DBConfig, tableName string, typeOf *reflect.Type, entity IEntityStruct, columns *[]reflect.StructField, values *[]interface{}) (string, int, string, error) {
 
 	//自增类型  0(不自增),1(普通自增),2(序列自增),3(触发器自增)
 	//Self-increment type： 0（Not increase）,1(Ordinary increment),2(Sequence increment),3(Trigger increment)
 	autoIncrement := 0
+	drv := config.Dialect
+	//转义表名和字段名
+	//Quote table name and column name
+	quote := identifierQuoter(config)
 	//主键类型
 	//Primary key type
 	pkType := ""
@@ -77,7 +75,7 @@ func wrapInsertSQLNOreBuild(drv string, typeOf *reflect.Type, entity IEntityStru
 	//SQL statement constructor
 	var sqlBuilder SQLBuilder
 	sqlBuilder.WriteString("INSERT INTO ")
-	sqlBuilder.WriteString(entity.TableName())
+	sqlBuilder.WriteString(quote(tableName))
 	sqlBuilder.WriteString("(")
 
 	//SQL语句中,VALUES(?,?,...)语句的构造器
@@ -139,7 +137,7 @@ func wrapInsertSQLNOreBuild(drv string, typeOf *reflect.Type, entity IEntityStru
 				//sqlBuilder.WriteString(getStructFieldTagColumnValue(typeOf, field.Name))
 				//sqlBuilder.WriteString(field.Tag.Get(tagColumnName))
 				colName := getFieldTagName(&field)
-				sqlBuilder.WriteString(colName)
+				sqlBuilder.WriteString(quote(colName))
 				sqlBuilder.WriteString(",")
 				valueSQLBuilder.WriteString(sequence)
 				valueSQLBuilder.WriteString(",")
@@ -179,7 +177,7 @@ func wrapInsertSQLNOreBuild(drv string, typeOf *reflect.Type, entity IEntityStru
 		//sqlBuilder.WriteString(getStructFieldTagColumnValue(typeOf, field.Name))
 		// sqlBuilder.WriteString(field.Tag.Get(tagColumnName))
 		colName := getFieldTagName(&field)
-		sqlBuilder.WriteString(colName)
+		sqlBuilder.WriteString(quote(colName))
 		sqlBuilder.WriteString(",")
 		valueSQLBuilder.WriteString("?,")
 	}
@@ -201,7 +199,7 @@ func wrapInsertSQLNOreBuild(drv string, typeOf *reflect.Type, entity IEntityStru
 //数组传递,如果外部方法有调用append的逻辑，append会破坏指针引用，所以传递指针
 //wrapInsertSliceSQL Package and save Struct Slice statements in batches. Return SQL statement, whether it is incremented, error message
 //Array transfer, if the external method has logic to call append, append will destroy the pointer reference, so the pointer is passed
-func wrapInsertSliceSQL(drv string, typeOf *reflect.Type, entityStructSlice []IEntityStruct, columns *[]reflect.StructField, values *[]interface{}) (string, int, error) {
+func wrapInsertSliceSQL(config *DBConfig, tableName string, typeOf *reflect.Type, entityStructSlice []IEntityStruct, columns *[]reflect.StructField, values *[]interface{}) (string, int, error) {
 	sliceLen := len(entityStructSlice)
 	if entityStructSlice == nil || sliceLen < 1 {
 		return "", 0, errors.New("wrapInsertSliceSQL对象数组不能为空")
@@ -213,14 +211,14 @@ func wrapInsertSliceSQL(drv string, typeOf *reflect.Type, entityStructSlice []IE
 
 	//先生成一条语句
 	//Generate a statement first
-	sqlStr, autoIncrement, _, firstErr := wrapInsertSQLNOreBuild(drv, typeOf, entity, columns, values)
+	sqlStr, autoIncrement, _, firstErr := wrapInsertSQLNOreBuild(config, tableName, typeOf, entity, columns, values)
 	if firstErr != nil {
 		return "", autoIncrement, firstErr
 	}
 	//如果只有一个Struct对象
 	//If there is only one Struct object
 	if sliceLen == 1 {
-		sqlStr, _ = reBindSQL(drv, sqlStr)
+		sqlStr, _ = reBindSQL(config, sqlStr)
 		return sqlStr, autoIncrement, firstErr
 	}
 	//主键的名称
@@ -284,40 +282,83 @@ func wrapInsertSliceSQL(drv string, typeOf *reflect.Type, entityStructSlice []IE
 
 	//包装sql
 	//Wrap sql
-	saveSql, err := reBindSQL(drv, insertSliceSQLBuilder.String())
+	saveSql, err := reBindSQL(config, insertSliceSQLBuilder.String())
 	return saveSql, autoIncrement, err
 }
 
 //wrapUpdateSQL 包装更新Struct语句
 //数组传递,如果外部方法有调用append的逻辑，append会破坏指针引用，所以传递指针
+//tenant不为nil时,不更新租户字段,增加 AND 租户字段=? 的条件
+//有 grm:"version" 的字段时,版本号加1,增加 AND 版本号=? 的条件.grm:"autoCreateTime" 的字段不更新
 //wrapUpdateSQL Package update Struct statement
 //Array transfer, if the external method has logic to call append, append will destroy the pointer reference, so the pointer is passed
-func wrapUpdateSQL(drv string, typeOf *reflect.Type, entity IEntityStruct, columns *[]reflect.StructField, values *[]interface{}, onlyUpdateNotZero bool) (string, error) {
+//When tenant is not nil, the tenant column is not updated, and the condition AND tenant column=? is added
+//When there is a grm:"version" column, the version is incremented by 1, and the condition AND version=? is added. The grm:"autoCreateTime" column is not updated
+func wrapUpdateSQL(config *DBConfig, tableName string, typeOf *reflect.Type, entity IEntityStruct, columns *[]reflect.StructField, values *[]interface{}, onlyUpdateNotZero bool, tenant *tenantCondition) (string, error) {
 
 	//SQL语句的构造器
 	//SQL statement constructor
 	var sqlBuilder SQLBuilder
+	//转义表名和字段名
+	//Quote table name and column name
+	quote := identifierQuoter(config)
 
 	sqlBuilder.WriteString("UPDATE ")
-	sqlBuilder.WriteString(entity.TableName())
+	sqlBuilder.WriteString(quote(tableName))
 	sqlBuilder.WriteString(" SET ")
 
-	//主键的值
-	//The value of the primary key
-	var pkValue interface{}
-	//主键的名称
-	//The name of the primary key
-	pkFieldName, e := entityPKFieldName(entity, typeOf)
+	//主键的值,支持联合主键
+	//The values of the primary key, composite primary keys are supported
+	pkValues, e := entityPKValues(entity, typeOf)
+	if e != nil {
+		return "", e
+	}
+	//主键的属性名称
+	//The field names of the primary key
+	dbMap, e := getDBColumnFieldMap(typeOf)
+	if e != nil {
+		return "", e
+	}
+	pkFieldNames := make(map[string]bool)
+	for _, pkColumn := range entityPKColumns(entity) {
+		pkFieldNames[dbMap[strings.ToLower(pkColumn)].Name] = true
+	}
+	//乐观锁的版本号字段
+	//The version column of optimistic lock
+	versionField, hasVersion, e := getGrmTagField(typeOf, tagVersion)
+	if e != nil {
+		return "", e
+	}
+	if hasVersion && !isIntKind(versionField.Type.Kind()) {
+		return "", errors.New("wrapUpdateSQL-->乐观锁字段" + versionField.Name + "必须是整数类型")
+	}
+	var versionValue interface{}
+	//创建时间的字段
+	//The column of create time
+	createTimeField, hasCreateTime, e := getGrmTagField(typeOf, tagAutoCreateTime)
 	if e != nil {
 		return "", e
 	}
 
 	for i := 0; i < len(*columns); i++ {
 		field := (*columns)[i]
-		if field.Name == pkFieldName {
+		if hasVersion && field.Name == versionField.Name {
+			//版本号加1,原来的版本号作为条件
+			//The version is incremented by 1, and the original version is used as a condition
+			versionValue = (*values)[i]
+			*columns = append((*columns)[:i], (*columns)[i+1:]...)
+			*values = append((*values)[:i], (*values)[i+1:]...)
+			i = i - 1
+			colName := quote(getFieldTagName(&field))
+			sqlBuilder.WriteString(colName)
+			sqlBuilder.WriteString("=")
+			sqlBuilder.WriteString(colName)
+			sqlBuilder.WriteString("+1,")
+			continue
+		}
+		if pkFieldNames[field.Name] {
 			//如果是主键
 			//If it is the primary key.
-			pkValue = (*values)[i]
 			//去掉这一列,最后处理主键
 			//Remove this column, and finally process the primary key
 			*columns = append((*columns)[:i], (*columns)[i+1:]...)
@@ -326,6 +367,15 @@ func wrapUpdateSQL(drv string, typeOf *reflect.Type, entity IEntityStruct, colum
 			continue
 		}
 
+		//租户字段不更新,作为条件.创建时间不更新
+		//The tenant column is not updated and is used as a condition. The create time is not updated
+		if (tenant != nil && strings.ToLower(getFieldTagName(&field)) == tenant.column) || (hasCreateTime && field.Name == createTimeField.Name) {
+			*columns = append((*columns)[:i], (*columns)[i+1:]...)
+			*values = append((*values)[:i], (*values)[i+1:]...)
+			i = i - 1
+			continue
+		}
+
 		//如果是默认值字段,删除掉,不更新
 		//If it is the default value field, delete it and do not update
 		if onlyUpdateNotZero && (reflect.ValueOf((*values)[i]).IsZero()) {
@@ -339,41 +389,125 @@ func wrapUpdateSQL(drv string, typeOf *reflect.Type, entity IEntityStruct, colum
 		//sqlBuilder.WriteString(getStructFieldTagColumnValue(typeOf, field.Name))
 		// sqlBuilder.WriteString(field.Tag.Get(tagColumnName))
 		colName := getFieldTagName(&field)
-		sqlBuilder.WriteString(colName)
+		sqlBuilder.WriteString(quote(colName))
 		sqlBuilder.WriteString("=?,")
 	}
 	//主键的值是最后一个
 	//The value of the primary key is the last
-	*values = append(*values, pkValue)
+	*values = append(*values, pkValues...)
 	//去掉字符串最后的 ','
 	//Remove the',' at the end of the string
 	sqlBuilder.RemoveEnd(1)
 	sqlBuilder.WriteString(" WHERE ")
-	sqlBuilder.WriteString(entity.PK())
-	sqlBuilder.WriteString("=?")
+	wrapPKCondition(&sqlBuilder, entity, quote)
+	wrapTenantCondition(&sqlBuilder, values, tenant, quote)
+	if hasVersion {
+		sqlBuilder.WriteString(" AND ")
+		sqlBuilder.WriteString(quote(getFieldTagName(&versionField)))
+		sqlBuilder.WriteString("=?")
+		*values = append(*values, versionValue)
+	}
 
-	return reBindSQL(drv, sqlBuilder.String())
+	return reBindSQL(config, sqlBuilder.String())
 }
 
-//wrapDeleteSQL 包装删除Struct语句
-//wrapDeleteSQL Package delete Struct statement
-func wrapDeleteSQL(drv string, entity IEntityStruct) (string, error) {
+//wrapDeleteSQL 包装删除Struct语句,tenant不为nil时,增加 AND 租户字段=? 的条件,参数由调用方添加
+//wrapDeleteSQL Package delete Struct statement, when tenant is not nil, add the condition AND tenant column=?, the parameter is added by the caller
+func wrapDeleteSQL(config *DBConfig, tableName string, entity IEntityStruct, tenant *tenantCondition) (string, error) {
 
 	//SQL语句的构造器
 	//SQL statement constructor
 	var sqlBuilder SQLBuilder
+	//转义表名和字段名
+	//Quote table name and column name
+	quote := identifierQuoter(config)
 	sqlBuilder.WriteString("DELETE FROM ")
-	sqlBuilder.WriteString(entity.TableName())
+	sqlBuilder.WriteString(quote(tableName))
 	sqlBuilder.WriteString(" WHERE ")
-	sqlBuilder.WriteString(entity.PK())
+	wrapPKCondition(&sqlBuilder, entity, quote)
+	if tenant != nil {
+		sqlBuilder.WriteString(" AND ")
+		sqlBuilder.WriteString(quote(tenant.column))
+		sqlBuilder.WriteString("=?")
+	}
+	return reBindSQL(config, sqlBuilder.String())
+}
+
+//wrapSoftDeleteSQL 包装软删除和恢复Struct语句,更新软删除字段的值.values是软删除字段的值和主键的值,tenant不为nil时,增加 AND 租户字段=? 的条件
+//wrapSoftDeleteSQL Package soft delete and restore Struct statement, update the value of the soft delete column.
+//values are the value of the soft delete column and the value of the primary key, when tenant is not nil, add the condition AND tenant column=?
+func wrapSoftDeleteSQL(config *DBConfig, tableName string, entity IEntityStruct, column string, values *[]interface{}, tenant *tenantCondition) (string, error) {
+
+	//SQL语句的构造器
+	//SQL statement constructor
+	var sqlBuilder SQLBuilder
+	//转义表名和字段名
+	//Quote table name and column name
+	quote := identifierQuoter(config)
+	sqlBuilder.WriteString("UPDATE ")
+	sqlBuilder.WriteString(quote(tableName))
+	sqlBuilder.WriteString(" SET ")
+	sqlBuilder.WriteString(quote(column))
+	sqlBuilder.WriteString("=? WHERE ")
+	wrapPKCondition(&sqlBuilder, entity, quote)
+	wrapTenantCondition(&sqlBuilder, values, tenant, quote)
+	return reBindSQL(config, sqlBuilder.String())
+}
+
+//wrapPKCondition 增加主键的条件,联合主键是 a=? AND b=? ,参数的顺序和entityPKColumns一致
+//wrapPKCondition Add the condition of the primary key, the composite primary key is a=? AND b=?, the order of the parameters is consistent with entityPKColumns
+func wrapPKCondition(sqlBuilder *SQLBuilder, entity IEntityStruct, quote func(string) string) {
+	for i, pkColumn := range entityPKColumns(entity) {
+		if i > 0 {
+			sqlBuilder.WriteString(" AND ")
+		}
+		sqlBuilder.WriteString(quote(pkColumn))
+		sqlBuilder.WriteString("=?")
+	}
+}
+
+//wrapTenantCondition 增加 AND 租户字段=? 的条件和参数,tenant为nil时不处理
+//wrapTenantCondition Add the condition AND tenant column=? and the parameter, not processed when tenant is nil
+func wrapTenantCondition(sqlBuilder *SQLBuilder, values *[]interface{}, tenant *tenantCondition, quote func(string) string) {
+	if tenant == nil {
+		return
+	}
+	sqlBuilder.WriteString(" AND ")
+	sqlBuilder.WriteString(quote(tenant.column))
 	sqlBuilder.WriteString("=?")
-	return reBindSQL(drv, sqlBuilder.String())
+	*values = append(*values, tenant.value)
+}
+
+//identifierQuoter 返回转义表名和字段名的函数,DBConfig.QuoteIdentifier为false时不转义,参见Dialect.QuoteIdentifier
+//identifierQuoter Return the function to quote the table name and column name, no quoting when DBConfig.QuoteIdentifier is false, see Dialect.QuoteIdentifier
+func identifierQuoter(config *DBConfig) func(string) string {
+	if config != nil && config.QuoteIdentifier {
+		if dialect, err := getConfigDialect(config); err == nil {
+			return dialect.QuoteIdentifier
+		}
+	}
+	return func(name string) string {
+		return name
+	}
+}
+
+//quoteIdentifiers 转义名称数组,返回新的数组
+//quoteIdentifiers Quote the name slice and return a new slice
+func quoteIdentifiers(quote func(string) string, names []string) []string {
+	if names == nil {
+		return nil
+	}
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quote(name)
+	}
+	return quoted
 }
 
 //wrapInsertEntityMapSQL 包装保存Map语句,Map因为没有字段属性,无法完成Id的类型判断和赋值,需要确保Map的值是完整的
 //wrapInsertEntityMapSQL Pack and save the Map statement. Because Map does not have field attributes,
 //it cannot complete the type judgment and assignment of ID. It is necessary to ensure that the value of Map is complete
-func wrapInsertEntityMapSQL(drv string, entity IEntityMap) (string, []interface{}, bool, error) {
+func wrapInsertEntityMapSQL(config *DBConfig, tableName string, entity IEntityMap) (string, []interface{}, bool, error) {
 	//是否自增,默认false
 	dbFieldMap := entity.FieldMap()
 	if len(dbFieldMap) < 1 {
@@ -387,8 +521,11 @@ func wrapInsertEntityMapSQL(drv string, entity IEntityMap) (string, []interface{
 	//SQL语句的构造器
 	//SQL statement constructor
 	var sqlBuilder SQLBuilder
+	//转义表名和字段名
+	//Quote table name and column name
+	quote := identifierQuoter(config)
 	sqlBuilder.WriteString("INSERT INTO ")
-	sqlBuilder.WriteString(entity.TableName())
+	sqlBuilder.WriteString(quote(tableName))
 	sqlBuilder.WriteString("(")
 
 	//SQL语句中,VALUES(?,?,...)语句的构造器
@@ -400,8 +537,8 @@ func wrapInsertEntityMapSQL(drv string, entity IEntityMap) (string, []interface{
 	_, hasPK := dbFieldMap[entity.PK()]
 	if !hasPK { //如果没有设置主键,认为是自增或者序列 | If the primary key is not set, it is considered to be auto-increment or sequence
 		autoIncrement = true
-		if sequence, ok := entity.GetPkSequence()[drv]; ok { //如果是序列 | If it is a sequence.
-			sqlBuilder.WriteString(entity.PK())
+		if sequence, ok := entity.GetPkSequence()[config.Dialect]; ok { //如果是序列 | If it is a sequence.
+			sqlBuilder.WriteString(quote(entity.PK()))
 			sqlBuilder.WriteString(",")
 			valueSQLBuilder.WriteString(sequence)
 			valueSQLBuilder.WriteString(",")
@@ -411,7 +548,7 @@ func wrapInsertEntityMapSQL(drv string, entity IEntityMap) (string, []interface{
 	for k, v := range dbFieldMap {
 		//拼接字符串
 		//Concatenated string
-		sqlBuilder.WriteString(k + ",")
+		sqlBuilder.WriteString(quote(k) + ",")
 		valueSQLBuilder.WriteString("?,")
 		values = append(values, v)
 	}
@@ -426,7 +563,7 @@ func wrapInsertEntityMapSQL(drv string, entity IEntityMap) (string, []interface{
 	sqlBuilder.WriteString(")")
 	sqlBuilder.WriteString(valueSQLBuilder.String())
 	sqlBuilder.WriteString(")")
-	sqlStr, e := reBindSQL(drv, sqlBuilder.String())
+	sqlStr, e := reBindSQL(config, sqlBuilder.String())
 	if e != nil {
 		return "", nil, autoIncrement, e
 	}
@@ -434,9 +571,11 @@ func wrapInsertEntityMapSQL(drv string, entity IEntityMap) (string, []interface{
 }
 
 //wrapUpdateEntityMapSQL 包装Map更新语句,Map因为没有字段属性,无法完成Id的类型判断和赋值,需要确保Map的值是完整的
+//tenant不为nil时,不更新租户字段,增加 AND 租户字段=? 的条件
 //wrapUpdateEntityMapSQL Wrap the Map update statement. Because Map does not have field attributes,
 //it cannot complete the type judgment and assignment of Id. It is necessary to ensure that the value of Map is complete
-func wrapUpdateEntityMapSQL(drv string, entity IEntityMap) (string, []interface{}, error) {
+//When tenant is not nil, the tenant column is not updated, and the condition AND tenant column=? is added
+func wrapUpdateEntityMapSQL(config *DBConfig, tableName string, entity IEntityMap, tenant *tenantCondition) (string, []interface{}, error) {
 	dbFieldMap := entity.FieldMap()
 	if len(dbFieldMap) < 1 {
 		return "", nil, errors.New("wrapUpdateEntityMapSQL-->FieldMap返回值不能为空")
@@ -444,9 +583,12 @@ func wrapUpdateEntityMapSQL(drv string, entity IEntityMap) (string, []interface{
 	//SQL语句的构造器
 	//SQL statement constructor
 	var sqlBuilder SQLBuilder
+	//转义表名和字段名
+	//Quote table name and column name
+	quote := identifierQuoter(config)
 
 	sqlBuilder.WriteString("UPDATE ")
-	sqlBuilder.WriteString(entity.TableName())
+	sqlBuilder.WriteString(quote(tableName))
 	sqlBuilder.WriteString(" SET ")
 
 	//SQL对应的参数
@@ -461,7 +603,10 @@ func wrapUpdateEntityMapSQL(drv string, entity IEntityMap) (string, []interface{
 			pkValue = v
 			continue
 		}
-		sqlBuilder.WriteString(k)
+		if tenant != nil && k == tenant.column { //租户字段不更新 | The tenant column is not updated
+			continue
+		}
+		sqlBuilder.WriteString(quote(k))
 		sqlBuilder.WriteString("=?,")
 		values = append(values, v)
 	}
@@ -472,20 +617,55 @@ func wrapUpdateEntityMapSQL(drv string, entity IEntityMap) (string, []interface{
 	sqlStr = sqlStr[:len(sqlStr)-1]
 	sqlBuilder.RemoveEnd(1)
 	sqlBuilder.WriteString(" WHERE ")
-	sqlBuilder.WriteString(entity.PK())
+	sqlBuilder.WriteString(quote(entity.PK()))
 	sqlBuilder.WriteString("=?")
+	wrapTenantCondition(&sqlBuilder, &values, tenant, quote)
 
 	var e error
-	sqlStr, e = reBindSQL(drv, sqlBuilder.String())
+	sqlStr, e = reBindSQL(config, sqlBuilder.String())
 	if e != nil {
 		return "", nil, e
 	}
 	return sqlStr, values, nil
 }
 
+//wrapUpsertSQL 包装Upsert语句,没有reBindSQL.columns是保存的字段,参数的顺序和columns一致,参见Dialect.UpsertSQL
+//wrapUpsertSQL Wrap the Upsert statement, without reBindSQL. columns are the inserted columns,
+//and the order of parameters is consistent with columns, see Dialect.UpsertSQL
+func wrapUpsertSQL(config *DBConfig, tableName string, columns []string, conflictColumns []string, updateColumns []string, pkColumn string, pkSequence string) (string, error) {
+	dialect, err := getConfigDialect(config)
+	if err != nil {
+		return "", err
+	}
+	quote := identifierQuoter(config)
+	return dialect.UpsertSQL(quote(tableName), quoteIdentifiers(quote, columns), quoteIdentifiers(quote, conflictColumns), quoteIdentifiers(quote, updateColumns), quote(pkColumn), pkSequence)
+}
+
+//wrapReturningID 根据方言拼接获取自增主键的语句.返回值lastInsertID不为nil时使用QueryRow接收,outReturningID不为nil时使用sql.Out参数接收
+//都为nil时使用sql.Result.LastInsertId获取
+//wrapReturningID Append the statement to get the auto-increment primary key according to the dialect. When the returned lastInsertID is not nil,
+//use QueryRow to receive, when outReturningID is not nil, use the sql.Out parameter to receive. When both are nil, use sql.Result.LastInsertId to get
+func wrapReturningID(config *DBConfig, pkColumn string, sqlStr *string, values *[]interface{}) (*int64, *int64, error) {
+	dialect, err := getConfigDialect(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	returning, mode := dialect.ReturningID(identifierQuoter(config)(pkColumn))
+	var p int64 = 0
+	if mode == ReturningIDQueryRow {
+		*sqlStr = *sqlStr + returning
+		return &p, nil, nil
+	} else if mode == ReturningIDOutParam {
+		*sqlStr = *sqlStr + returning
+		*values = append(*values, sql.Named("grmSQLOutReturningID", sql.Out{Dest: &p}))
+		return nil, &p, nil
+	}
+	return nil, nil, nil
+}
+
 //wrapQuerySQL 封装查询语句
 //wrapQuerySQL Encapsulated query statement
-func wrapQuerySQL(drv string, finder *Finder, page *Page) (string, error) {
+func wrapQuerySQL(config *DBConfig, finder *Finder, page *Page) (string, error) {
 
 	//获取到没有page的sql的语句
 	//Get the SQL statement without page.
@@ -494,9 +674,9 @@ func wrapQuerySQL(drv string, finder *Finder, page *Page) (string, error) {
 		return "", err
 	}
 	if page == nil {
-		sqlStr, err = reBindSQL(drv, sqlStr)
+		sqlStr, err = reBindSQL(config, sqlStr)
 	} else {
-		sqlStr, err = wrapPageSQL(drv, sqlStr, page)
+		sqlStr, err = wrapPageSQL(config, sqlStr, page)
 	}
 
 	if err != nil {
@@ -507,8 +687,12 @@ func wrapQuerySQL(drv string, finder *Finder, page *Page) (string, error) {
 
 //reBindSQL 包装基础的SQL语句,根据数据库类型,调整SQL变量符号,例如?,? $1,$2这样的
 //reBindSQL Pack basic SQL statements, adjust the SQL variable symbols according to the database type, such as?,? $1,$2
-func reBindSQL(drv string, sqlStr string) (string, error) {
-	if drv == "mysql" || drv == "sqlite" || drv == "clickhouse" {
+func reBindSQL(config *DBConfig, sqlStr string) (string, error) {
+	dialect, err := getConfigDialect(config)
+	if err != nil {
+		return "", err
+	}
+	if dialect.BindVar(1) == "?" {
 		return sqlStr, nil
 	}
 
@@ -519,52 +703,114 @@ func reBindSQL(drv string, sqlStr string) (string, error) {
 	var sqlBuilder SQLBuilder
 	sqlBuilder.WriteString(strs[0])
 	for i := 1; i < len(strs); i++ {
-		if drv == "postgresql" { //postgresql
-			sqlBuilder.WriteString("$")
-			sqlBuilder.WriteInt(i)
-		} else if drv == "mssql" { //mssql
-			sqlBuilder.WriteString("@p")
-			sqlBuilder.WriteInt(i)
-		} else if drv == "oracle" { //oracle
-			sqlBuilder.WriteString(":")
-			sqlBuilder.WriteInt(i)
-		} else { //其他情况,还是使用 '?' | In other cases, or use'?'
-			sqlBuilder.WriteString("?")
-		}
+		sqlBuilder.WriteString(dialect.BindVar(i))
 		sqlBuilder.WriteString(strs[i])
 	}
 	return sqlBuilder.String(), nil
 }
 
-//reUpdateFinderSQL 根据数据类型更新 手动编写的 UpdateFinder的语句,用于处理数据库兼容,例如 clickhouse的 UPDATE 和 DELETE
-func reUpdateFinderSQL(drv string, sqlStr *string) (*string, error) {
-
-	//处理clickhouse的特殊更新语法
-	if drv == "clickhouse" {
-		//SQL语句的构造器
-		//SQL statement constructor
-		var sqlBuilder SQLBuilder
-		sqlBuilder.WriteString("ALTER TABLE ")
-		sqls := findUpdateTableName(sqlStr)
-		if len(sqls) >= 2 { //如果是更新语句
-			sqlBuilder.WriteString(sqls[1])
-			sqlBuilder.WriteString(" UPDATE ")
-		} else { //如果不是更新语句
-			sqls = findDeleteTableName(sqlStr)
-			if len(sqls) < 2 { //如果也不是删除语句
-				return sqlStr, nil
-			}
-			sqlBuilder.WriteString(sqls[1])
-			sqlBuilder.WriteString(" DELETE WHERE ")
-		}
+//reUpdateFinderSQL 根据数据类型更新 手动编写的 UpdateFinder的语句,用于处理数据库兼容,例如 clickhouse的 UPDATE 和 DELETE,参见UpdateSQLRewriter
+func reUpdateFinderSQL(config *DBConfig, sqlStr *string) (*string, error) {
+	dialect, err := getConfigDialect(config)
+	if err != nil {
+		return nil, err
+	}
+	rewriter, ok := dialect.(UpdateSQLRewriter)
+	if !ok {
+		return sqlStr, nil
+	}
+	str, err := rewriter.RewriteUpdateSQL(*sqlStr)
+	if err != nil {
+		return nil, err
+	}
+	return &str, nil
+}
 
-		//截取字符串
-		content := (*sqlStr)[len(sqls[0]):]
-		sqlBuilder.WriteString(content)
-		str := sqlBuilder.String()
-		return &str, nil
+//savepoint的操作类型
+//savepoint operation type
+const (
+	savepointCreate = iota
+	savepointRollback
+	savepointRelease
+)
+
+//wrapSavepointSQL 根据数据库类型,生成savepoint的语句,返回""代表数据库没有对应的语法,不需要执行
+//mssql使用SAVE TRANSACTION,oracle和mssql没有释放savepoint的语法
+//wrapSavepointSQL Generate the savepoint statement according to the database type, return "" means there is no corresponding syntax and no need to execute
+func wrapSavepointSQL(config *DBConfig, spType int, name string) (string, error) {
+	dialect, err := getConfigDialect(config)
+	if err != nil {
+		return "", err
+	}
+	if spType == savepointCreate {
+		return dialect.SavepointSQL(name), nil
+	} else if spType == savepointRollback {
+		return dialect.RollbackToSavepointSQL(name), nil
+	}
+	return dialect.ReleaseSavepointSQL(name), nil
+}
+
+//XA分布式事务分支的操作类型
+//XA distributed transaction branch operation type
+const (
+	xaStart = iota
+	xaEnd
+	xaPrepare
+	xaCommit
+	xaRollback
+	xaRollbackActive
+)
+
+//wrapXASQL 根据数据库方言,生成XA分支事务的语句,返回""代表数据库在这一步不需要执行语句,方言需要实现XADialect
+//wrapXASQL Generate the statement of the XA branch transaction according to the database dialect,
+//return "" means the database does not need to execute a statement in this step, the dialect needs to implement XADialect
+func wrapXASQL(config *DBConfig, xaType int, xid string) (string, error) {
+	xaDialect, err := getXADialect(config)
+	if err != nil {
+		return "", err
+	}
+	switch xaType {
+	case xaStart:
+		return xaDialect.XAStartSQL(xid), nil
+	case xaEnd:
+		return xaDialect.XAEndSQL(xid), nil
+	case xaPrepare:
+		return xaDialect.XAPrepareSQL(xid), nil
+	case xaCommit:
+		return xaDialect.XACommitSQL(xid), nil
+	case xaRollback:
+		return xaDialect.XARollbackSQL(xid, true), nil
+	}
+	return xaDialect.XARollbackSQL(xid, false), nil
+}
+
+//getXADialect 获取数据库配置的XADialect,方言没有实现XADialect返回错误
+//getXADialect Get the XADialect of the database configuration, return an error if the dialect does not implement XADialect
+func getXADialect(config *DBConfig) (XADialect, error) {
+	dialect, err := getConfigDialect(config)
+	if err != nil {
+		return nil, err
+	}
+	xaDialect, ok := dialect.(XADialect)
+	if !ok {
+		return nil, errors.New("getXADialect-->不支持XA分布式事务的数据库:" + config.Dialect)
+	}
+	return xaDialect, nil
+}
+
+//wrapReplicaLagSQL 根据数据库方言,生成查询从库复制延迟的语句,方言没有实现ReplicaLagDialect时使用心跳表
+//wrapReplicaLagSQL Generate the statement to query the replication lag of the replica according to the database dialect,
+//use the heartbeat table when the dialect does not implement ReplicaLagDialect
+func wrapReplicaLagSQL(config *DBConfig) (string, error) {
+	dialect, err := getConfigDialect(config)
+	if err != nil {
+		return "", err
 	}
-	return sqlStr, nil
+	lagDialect, ok := dialect.(ReplicaLagDialect)
+	if !ok {
+		return "", errors.New("wrapReplicaLagSQL-->不支持查询复制延迟的数据库,请使用心跳表:" + config.Dialect)
+	}
+	return lagDialect.ReplicaLagSQL(), nil
 }
 
 //查询' order by '在sql中出现的开始位置和结束位置
@@ -579,6 +825,31 @@ func findOrderByIndex(strSql string) []int {
 	return loc
 }
 
+//splitOrderByItems 按照不在括号内的逗号拆分ORDER BY的排序项,例如 "a DESC,COALESCE(b,c)" 拆分为 "a DESC" 和 "COALESCE(b,c)"
+//splitOrderByItems Split the ORDER BY items by commas that are not in parentheses, for example "a DESC,COALESCE(b,c)" is split into "a DESC" and "COALESCE(b,c)"
+func splitOrderByItems(orderBy string) []string {
+	items := make([]string, 0)
+	depth := 0
+	start := 0
+	for i := 0; i < len(orderBy); i++ {
+		switch orderBy[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, strings.TrimSpace(orderBy[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if item := strings.TrimSpace(orderBy[start:]); item != "" {
+		items = append(items, item)
+	}
+	return items
+}
+
 //查询"group by"在sql中出现的开始位置和结束位置
 //Query the start position and end position of"group by" in sql。
 var groupByRegexp, _ = regexp.Compile("(?i)\\s(group)\\s+by\\s")
@@ -0,0 +1,1108 @@
+package grm
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MySQLDialect mysql方言,也可以用于TiDB,MariaDB等兼容mysql的数据库
+// MySQLDialect mysql dialect, can also be used for mysql compatible databases such as TiDB and MariaDB
+type MySQLDialect struct{}
+
+// Name 方言的名称
+// Name The name of the dialect
+func (dialect MySQLDialect) Name() string {
+	return "mysql"
+}
+
+// BindVar 占位符 ?
+// BindVar Placeholder ?
+func (dialect MySQLDialect) BindVar(i int) string {
+	return "?"
+}
+
+// LimitSQL LIMIT offset,limit
+func (dialect MySQLDialect) LimitSQL(sqlStr string, offset int, limit int) (string, error) {
+	return limitCommaSQL(sqlStr, offset, limit), nil
+}
+
+// QuoteIdentifier 使用反引号转义
+// QuoteIdentifier Quote with backticks
+func (dialect MySQLDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, "`", "`")
+}
+
+// ReturningID 使用LastInsertId获取自增主键
+// ReturningID Use LastInsertId to get the auto-increment primary key
+func (dialect MySQLDialect) ReturningID(pkColumn string) (string, ReturningIDMode) {
+	return "", ReturningIDLastInsertID
+}
+
+// UpsertSQL INSERT ... ON DUPLICATE KEY UPDATE c=VALUES(c),根据表的唯一索引判断冲突
+// UpsertSQL INSERT ... ON DUPLICATE KEY UPDATE c=VALUES(c), judge the conflict according to the unique index of the table
+func (dialect MySQLDialect) UpsertSQL(tableName string, columns []string, conflictColumns []string, updateColumns []string, pkColumn string, pkSequence string) (string, error) {
+	if len(columns) < 1 || len(conflictColumns) < 1 {
+		return "", errors.New("UpsertSQL-->columns和conflictColumns不能为空")
+	}
+	var sqlBuilder SQLBuilder
+	writeInsertValuesSQL(&sqlBuilder, tableName, columns, pkColumn, pkSequence)
+	sqlBuilder.WriteString(" ON DUPLICATE KEY UPDATE ")
+	if len(updateColumns) < 1 { //不更新,使用无效的更新 | No update, use invalid update
+		sqlBuilder.WriteString(conflictColumns[0])
+		sqlBuilder.WriteString("=")
+		sqlBuilder.WriteString(conflictColumns[0])
+		return sqlBuilder.String(), nil
+	}
+	for i, column := range updateColumns {
+		if i > 0 {
+			sqlBuilder.WriteString(",")
+		}
+		sqlBuilder.WriteString(column)
+		sqlBuilder.WriteString("=VALUES(")
+		sqlBuilder.WriteString(column)
+		sqlBuilder.WriteString(")")
+	}
+	return sqlBuilder.String(), nil
+}
+
+// SavepointSQL SAVEPOINT name
+func (dialect MySQLDialect) SavepointSQL(name string) string {
+	return "SAVEPOINT " + name
+}
+
+// RollbackToSavepointSQL ROLLBACK TO SAVEPOINT name
+func (dialect MySQLDialect) RollbackToSavepointSQL(name string) string {
+	return "ROLLBACK TO SAVEPOINT " + name
+}
+
+// ReleaseSavepointSQL RELEASE SAVEPOINT name
+func (dialect MySQLDialect) ReleaseSavepointSQL(name string) string {
+	return "RELEASE SAVEPOINT " + name
+}
+
+// XAStartSQL XA START 'xid'
+func (dialect MySQLDialect) XAStartSQL(xid string) string {
+	return "XA START '" + xid + "'"
+}
+
+// XAEndSQL XA END 'xid'
+func (dialect MySQLDialect) XAEndSQL(xid string) string {
+	return "XA END '" + xid + "'"
+}
+
+// XAPrepareSQL XA PREPARE 'xid'
+func (dialect MySQLDialect) XAPrepareSQL(xid string) string {
+	return "XA PREPARE '" + xid + "'"
+}
+
+// XACommitSQL XA COMMIT 'xid'
+func (dialect MySQLDialect) XACommitSQL(xid string) string {
+	return "XA COMMIT '" + xid + "'"
+}
+
+// XARollbackSQL XA ROLLBACK 'xid' ,未prepare的分支需要先XA END,由调用方处理
+// XARollbackSQL XA ROLLBACK 'xid', the branch that is not prepared needs XA END first, which is handled by the caller
+func (dialect MySQLDialect) XARollbackSQL(xid string, prepared bool) string {
+	return "XA ROLLBACK '" + xid + "'"
+}
+
+// XARecoverSQL XA RECOVER
+func (dialect MySQLDialect) XARecoverSQL() string {
+	return "XA RECOVER"
+}
+
+// ScanXID XA RECOVER返回formatID,gtrid_length,bqual_length,data,xid是data的前gtrid_length个字符
+// ScanXID XA RECOVER returns formatID,gtrid_length,bqual_length,data, the xid is the first gtrid_length characters of data
+func (dialect MySQLDialect) ScanXID(rows *sql.Rows) (string, error) {
+	var xid string
+	var formatID, gtridLength, bqualLength int
+	if err := rows.Scan(&formatID, &gtridLength, &bqualLength, &xid); err != nil {
+		return "", err
+	}
+	if gtridLength < len(xid) {
+		xid = xid[:gtridLength]
+	}
+	return xid, nil
+}
+
+// ReplicaLagSQL SHOW SLAVE STATUS ,读取Seconds_Behind_Master
+// ReplicaLagSQL SHOW SLAVE STATUS, read Seconds_Behind_Master
+func (dialect MySQLDialect) ReplicaLagSQL() string {
+	return "SHOW SLAVE STATUS"
+}
+
+// LockSkipSQL SELECT ... ORDER BY ... LIMIT limit FOR UPDATE SKIP LOCKED ,需要mysql 8.0+
+// LockSkipSQL SELECT ... ORDER BY ... LIMIT limit FOR UPDATE SKIP LOCKED, requires mysql 8.0+
+func (dialect MySQLDialect) LockSkipSQL(tableName string, alias string, columns string, where string, orderBy string, limit int) string {
+	return limitLockSkipSQL(tableName, alias, columns, where, orderBy, limit)
+}
+
+// PostgreSQLDialect postgresql方言
+// PostgreSQLDialect postgresql dialect
+type PostgreSQLDialect struct{}
+
+// Name 方言的名称
+// Name The name of the dialect
+func (dialect PostgreSQLDialect) Name() string {
+	return "postgresql"
+}
+
+// BindVar 占位符 $1
+// BindVar Placeholder $1
+func (dialect PostgreSQLDialect) BindVar(i int) string {
+	return "$" + strconv.Itoa(i)
+}
+
+// LimitSQL LIMIT limit OFFSET offset
+func (dialect PostgreSQLDialect) LimitSQL(sqlStr string, offset int, limit int) (string, error) {
+	var sqlBuilder SQLBuilder
+	sqlBuilder.WriteString(sqlStr)
+	sqlBuilder.WriteString(" LIMIT ")
+	sqlBuilder.WriteInt(limit)
+	sqlBuilder.WriteString(" OFFSET ")
+	sqlBuilder.WriteInt(offset)
+	return sqlBuilder.String(), nil
+}
+
+// QuoteIdentifier 使用双引号转义
+// QuoteIdentifier Quote with double quotes
+func (dialect PostgreSQLDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, `"`, `"`)
+}
+
+// ReturningID SERIAL自增,使用 RETURNING 返回主键的值
+// ReturningID SERIAL auto-increment, use RETURNING to return the value of the primary key
+func (dialect PostgreSQLDialect) ReturningID(pkColumn string) (string, ReturningIDMode) {
+	return " RETURNING " + pkColumn, ReturningIDQueryRow
+}
+
+// UpsertSQL INSERT ... ON CONFLICT (a) DO UPDATE SET c=EXCLUDED.c
+func (dialect PostgreSQLDialect) UpsertSQL(tableName string, columns []string, conflictColumns []string, updateColumns []string, pkColumn string, pkSequence string) (string, error) {
+	return onConflictUpsertSQL(tableName, columns, conflictColumns, updateColumns, pkColumn, pkSequence)
+}
+
+// SavepointSQL SAVEPOINT name
+func (dialect PostgreSQLDialect) SavepointSQL(name string) string {
+	return "SAVEPOINT " + name
+}
+
+// RollbackToSavepointSQL ROLLBACK TO SAVEPOINT name
+func (dialect PostgreSQLDialect) RollbackToSavepointSQL(name string) string {
+	return "ROLLBACK TO SAVEPOINT " + name
+}
+
+// ReleaseSavepointSQL RELEASE SAVEPOINT name
+func (dialect PostgreSQLDialect) ReleaseSavepointSQL(name string) string {
+	return "RELEASE SAVEPOINT " + name
+}
+
+// XAStartSQL BEGIN ,postgresql没有XA START,使用普通事务,prepare后成为两阶段事务
+// XAStartSQL BEGIN, postgresql has no XA START, use a normal transaction, which becomes a two-phase transaction after prepare
+func (dialect PostgreSQLDialect) XAStartSQL(xid string) string {
+	return "BEGIN"
+}
+
+// XAEndSQL postgresql不需要结束分支事务
+// XAEndSQL postgresql does not need to end the branch transaction
+func (dialect PostgreSQLDialect) XAEndSQL(xid string) string {
+	return ""
+}
+
+// XAPrepareSQL PREPARE TRANSACTION 'xid' ,需要设置max_prepared_transactions
+// XAPrepareSQL PREPARE TRANSACTION 'xid', max_prepared_transactions needs to be set
+func (dialect PostgreSQLDialect) XAPrepareSQL(xid string) string {
+	return "PREPARE TRANSACTION '" + xid + "'"
+}
+
+// XACommitSQL COMMIT PREPARED 'xid'
+func (dialect PostgreSQLDialect) XACommitSQL(xid string) string {
+	return "COMMIT PREPARED '" + xid + "'"
+}
+
+// XARollbackSQL 已经prepare使用 ROLLBACK PREPARED 'xid' ,否则使用 ROLLBACK
+// XARollbackSQL Use ROLLBACK PREPARED 'xid' if prepared, otherwise use ROLLBACK
+func (dialect PostgreSQLDialect) XARollbackSQL(xid string, prepared bool) string {
+	if prepared {
+		return "ROLLBACK PREPARED '" + xid + "'"
+	}
+	return "ROLLBACK"
+}
+
+// XARecoverSQL 查询当前数据库pg_prepared_xacts的gid
+// XARecoverSQL Query the gid of pg_prepared_xacts of the current database
+func (dialect PostgreSQLDialect) XARecoverSQL() string {
+	return "SELECT gid FROM pg_prepared_xacts WHERE database = current_database()"
+}
+
+// ScanXID 读取gid
+// ScanXID Read the gid
+func (dialect PostgreSQLDialect) ScanXID(rows *sql.Rows) (string, error) {
+	var xid string
+	err := rows.Scan(&xid)
+	return xid, err
+}
+
+// ReplicaLagSQL 使用pg_last_xact_replay_timestamp()计算延迟的秒数,WAL已经全部回放时是0,主库返回0
+// ReplicaLagSQL Use pg_last_xact_replay_timestamp() to calculate the lag seconds, 0 when all WAL has been replayed, the primary returns 0
+func (dialect PostgreSQLDialect) ReplicaLagSQL() string {
+	return "SELECT COALESCE(CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0 ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END, 0)"
+}
+
+// LockSkipSQL SELECT ... ORDER BY ... LIMIT limit FOR UPDATE SKIP LOCKED
+func (dialect PostgreSQLDialect) LockSkipSQL(tableName string, alias string, columns string, where string, orderBy string, limit int) string {
+	return limitLockSkipSQL(tableName, alias, columns, where, orderBy, limit)
+}
+
+// OracleDialect oracle方言,分页需要12c+
+// OracleDialect oracle dialect, paging requires 12c+
+type OracleDialect struct{}
+
+// Name 方言的名称
+// Name The name of the dialect
+func (dialect OracleDialect) Name() string {
+	return "oracle"
+}
+
+// BindVar 占位符 :1
+// BindVar Placeholder :1
+func (dialect OracleDialect) BindVar(i int) string {
+	return ":" + strconv.Itoa(i)
+}
+
+// LimitSQL OFFSET offset ROWS FETCH NEXT limit ROWS ONLY
+func (dialect OracleDialect) LimitSQL(sqlStr string, offset int, limit int) (string, error) {
+	return offsetFetchSQL(sqlStr, offset, limit), nil
+}
+
+// QuoteIdentifier 使用双引号转义
+// QuoteIdentifier Quote with double quotes
+func (dialect OracleDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, `"`, `"`)
+}
+
+// ReturningID 12c+ 的IDENTITY自增列,使用 RETURNING INTO 返回主键的值
+// ReturningID IDENTITY auto-increment column of 12c+, use RETURNING INTO to return the value of the primary key
+func (dialect OracleDialect) ReturningID(pkColumn string) (string, ReturningIDMode) {
+	return " RETURNING " + pkColumn + " INTO :grmSQLOutReturningID ", ReturningIDOutParam
+}
+
+// UpsertSQL MERGE INTO ... USING (SELECT ... FROM dual) ...
+func (dialect OracleDialect) UpsertSQL(tableName string, columns []string, conflictColumns []string, updateColumns []string, pkColumn string, pkSequence string) (string, error) {
+	return mergeUpsertSQL(tableName, columns, conflictColumns, updateColumns, pkColumn, pkSequence, " FROM dual", "")
+}
+
+// SavepointSQL SAVEPOINT name
+func (dialect OracleDialect) SavepointSQL(name string) string {
+	return "SAVEPOINT " + name
+}
+
+// RollbackToSavepointSQL ROLLBACK TO SAVEPOINT name
+func (dialect OracleDialect) RollbackToSavepointSQL(name string) string {
+	return "ROLLBACK TO SAVEPOINT " + name
+}
+
+// ReleaseSavepointSQL oracle没有释放savepoint的语法
+// ReleaseSavepointSQL oracle has no syntax to release savepoint
+func (dialect OracleDialect) ReleaseSavepointSQL(name string) string {
+	return ""
+}
+
+// LockSkipSQL oracle的FOR UPDATE不能和ROWNUM分页的子查询一起使用,使用ROWID的IN子查询限制数量
+// LockSkipSQL oracle's FOR UPDATE cannot be used with the ROWNUM paging subquery, use the IN subquery of ROWID to limit the number
+func (dialect OracleDialect) LockSkipSQL(tableName string, alias string, columns string, where string, orderBy string, limit int) string {
+	var sqlBuilder SQLBuilder
+	sqlBuilder.WriteString("SELECT ")
+	sqlBuilder.WriteString(columns)
+	sqlBuilder.WriteString(" FROM ")
+	sqlBuilder.WriteString(tableName)
+	sqlBuilder.WriteString(" ")
+	sqlBuilder.WriteString(alias)
+	sqlBuilder.WriteString(" WHERE ")
+	sqlBuilder.WriteString(alias)
+	sqlBuilder.WriteString(".ROWID IN (SELECT grm_lock_rid FROM (SELECT ")
+	sqlBuilder.WriteString(alias)
+	sqlBuilder.WriteString(".ROWID grm_lock_rid FROM ")
+	sqlBuilder.WriteString(tableName)
+	sqlBuilder.WriteString(" ")
+	sqlBuilder.WriteString(alias)
+	sqlBuilder.WriteString(" WHERE ")
+	sqlBuilder.WriteString(where)
+	sqlBuilder.WriteString(" ORDER BY ")
+	sqlBuilder.WriteString(orderBy)
+	sqlBuilder.WriteString(") WHERE ROWNUM <= ")
+	sqlBuilder.WriteInt(limit)
+	sqlBuilder.WriteString(") ORDER BY ")
+	sqlBuilder.WriteString(orderBy)
+	sqlBuilder.WriteString(" FOR UPDATE SKIP LOCKED")
+	return sqlBuilder.String()
+}
+
+// VersionSQL 查询oracle的版本,例如 11.2.0.4.0
+// VersionSQL Query the version of oracle, such as 11.2.0.4.0
+func (dialect OracleDialect) VersionSQL() string {
+	return "SELECT version FROM product_component_version WHERE product LIKE 'Oracle%' AND ROWNUM=1"
+}
+
+// ForVersion 12c之前的版本使用Oracle11gDialect
+// ForVersion Versions before 12c use Oracle11gDialect
+func (dialect OracleDialect) ForVersion(version int) Dialect {
+	if version < 12 {
+		return Oracle11gDialect{}
+	}
+	return dialect
+}
+
+// Oracle11gDialect oracle 11g及之前版本的方言,分页使用ROWNUM的子查询,查询结果多返回的grm_page_rn列Query和QueryMap会忽略
+// Oracle11gDialect The dialect of oracle 11g and earlier versions, paging uses the ROWNUM subquery, and the extra grm_page_rn column in the query result is ignored by Query and QueryMap
+type Oracle11gDialect struct {
+	OracleDialect
+}
+
+// LimitSQL SELECT * FROM (SELECT t.*,ROWNUM rn FROM (...) t WHERE ROWNUM <= offset+limit) WHERE rn > offset
+func (dialect Oracle11gDialect) LimitSQL(sqlStr string, offset int, limit int) (string, error) {
+	var sqlBuilder SQLBuilder
+	sqlBuilder.WriteString("SELECT * FROM (SELECT grm_page_t.*,ROWNUM ")
+	sqlBuilder.WriteString(pageRowNumberColumn)
+	sqlBuilder.WriteString(" FROM (")
+	sqlBuilder.WriteString(sqlStr)
+	sqlBuilder.WriteString(") grm_page_t WHERE ROWNUM <= ")
+	sqlBuilder.WriteInt(offset + limit)
+	sqlBuilder.WriteString(") WHERE ")
+	sqlBuilder.WriteString(pageRowNumberColumn)
+	sqlBuilder.WriteString(" > ")
+	sqlBuilder.WriteInt(offset)
+	return sqlBuilder.String(), nil
+}
+
+// MSSQLDialect sqlserver方言,分页需要2012+
+// MSSQLDialect sqlserver dialect, paging requires 2012+
+type MSSQLDialect struct{}
+
+// Name 方言的名称
+// Name The name of the dialect
+func (dialect MSSQLDialect) Name() string {
+	return "mssql"
+}
+
+// BindVar 占位符 @p1
+// BindVar Placeholder @p1
+func (dialect MSSQLDialect) BindVar(i int) string {
+	return "@p" + strconv.Itoa(i)
+}
+
+// LimitSQL OFFSET offset ROWS FETCH NEXT limit ROWS ONLY
+func (dialect MSSQLDialect) LimitSQL(sqlStr string, offset int, limit int) (string, error) {
+	return offsetFetchSQL(sqlStr, offset, limit), nil
+}
+
+// QuoteIdentifier 使用方括号转义
+// QuoteIdentifier Quote with square brackets
+func (dialect MSSQLDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, "[", "]")
+}
+
+// ReturningID 使用LastInsertId获取自增主键
+// ReturningID Use LastInsertId to get the auto-increment primary key
+func (dialect MSSQLDialect) ReturningID(pkColumn string) (string, ReturningIDMode) {
+	return "", ReturningIDLastInsertID
+}
+
+// UpsertSQL MERGE INTO ... USING (SELECT ...) ... ; MERGE语句必须以分号结束
+// UpsertSQL MERGE INTO ... USING (SELECT ...) ... ; The MERGE statement must end with a semicolon
+func (dialect MSSQLDialect) UpsertSQL(tableName string, columns []string, conflictColumns []string, updateColumns []string, pkColumn string, pkSequence string) (string, error) {
+	return mergeUpsertSQL(tableName, columns, conflictColumns, updateColumns, pkColumn, pkSequence, "", ";")
+}
+
+// SavepointSQL SAVE TRANSACTION name
+func (dialect MSSQLDialect) SavepointSQL(name string) string {
+	return "SAVE TRANSACTION " + name
+}
+
+// RollbackToSavepointSQL ROLLBACK TRANSACTION name
+func (dialect MSSQLDialect) RollbackToSavepointSQL(name string) string {
+	return "ROLLBACK TRANSACTION " + name
+}
+
+// ReleaseSavepointSQL mssql没有释放savepoint的语法
+// ReleaseSavepointSQL mssql has no syntax to release savepoint
+func (dialect MSSQLDialect) ReleaseSavepointSQL(name string) string {
+	return ""
+}
+
+// LockSkipSQL SELECT TOP (limit) ... FROM tableName alias WITH (UPDLOCK, READPAST, ROWLOCK) ,READPAST跳过已经锁定的行
+// LockSkipSQL SELECT TOP (limit) ... FROM tableName alias WITH (UPDLOCK, READPAST, ROWLOCK), READPAST skips the locked rows
+func (dialect MSSQLDialect) LockSkipSQL(tableName string, alias string, columns string, where string, orderBy string, limit int) string {
+	var sqlBuilder SQLBuilder
+	sqlBuilder.WriteString("SELECT TOP (")
+	sqlBuilder.WriteInt(limit)
+	sqlBuilder.WriteString(") ")
+	sqlBuilder.WriteString(columns)
+	sqlBuilder.WriteString(" FROM ")
+	sqlBuilder.WriteString(tableName)
+	sqlBuilder.WriteString(" ")
+	sqlBuilder.WriteString(alias)
+	sqlBuilder.WriteString(" WITH (UPDLOCK, READPAST, ROWLOCK) WHERE ")
+	sqlBuilder.WriteString(where)
+	sqlBuilder.WriteString(" ORDER BY ")
+	sqlBuilder.WriteString(orderBy)
+	return sqlBuilder.String()
+}
+
+// VersionSQL 查询sqlserver的版本,例如 10.50.1600.1
+// VersionSQL Query the version of sqlserver, such as 10.50.1600.1
+func (dialect MSSQLDialect) VersionSQL() string {
+	return "SELECT CAST(SERVERPROPERTY('ProductVersion') AS VARCHAR(32))"
+}
+
+// ForVersion 2012(主版本号11)之前的版本使用MSSQL2008Dialect
+// ForVersion Versions before 2012 (major version 11) use MSSQL2008Dialect
+func (dialect MSSQLDialect) ForVersion(version int) Dialect {
+	if version < 11 {
+		return MSSQL2008Dialect{}
+	}
+	return dialect
+}
+
+// MSSQL2008Dialect sqlserver 2008及之前版本的方言,分页使用ROW_NUMBER() OVER (ORDER BY ...),查询结果多返回的grm_page_rn列Query和QueryMap会忽略
+// MSSQL2008Dialect The dialect of sqlserver 2008 and earlier versions, paging uses ROW_NUMBER() OVER (ORDER BY ...),
+// and the extra grm_page_rn column in the query result is ignored by Query and QueryMap
+type MSSQL2008Dialect struct {
+	MSSQLDialect
+}
+
+// LimitSQL 语句的ORDER BY移到ROW_NUMBER() OVER (ORDER BY ...),没有ORDER BY使用 (SELECT 0)
+// SELECT 开头的语句在字段前插入ROW_NUMBER(),DISTINCT,TOP,UNION等其他语句包装为子查询,ORDER BY的字段去掉表别名
+// OVER里不能使用查询字段的别名和序号,ORDER BY使用别名或者序号(例如 ORDER BY 2)时也包装为子查询,序号替换为对应查询字段的列名
+// LimitSQL The ORDER BY of the statement is moved to ROW_NUMBER() OVER (ORDER BY ...), and (SELECT 0) is used if there is no ORDER BY.
+// Statements starting with SELECT insert ROW_NUMBER() before the columns, other statements such as DISTINCT, TOP, UNION are wrapped as subqueries,
+// and the table alias of the ORDER BY columns is removed. The alias and ordinal of the select list cannot be used in OVER,
+// when ORDER BY uses an alias or an ordinal (such as ORDER BY 2), the statement is also wrapped as a subquery, and the ordinal is replaced with the column name of the select item
+func (dialect MSSQL2008Dialect) LimitSQL(sqlStr string, offset int, limit int) (string, error) {
+	orderBy := "(SELECT 0)"
+	selectOutput := false
+	locOrderBy := findOrderByIndex(sqlStr)
+	if len(locOrderBy) > 0 {
+		var err error
+		orderBy, selectOutput, err = selectOutputOrderBy(sqlStr[:locOrderBy[0]], strings.TrimSpace(sqlStr[locOrderBy[1]:]))
+		if err != nil {
+			return "", err
+		}
+		sqlStr = sqlStr[:locOrderBy[0]]
+	}
+	sqlStr = strings.TrimSpace(sqlStr)
+	var sqlBuilder SQLBuilder
+	sqlBuilder.WriteString("SELECT * FROM (")
+	lowerSQL := strings.ToLower(sqlStr)
+	injectable := !selectOutput && strings.HasPrefix(lowerSQL, "select ") && !strings.Contains(lowerSQL, " union ")
+	if injectable {
+		columns := strings.TrimSpace(lowerSQL[7:])
+		injectable = !strings.HasPrefix(columns, "distinct ") && !strings.HasPrefix(columns, "top ")
+	}
+	//行号列放在最后,和Oracle11gDialect一致,Query查询单列时忽略最后的行号列
+	//The row number column is placed last, consistent with Oracle11gDialect, Query ignores the last row number column when querying a single column
+	var locFrom []int
+	if injectable {
+		locFrom = findSelectFromIndex(sqlStr)
+	}
+	if len(locFrom) > 1 {
+		sqlBuilder.WriteString(strings.TrimSpace(sqlStr[:locFrom[0]]))
+		sqlBuilder.WriteString(",ROW_NUMBER() OVER (ORDER BY ")
+		sqlBuilder.WriteString(orderBy)
+		sqlBuilder.WriteString(") ")
+		sqlBuilder.WriteString(pageRowNumberColumn)
+		sqlBuilder.WriteString(" ")
+		sqlBuilder.WriteString(sqlStr[locFrom[0]:])
+	} else {
+		sqlBuilder.WriteString("SELECT grm_page_t.*,ROW_NUMBER() OVER (ORDER BY ")
+		sqlBuilder.WriteString(tableAliasRegexp.ReplaceAllString(orderBy, ""))
+		sqlBuilder.WriteString(") ")
+		sqlBuilder.WriteString(pageRowNumberColumn)
+		sqlBuilder.WriteString(" FROM (")
+		sqlBuilder.WriteString(sqlStr)
+		sqlBuilder.WriteString(") grm_page_t")
+	}
+	sqlBuilder.WriteString(") grm_page_o WHERE ")
+	sqlBuilder.WriteString(pageRowNumberColumn)
+	sqlBuilder.WriteString(" > ")
+	sqlBuilder.WriteInt(offset)
+	sqlBuilder.WriteString(" AND ")
+	sqlBuilder.WriteString(pageRowNumberColumn)
+	sqlBuilder.WriteString(" <= ")
+	sqlBuilder.WriteInt(offset + limit)
+	sqlBuilder.WriteString(" ORDER BY ")
+	sqlBuilder.WriteString(pageRowNumberColumn)
+	return sqlBuilder.String(), nil
+}
+
+// SQLiteDialect sqlite方言
+// SQLiteDialect sqlite dialect
+type SQLiteDialect struct{}
+
+// Name 方言的名称
+// Name The name of the dialect
+func (dialect SQLiteDialect) Name() string {
+	return "sqlite"
+}
+
+// BindVar 占位符 ?
+// BindVar Placeholder ?
+func (dialect SQLiteDialect) BindVar(i int) string {
+	return "?"
+}
+
+// LimitSQL LIMIT offset,limit
+func (dialect SQLiteDialect) LimitSQL(sqlStr string, offset int, limit int) (string, error) {
+	return limitCommaSQL(sqlStr, offset, limit), nil
+}
+
+// QuoteIdentifier 使用双引号转义
+// QuoteIdentifier Quote with double quotes
+func (dialect SQLiteDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, `"`, `"`)
+}
+
+// ReturningID 使用LastInsertId获取自增主键
+// ReturningID Use LastInsertId to get the auto-increment primary key
+func (dialect SQLiteDialect) ReturningID(pkColumn string) (string, ReturningIDMode) {
+	return "", ReturningIDLastInsertID
+}
+
+// UpsertSQL INSERT ... ON CONFLICT (a) DO UPDATE SET c=EXCLUDED.c
+func (dialect SQLiteDialect) UpsertSQL(tableName string, columns []string, conflictColumns []string, updateColumns []string, pkColumn string, pkSequence string) (string, error) {
+	return onConflictUpsertSQL(tableName, columns, conflictColumns, updateColumns, pkColumn, pkSequence)
+}
+
+// SavepointSQL SAVEPOINT name
+func (dialect SQLiteDialect) SavepointSQL(name string) string {
+	return "SAVEPOINT " + name
+}
+
+// RollbackToSavepointSQL ROLLBACK TO SAVEPOINT name
+func (dialect SQLiteDialect) RollbackToSavepointSQL(name string) string {
+	return "ROLLBACK TO SAVEPOINT " + name
+}
+
+// ReleaseSavepointSQL RELEASE SAVEPOINT name
+func (dialect SQLiteDialect) ReleaseSavepointSQL(name string) string {
+	return "RELEASE SAVEPOINT " + name
+}
+
+// ClickHouseDialect clickhouse方言,不支持Upsert和savepoint,UpdateFinder的语句改写为 ALTER TABLE ... UPDATE/DELETE
+// ClickHouseDialect clickhouse dialect, does not support Upsert and savepoint,
+// the statement of UpdateFinder is rewritten as ALTER TABLE ... UPDATE/DELETE
+type ClickHouseDialect struct{}
+
+// Name 方言的名称
+// Name The name of the dialect
+func (dialect ClickHouseDialect) Name() string {
+	return "clickhouse"
+}
+
+// BindVar 占位符 ?
+// BindVar Placeholder ?
+func (dialect ClickHouseDialect) BindVar(i int) string {
+	return "?"
+}
+
+// LimitSQL LIMIT offset,limit
+func (dialect ClickHouseDialect) LimitSQL(sqlStr string, offset int, limit int) (string, error) {
+	return limitCommaSQL(sqlStr, offset, limit), nil
+}
+
+// QuoteIdentifier 使用反引号转义
+// QuoteIdentifier Quote with backticks
+func (dialect ClickHouseDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, "`", "`")
+}
+
+// ReturningID clickhouse没有自增主键
+// ReturningID clickhouse has no auto-increment primary key
+func (dialect ClickHouseDialect) ReturningID(pkColumn string) (string, ReturningIDMode) {
+	return "", ReturningIDLastInsertID
+}
+
+// UpsertSQL clickhouse不支持
+// UpsertSQL clickhouse does not support
+func (dialect ClickHouseDialect) UpsertSQL(tableName string, columns []string, conflictColumns []string, updateColumns []string, pkColumn string, pkSequence string) (string, error) {
+	return "", errors.New("UpsertSQL-->不支持的数据库类型:clickhouse")
+}
+
+// SavepointSQL clickhouse不支持savepoint
+// SavepointSQL clickhouse does not support savepoint
+func (dialect ClickHouseDialect) SavepointSQL(name string) string {
+	return ""
+}
+
+// RollbackToSavepointSQL clickhouse不支持savepoint
+// RollbackToSavepointSQL clickhouse does not support savepoint
+func (dialect ClickHouseDialect) RollbackToSavepointSQL(name string) string {
+	return ""
+}
+
+// ReleaseSavepointSQL clickhouse不支持savepoint
+// ReleaseSavepointSQL clickhouse does not support savepoint
+func (dialect ClickHouseDialect) ReleaseSavepointSQL(name string) string {
+	return ""
+}
+
+// RewriteUpdateSQL 改写为clickhouse的 ALTER TABLE ... UPDATE 和 ALTER TABLE ... DELETE WHERE
+// RewriteUpdateSQL Rewrite as ALTER TABLE ... UPDATE and ALTER TABLE ... DELETE WHERE of clickhouse
+func (dialect ClickHouseDialect) RewriteUpdateSQL(sqlStr string) (string, error) {
+	//SQL语句的构造器
+	//SQL statement constructor
+	var sqlBuilder SQLBuilder
+	sqlBuilder.WriteString("ALTER TABLE ")
+	sqls := findUpdateTableName(&sqlStr)
+	if len(sqls) >= 2 { //如果是更新语句
+		sqlBuilder.WriteString(sqls[1])
+		sqlBuilder.WriteString(" UPDATE ")
+	} else { //如果不是更新语句
+		sqls = findDeleteTableName(&sqlStr)
+		if len(sqls) < 2 { //如果也不是删除语句
+			return sqlStr, nil
+		}
+		sqlBuilder.WriteString(sqls[1])
+		sqlBuilder.WriteString(" DELETE WHERE ")
+	}
+
+	//截取字符串
+	sqlBuilder.WriteString(sqlStr[len(sqls[0]):])
+	return sqlBuilder.String(), nil
+}
+
+// pageRowNumberColumn 旧版本数据库分页语句的行号列,查询结果会多返回这一列,Query和QueryMap会忽略
+// pageRowNumberColumn The row number column of the paging statement of the old version database,
+// the query result returns this extra column, Query and QueryMap ignore it
+const pageRowNumberColumn = "grm_page_rn"
+
+// tableAliasRegexp ORDER BY字段的表别名,例如 t.id 的 t.
+// tableAliasRegexp The table alias of the ORDER BY column, such as t. of t.id
+var tableAliasRegexp = regexp.MustCompile(`\b[A-Za-z_][A-Za-z0-9_]*\.`)
+
+// columnRefRegexp 字段名,可以带表别名,例如 name 和 t.name
+// columnRefRegexp Column name, can have a table alias, such as name and t.name
+var columnRefRegexp = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*\.)*[A-Za-z_][A-Za-z0-9_]*$`)
+
+// columnAliasRegexp 查询字段的别名,可以使用[]或者双引号转义
+// columnAliasRegexp The alias of the select item, can be quoted with [] or double quotes
+var columnAliasRegexp = regexp.MustCompile(`^(\[[^\]]+\]|"[^"]+"|[A-Za-z_][A-Za-z0-9_]*)$`)
+
+// selectOutputOrderBy 检查ORDER BY是否使用了查询字段的别名或者序号,序号替换为对应查询字段的列名,返回新的ORDER BY和是否使用了别名或者序号
+// 序号对应的查询字段没有列名时返回错误,例如 SELECT COUNT(*) FROM t ORDER BY 1
+// selectOutputOrderBy Check whether ORDER BY uses the alias or ordinal of the select list, the ordinal is replaced with the column name of the select item,
+// return the new ORDER BY and whether an alias or ordinal is used. Return an error if the select item of the ordinal has no column name,
+// such as SELECT COUNT(*) FROM t ORDER BY 1
+func selectOutputOrderBy(sqlStr string, orderBy string) (string, bool, error) {
+	names, aliases := selectColumnNames(sqlStr)
+	items := splitOrderByItems(orderBy)
+	selectOutput := false
+	for i, item := range items {
+		expr := item
+		direction := ""
+		fields := strings.Fields(item)
+		if last := strings.ToUpper(fields[len(fields)-1]); len(fields) > 1 && (last == "ASC" || last == "DESC") {
+			expr = strings.Join(fields[:len(fields)-1], " ")
+			direction = " " + last
+		}
+		if index, err := strconv.Atoi(expr); err == nil {
+			if index < 1 || index > len(names) || names[index-1] == "" {
+				return "", false, errors.New("selectOutputOrderBy-->ORDER BY的序号" + expr + "对应的查询字段没有列名,请使用别名: " + sqlStr)
+			}
+			items[i] = names[index-1] + direction
+			selectOutput = true
+		} else if aliases[strings.ToLower(strings.Trim(expr, `[]"`))] {
+			selectOutput = true
+		}
+	}
+	return strings.Join(items, ","), selectOutput, nil
+}
+
+// selectColumnNames 返回SELECT语句每个查询字段的列名和显式的别名,有别名使用别名,t.name 使用 name ,其他表达式是空字符串
+// selectColumnNames Return the column name of each select item and the explicit aliases of the SELECT statement,
+// use the alias if there is one, t.name uses name, and other expressions are empty strings
+func selectColumnNames(sqlStr string) ([]string, map[string]bool) {
+	aliases := make(map[string]bool)
+	sqlStr = strings.TrimSpace(sqlStr)
+	locFrom := findSelectFromIndex(sqlStr)
+	if len(locFrom) < 2 || !strings.HasPrefix(strings.ToLower(sqlStr), "select") {
+		return nil, aliases
+	}
+	columns := strings.TrimSpace(sqlStr[len("select"):locFrom[0]])
+	if strings.HasPrefix(strings.ToLower(columns), "distinct ") {
+		columns = columns[len("distinct "):]
+	}
+	items := splitOrderByItems(columns)
+	names := make([]string, len(items))
+	for i, item := range items {
+		fields := strings.Fields(item)
+		last := fields[len(fields)-1]
+		alias := ""
+		if len(fields) > 2 && strings.EqualFold(fields[len(fields)-2], "as") {
+			alias = last
+		} else if len(fields) > 1 && columnAliasRegexp.MatchString(last) && !strings.EqualFold(last, "end") {
+			//前一个字段以运算符结尾时,最后一个是表达式的一部分,例如 a + b
+			//When the previous field ends with an operator, the last one is part of the expression, such as a + b
+			prev := fields[len(fields)-2]
+			if strings.IndexByte("+-*/%=<>|&^~", prev[len(prev)-1]) < 0 {
+				alias = last
+			}
+		}
+		if alias != "" {
+			aliases[strings.ToLower(strings.Trim(alias, `[]"`))] = true
+			names[i] = alias
+		} else if columnRefRegexp.MatchString(item) {
+			names[i] = item[strings.LastIndex(item, ".")+1:]
+		}
+	}
+	return names, aliases
+}
+
+// limitCommaSQL LIMIT offset,limit 的分页语句,例如mysql,sqlite,clickhouse
+// limitCommaSQL LIMIT offset,limit paging statement, such as mysql, sqlite, clickhouse
+func limitCommaSQL(sqlStr string, offset int, limit int) string {
+	var sqlBuilder SQLBuilder
+	sqlBuilder.WriteString(sqlStr)
+	sqlBuilder.WriteString(" LIMIT ")
+	sqlBuilder.WriteInt(offset)
+	sqlBuilder.WriteString(",")
+	sqlBuilder.WriteInt(limit)
+	return sqlBuilder.String()
+}
+
+// offsetFetchSQL OFFSET ... FETCH NEXT 的分页语句,例如sqlserver 2012+,oracle 12c+
+// offsetFetchSQL OFFSET ... FETCH NEXT paging statement, such as sqlserver 2012+, oracle 12c+
+func offsetFetchSQL(sqlStr string, offset int, limit int) string {
+	var sqlBuilder SQLBuilder
+	sqlBuilder.WriteString(sqlStr)
+	sqlBuilder.WriteString(" OFFSET ")
+	sqlBuilder.WriteInt(offset)
+	sqlBuilder.WriteString(" ROWS FETCH NEXT ")
+	sqlBuilder.WriteInt(limit)
+	sqlBuilder.WriteString(" ROWS ONLY ")
+	return sqlBuilder.String()
+}
+
+// quoteIdentifier 使用left和right转义名称,带schema的名称分段转义,例如 schema.table,已经转义的名称不再处理
+// quoteIdentifier Quote the name with left and right, names with schema are quoted in segments, such as schema.table,
+// and names that have been quoted are not processed
+func quoteIdentifier(name string, left string, right string) string {
+	if name == "" || strings.HasPrefix(name, left) {
+		return name
+	}
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = left + strings.Replace(part, right, right+right, -1) + right
+	}
+	return strings.Join(parts, ".")
+}
+
+// writeInsertValuesSQL 写入 INSERT INTO t(pk,a,b) VALUES (seq,?,?) ,pkSequence为空时没有主键
+// writeInsertValuesSQL Write INSERT INTO t(pk,a,b) VALUES (seq,?,?), there is no primary key when pkSequence is empty
+func writeInsertValuesSQL(sqlBuilder *SQLBuilder, tableName string, columns []string, pkColumn string, pkSequence string) {
+	sqlBuilder.WriteString("INSERT INTO ")
+	sqlBuilder.WriteString(tableName)
+	sqlBuilder.WriteString("(")
+	if pkSequence != "" {
+		sqlBuilder.WriteString(pkColumn)
+		sqlBuilder.WriteString(",")
+	}
+	sqlBuilder.WriteString(strings.Join(columns, ","))
+	sqlBuilder.WriteString(") VALUES (")
+	if pkSequence != "" {
+		sqlBuilder.WriteString(pkSequence)
+		sqlBuilder.WriteString(",")
+	}
+	sqlBuilder.WriteString(strings.Repeat("?,", len(columns)))
+	sqlBuilder.RemoveEnd(1)
+	sqlBuilder.WriteString(")")
+}
+
+// onConflictUpsertSQL INSERT ... ON CONFLICT (a) DO UPDATE SET c=EXCLUDED.c ,例如postgresql,sqlite
+// onConflictUpsertSQL INSERT ... ON CONFLICT (a) DO UPDATE SET c=EXCLUDED.c, such as postgresql, sqlite
+func onConflictUpsertSQL(tableName string, columns []string, conflictColumns []string, updateColumns []string, pkColumn string, pkSequence string) (string, error) {
+	if len(columns) < 1 || len(conflictColumns) < 1 {
+		return "", errors.New("UpsertSQL-->columns和conflictColumns不能为空")
+	}
+	var sqlBuilder SQLBuilder
+	writeInsertValuesSQL(&sqlBuilder, tableName, columns, pkColumn, pkSequence)
+	sqlBuilder.WriteString(" ON CONFLICT (")
+	sqlBuilder.WriteString(strings.Join(conflictColumns, ","))
+	if len(updateColumns) < 1 {
+		sqlBuilder.WriteString(") DO NOTHING")
+		return sqlBuilder.String(), nil
+	}
+	sqlBuilder.WriteString(") DO UPDATE SET ")
+	for i, column := range updateColumns {
+		if i > 0 {
+			sqlBuilder.WriteString(",")
+		}
+		sqlBuilder.WriteString(column)
+		sqlBuilder.WriteString("=EXCLUDED.")
+		sqlBuilder.WriteString(column)
+	}
+	return sqlBuilder.String(), nil
+}
+
+// mergeUpsertSQL MERGE INTO ... USING (SELECT ...) ON (...) WHEN MATCHED THEN UPDATE ... WHEN NOT MATCHED THEN INSERT ...
+// fromDual是SELECT需要的FROM语句,例如oracle的 FROM dual,terminator是语句的结束符,例如sqlserver的;
+// mergeUpsertSQL fromDual is the FROM statement required by SELECT, such as FROM dual of oracle, terminator is the end of the statement, such as ; of sqlserver
+func mergeUpsertSQL(tableName string, columns []string, conflictColumns []string, updateColumns []string, pkColumn string, pkSequence string, fromDual string, terminator string) (string, error) {
+	if len(columns) < 1 || len(conflictColumns) < 1 {
+		return "", errors.New("UpsertSQL-->columns和conflictColumns不能为空")
+	}
+	var sqlBuilder SQLBuilder
+	sqlBuilder.WriteString("MERGE INTO ")
+	sqlBuilder.WriteString(tableName)
+	sqlBuilder.WriteString(" grm_target USING (SELECT ")
+	for i, column := range columns {
+		if i > 0 {
+			sqlBuilder.WriteString(",")
+		}
+		sqlBuilder.WriteString("? ")
+		sqlBuilder.WriteString(column)
+	}
+	sqlBuilder.WriteString(fromDual)
+	sqlBuilder.WriteString(") grm_source ON (")
+	for i, column := range conflictColumns {
+		if i > 0 {
+			sqlBuilder.WriteString(" AND ")
+		}
+		sqlBuilder.WriteString("grm_target.")
+		sqlBuilder.WriteString(column)
+		sqlBuilder.WriteString("=grm_source.")
+		sqlBuilder.WriteString(column)
+	}
+	sqlBuilder.WriteString(")")
+	if len(updateColumns) > 0 {
+		sqlBuilder.WriteString(" WHEN MATCHED THEN UPDATE SET ")
+		for i, column := range updateColumns {
+			if i > 0 {
+				sqlBuilder.WriteString(",")
+			}
+			sqlBuilder.WriteString("grm_target.")
+			sqlBuilder.WriteString(column)
+			sqlBuilder.WriteString("=grm_source.")
+			sqlBuilder.WriteString(column)
+		}
+	}
+	sqlBuilder.WriteString(" WHEN NOT MATCHED THEN INSERT (")
+	if pkSequence != "" {
+		sqlBuilder.WriteString(pkColumn)
+		sqlBuilder.WriteString(",")
+	}
+	sqlBuilder.WriteString(strings.Join(columns, ","))
+	sqlBuilder.WriteString(") VALUES (")
+	if pkSequence != "" {
+		sqlBuilder.WriteString(pkSequence)
+		sqlBuilder.WriteString(",")
+	}
+	for i, column := range columns {
+		if i > 0 {
+			sqlBuilder.WriteString(",")
+		}
+		sqlBuilder.WriteString("grm_source.")
+		sqlBuilder.WriteString(column)
+	}
+	sqlBuilder.WriteString(")")
+	sqlBuilder.WriteString(terminator)
+	return sqlBuilder.String(), nil
+}
+
+// DMDialect 达梦数据库方言,驱动名称是dm.分页使用 LIMIT offset,limit ,自增主键使用IDENTITY,驱动的LastInsertId返回IDENTITY的值
+// 默认注册*dm.DmClob的类型转换,TEXT和CLOB字段可以直接使用string接收
+// DMDialect Dameng database dialect, the driver name is dm. Paging uses LIMIT offset,limit, the auto-increment primary key uses IDENTITY,
+// and the LastInsertId of the driver returns the value of IDENTITY. The type conversion of *dm.DmClob is registered by default,
+// and the TEXT and CLOB columns can be received directly with string
+type DMDialect struct{}
+
+// Name 方言的名称
+// Name The name of the dialect
+func (dialect DMDialect) Name() string {
+	return "dm"
+}
+
+// BindVar 占位符 ?
+// BindVar Placeholder ?
+func (dialect DMDialect) BindVar(i int) string {
+	return "?"
+}
+
+// LimitSQL LIMIT offset,limit
+func (dialect DMDialect) LimitSQL(sqlStr string, offset int, limit int) (string, error) {
+	return limitCommaSQL(sqlStr, offset, limit), nil
+}
+
+// QuoteIdentifier 使用双引号转义
+// QuoteIdentifier Quote with double quotes
+func (dialect DMDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name, `"`, `"`)
+}
+
+// ReturningID 使用LastInsertId获取IDENTITY自增主键
+// ReturningID Use LastInsertId to get the IDENTITY auto-increment primary key
+func (dialect DMDialect) ReturningID(pkColumn string) (string, ReturningIDMode) {
+	return "", ReturningIDLastInsertID
+}
+
+// UpsertSQL MERGE INTO ... USING (SELECT ... FROM dual) ...
+func (dialect DMDialect) UpsertSQL(tableName string, columns []string, conflictColumns []string, updateColumns []string, pkColumn string, pkSequence string) (string, error) {
+	return mergeUpsertSQL(tableName, columns, conflictColumns, updateColumns, pkColumn, pkSequence, " FROM dual", "")
+}
+
+// SavepointSQL SAVEPOINT name
+func (dialect DMDialect) SavepointSQL(name string) string {
+	return "SAVEPOINT " + name
+}
+
+// RollbackToSavepointSQL ROLLBACK TO SAVEPOINT name
+func (dialect DMDialect) RollbackToSavepointSQL(name string) string {
+	return "ROLLBACK TO SAVEPOINT " + name
+}
+
+// ReleaseSavepointSQL 达梦没有释放savepoint的语法
+// ReleaseSavepointSQL dm has no syntax to release savepoint
+func (dialect DMDialect) ReleaseSavepointSQL(name string) string {
+	return ""
+}
+
+// CustomDriverValues *dm.DmClob 转换为string
+// CustomDriverValues *dm.DmClob is converted to string
+func (dialect DMDialect) CustomDriverValues() map[string]CustomDriverValueConvert {
+	return map[string]CustomDriverValueConvert{"*dm.DmClob": dmClobConvert{}}
+}
+
+// KingbaseDialect 人大金仓KingbaseES方言,驱动名称是kingbase,兼容postgresql的语法
+// KingbaseDialect KingbaseES dialect, the driver name is kingbase, compatible with the syntax of postgresql
+type KingbaseDialect struct {
+	PostgreSQLDialect
+}
+
+// Name 方言的名称
+// Name The name of the dialect
+func (dialect KingbaseDialect) Name() string {
+	return "kingbase"
+}
+
+// OpenGaussDialect openGauss方言,驱动名称是opengauss,兼容postgresql的语法,Upsert使用 ON DUPLICATE KEY UPDATE
+// OpenGaussDialect openGauss dialect, the driver name is opengauss, compatible with the syntax of postgresql, Upsert uses ON DUPLICATE KEY UPDATE
+type OpenGaussDialect struct {
+	PostgreSQLDialect
+}
+
+// Name 方言的名称
+// Name The name of the dialect
+func (dialect OpenGaussDialect) Name() string {
+	return "opengauss"
+}
+
+// UpsertSQL INSERT ... ON DUPLICATE KEY UPDATE c=EXCLUDED.c ,根据表的唯一索引判断冲突,冲突不更新使用 UPDATE NOTHING
+// UpsertSQL INSERT ... ON DUPLICATE KEY UPDATE c=EXCLUDED.c, judge the conflict according to the unique index of the table,
+// use UPDATE NOTHING when there is no update on conflict
+func (dialect OpenGaussDialect) UpsertSQL(tableName string, columns []string, conflictColumns []string, updateColumns []string, pkColumn string, pkSequence string) (string, error) {
+	if len(columns) < 1 || len(conflictColumns) < 1 {
+		return "", errors.New("UpsertSQL-->columns和conflictColumns不能为空")
+	}
+	var sqlBuilder SQLBuilder
+	writeInsertValuesSQL(&sqlBuilder, tableName, columns, pkColumn, pkSequence)
+	sqlBuilder.WriteString(" ON DUPLICATE KEY UPDATE ")
+	if len(updateColumns) < 1 {
+		sqlBuilder.WriteString("NOTHING")
+		return sqlBuilder.String(), nil
+	}
+	for i, column := range updateColumns {
+		if i > 0 {
+			sqlBuilder.WriteString(",")
+		}
+		sqlBuilder.WriteString(column)
+		sqlBuilder.WriteString("=EXCLUDED.")
+		sqlBuilder.WriteString(column)
+	}
+	return sqlBuilder.String(), nil
+}
+
+// ReplicaLagSQL openGauss没有pg_last_wal_receive_lsn(),使用pg_last_xlog_receive_location()和pg_last_xlog_replay_location()
+// ReplicaLagSQL openGauss has no pg_last_wal_receive_lsn(), use pg_last_xlog_receive_location() and pg_last_xlog_replay_location()
+func (dialect OpenGaussDialect) ReplicaLagSQL() string {
+	return "SELECT COALESCE(CASE WHEN pg_last_xlog_receive_location() = pg_last_xlog_replay_location() THEN 0 ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END, 0)"
+}
+
+// limitLockSkipSQL SELECT ... FROM tableName alias WHERE ... ORDER BY ... LIMIT limit FOR UPDATE SKIP LOCKED
+func limitLockSkipSQL(tableName string, alias string, columns string, where string, orderBy string, limit int) string {
+	var sqlBuilder SQLBuilder
+	sqlBuilder.WriteString("SELECT ")
+	sqlBuilder.WriteString(columns)
+	sqlBuilder.WriteString(" FROM ")
+	sqlBuilder.WriteString(tableName)
+	sqlBuilder.WriteString(" ")
+	sqlBuilder.WriteString(alias)
+	sqlBuilder.WriteString(" WHERE ")
+	sqlBuilder.WriteString(where)
+	sqlBuilder.WriteString(" ORDER BY ")
+	sqlBuilder.WriteString(orderBy)
+	sqlBuilder.WriteString(" LIMIT ")
+	sqlBuilder.WriteInt(limit)
+	sqlBuilder.WriteString(" FOR UPDATE SKIP LOCKED")
+	return sqlBuilder.String()
+}
+
+// dmClob *dm.DmClob 读取内容的方法,不依赖达梦的驱动包
+// dmClob The method of *dm.DmClob to read the content, does not depend on the driver package of dm
+type dmClob interface {
+	GetLength() (int64, error)
+	ReadString(pos int, length int) (string, error)
+}
+
+// dmClobConvert 达梦的TEXT和CLOB字段转换为string,支持string和*string类型的字段
+// dmClobConvert The TEXT and CLOB columns of dm are converted to string, supporting fields of type string and *string
+type dmClobConvert struct{}
+
+// GetDriverValue 使用*interface{}接收驱动返回的*dm.DmClob
+// GetDriverValue Use *interface{} to receive the *dm.DmClob returned by the driver
+func (convert dmClobConvert) GetDriverValue(columnType *sql.ColumnType, structFieldType *reflect.Type, finder *Finder) (driver.Value, error) {
+	return new(interface{}), nil
+}
+
+// ConvertDriverValue 读取*dm.DmClob的内容,返回接收类型值的指针
+// ConvertDriverValue Read the content of *dm.DmClob and return the pointer of the receiving type value
+func (convert dmClobConvert) ConvertDriverValue(columnType *sql.ColumnType, structFieldType *reflect.Type, tempDriverValue driver.Value, finder *Finder) (interface{}, error) {
+	var text string
+	if valuePtr, ok := tempDriverValue.(*interface{}); ok && *valuePtr != nil {
+		clob, ok := (*valuePtr).(dmClob)
+		if !ok {
+			return nil, fmt.Errorf("dmClobConvert-->类型%T不是*dm.DmClob", *valuePtr)
+		}
+		length, err := clob.GetLength()
+		if err != nil {
+			return nil, err
+		}
+		if length > 0 {
+			text, err = clob.ReadString(1, int(length))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	//Map查询没有字段类型
+	//Map query has no field type
+	if structFieldType == nil {
+		return &text, nil
+	}
+	fieldType := *structFieldType
+	result := reflect.New(fieldType)
+	if fieldType.Kind() == reflect.String {
+		result.Elem().SetString(text)
+	} else if fieldType.Kind() == reflect.Ptr && fieldType.Elem().Kind() == reflect.String {
+		textValue := reflect.New(fieldType.Elem())
+		textValue.Elem().SetString(text)
+		result.Elem().Set(textValue)
+	} else {
+		return nil, errors.New("dmClobConvert-->不支持的字段类型" + fieldType.String() + ",请使用string或者*string")
+	}
+	return result.Interface(), nil
+}
@@ -0,0 +1,256 @@
+package grm
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Dialect 数据库方言,生成和数据库相关的SQL语法.使用RegisterDialect注册,DBConfig.Dialect对应Dialect.Name()
+// 内置了mysql,postgresql,oracle,mssql,sqlite,clickhouse,dm,kingbase,opengauss,可以嵌入内置的方言实现其他数据库,例如TiDB嵌入MySQLDialect
+// Dialect Database dialect, generates SQL syntax related to the database. Register with RegisterDialect, DBConfig.Dialect corresponds to Dialect.Name()
+// Built-in mysql, postgresql, oracle, mssql, sqlite, clickhouse, dm, kingbase, opengauss, other databases can be implemented by embedding the built-in dialect, for example TiDB embeds MySQLDialect
+type Dialect interface {
+	// Name 方言的名称,例如mysql,也是IEntityStruct.GetPkSequence()的key
+	// Name The name of the dialect, such as mysql, which is also the key of IEntityStruct.GetPkSequence()
+	Name() string
+
+	// BindVar 第i个参数的占位符,i从1开始,例如mysql的?,postgresql的$1
+	// BindVar The placeholder of the i-th parameter, i starts from 1, such as ? of mysql, $1 of postgresql
+	BindVar(i int) string
+
+	// LimitSQL 包装从offset开始,最多limit条的查询语句,参数使用?占位符
+	// LimitSQL Wrap the query statement starting from offset, up to limit rows, the parameters use the ? placeholder
+	LimitSQL(sqlStr string, offset int, limit int) (string, error)
+
+	// QuoteIdentifier 转义表名或者字段名,例如mysql的`order`
+	// QuoteIdentifier Quote the table name or column name, such as `order` of mysql
+	QuoteIdentifier(name string) string
+
+	// ReturningID 保存语句获取自增主键的方式,返回拼接到保存语句后面的语句和获取方式,参见ReturningIDMode
+	// ReturningID The way the insert statement gets the auto-increment primary key,
+	// returns the statement appended to the insert statement and the way to get it, see ReturningIDMode
+	ReturningID(pkColumn string) (string, ReturningIDMode)
+
+	// UpsertSQL 保存语句,conflictColumns冲突时更新updateColumns,updateColumns为空时冲突不更新,参数使用?占位符,顺序和columns一致
+	// pkSequence不为空时,pkColumn使用序列生成主键的值,参见Upsert
+	// UpsertSQL Insert statement, update updateColumns when conflictColumns conflicts, no update when updateColumns is empty,
+	// the parameters use the ? placeholder, and the order is consistent with columns.
+	// When pkSequence is not empty, pkColumn uses the sequence to generate the value of the primary key, see Upsert
+	UpsertSQL(tableName string, columns []string, conflictColumns []string, updateColumns []string, pkColumn string, pkSequence string) (string, error)
+
+	// SavepointSQL 创建savepoint的语句,返回""代表不支持savepoint
+	// SavepointSQL The statement to create a savepoint, return "" means savepoint is not supported
+	SavepointSQL(name string) string
+
+	// RollbackToSavepointSQL 回滚到savepoint的语句
+	// RollbackToSavepointSQL The statement to roll back to the savepoint
+	RollbackToSavepointSQL(name string) string
+
+	// ReleaseSavepointSQL 释放savepoint的语句,返回""代表没有释放的语法,不需要执行
+	// ReleaseSavepointSQL The statement to release the savepoint, return "" means there is no release syntax and no need to execute
+	ReleaseSavepointSQL(name string) string
+}
+
+// UpdateSQLRewriter 方言可选实现的接口,改写UpdateFinder手动编写的UPDATE和DELETE语句,例如clickhouse的 ALTER TABLE ... UPDATE
+// UpdateSQLRewriter An optional interface implemented by dialects to rewrite the UPDATE and DELETE statements manually written by UpdateFinder,
+// such as ALTER TABLE ... UPDATE of clickhouse
+type UpdateSQLRewriter interface {
+	RewriteUpdateSQL(sqlStr string) (string, error)
+}
+
+// CustomDriverValueDialect 方言可选实现的接口,返回数据库默认的类型转换,RegisterDialect时添加到CustomDriverValueMap,不覆盖已经存在的key
+// 例如达梦的 *dm.DmClob 转换为string
+// CustomDriverValueDialect An optional interface implemented by dialects to return the default type conversions of the database,
+// which are added to CustomDriverValueMap when RegisterDialect, and existing keys are not overwritten. For example, *dm.DmClob of dm is converted to string
+type CustomDriverValueDialect interface {
+	CustomDriverValues() map[string]CustomDriverValueConvert
+}
+
+// DialectVersioner 方言可选实现的接口,根据数据库的主版本号使用不同的语法,例如oracle 11g和sqlserver 2008的分页.参见DBConfig.DialectVersion
+// DialectVersioner An optional interface implemented by dialects to use different syntax according to the major version of the database,
+// such as the paging of oracle 11g and sqlserver 2008. See DBConfig.DialectVersion
+type DialectVersioner interface {
+	// VersionSQL 查询数据库版本的语句,返回一行一列,以主版本号开头,例如 11.2.0.4.0
+	// VersionSQL The statement to query the database version, returns one row and one column, starting with the major version, such as 11.2.0.4.0
+	VersionSQL() string
+	// ForVersion 返回主版本号对应的方言,Name()必须和当前方言一致
+	// ForVersion Return the dialect corresponding to the major version, Name() must be consistent with the current dialect
+	ForVersion(version int) Dialect
+}
+
+// XADialect 方言可选实现的接口,生成XA分布式事务分支的语句,参见XACoordinator.返回""代表数据库在这一步不需要执行语句
+// XADialect An optional interface implemented by dialects to generate the statements of the XA distributed transaction branch, see XACoordinator.
+// Return "" means the database does not need to execute a statement in this step
+type XADialect interface {
+	// XAStartSQL 开启分支事务的语句,例如mysql的 XA START 'xid'
+	// XAStartSQL The statement to start the branch transaction, such as XA START 'xid' of mysql
+	XAStartSQL(xid string) string
+	// XAEndSQL 结束分支事务的语句,例如mysql的 XA END 'xid'
+	// XAEndSQL The statement to end the branch transaction, such as XA END 'xid' of mysql
+	XAEndSQL(xid string) string
+	// XAPrepareSQL prepare分支事务的语句,例如postgresql的 PREPARE TRANSACTION 'xid'
+	// XAPrepareSQL The statement to prepare the branch transaction, such as PREPARE TRANSACTION 'xid' of postgresql
+	XAPrepareSQL(xid string) string
+	// XACommitSQL 提交已经prepare的分支事务的语句,例如postgresql的 COMMIT PREPARED 'xid'
+	// XACommitSQL The statement to commit the prepared branch transaction, such as COMMIT PREPARED 'xid' of postgresql
+	XACommitSQL(xid string) string
+	// XARollbackSQL 回滚分支事务的语句,prepared代表分支事务已经prepare
+	// XARollbackSQL The statement to roll back the branch transaction, prepared means the branch transaction has been prepared
+	XARollbackSQL(xid string, prepared bool) string
+	// XARecoverSQL 查询悬挂(已prepare未提交)的分支事务的语句
+	// XARecoverSQL The statement to query the in-doubt (prepared but not committed) branch transactions
+	XARecoverSQL() string
+	// ScanXID 从XARecoverSQL的一行结果中读取xid
+	// ScanXID Read the xid from a row of the result of XARecoverSQL
+	ScanXID(rows *sql.Rows) (string, error)
+}
+
+// ReplicaLagDialect 方言可选实现的接口,查询从库的复制延迟,没有实现的数据库使用心跳表,参见ReplicaSetConfig.HeartbeatTable
+// ReplicaLagDialect An optional interface implemented by dialects to query the replication lag of the replica,
+// databases that are not implemented use the heartbeat table, see ReplicaSetConfig.HeartbeatTable
+type ReplicaLagDialect interface {
+	// ReplicaLagSQL 查询复制延迟的语句,返回一列延迟的秒数,或者包含Seconds_Behind_Master/Seconds_Behind_Source列的一行,没有结果代表不是从库
+	// ReplicaLagSQL The statement to query the replication lag, returns one column of lag seconds,
+	// or a row containing the Seconds_Behind_Master/Seconds_Behind_Source column, no result means it is not a replica
+	ReplicaLagSQL() string
+}
+
+// LockSkipDialect 方言可选实现的接口,生成锁定查询的行并跳过其他事务已经锁定的行的语句,用于多个实例并发消费同一个表,例如outbox的Relay
+// LockSkipDialect An optional interface implemented by dialects to generate the statement that locks the queried rows and skips the rows
+// already locked by other transactions, used for multiple instances to consume the same table concurrently, such as the Relay of outbox
+type LockSkipDialect interface {
+	// LockSkipSQL 查询tableName中满足where条件的最多limit行,按照orderBy排序.columns,where和orderBy使用表别名alias
+	// LockSkipSQL Query up to limit rows that meet the where condition in tableName, sorted by orderBy. columns, where and orderBy use the table alias
+	LockSkipSQL(tableName string, alias string, columns string, where string, orderBy string, limit int) string
+}
+
+// ReturningIDMode 保存后获取自增主键的方式
+// ReturningIDMode The way to get the auto-increment primary key after inserting
+type ReturningIDMode int
+
+const (
+	// ReturningIDLastInsertID 使用sql.Result.LastInsertId获取,例如mysql,sqlite
+	// ReturningIDLastInsertID Use sql.Result.LastInsertId to get, such as mysql, sqlite
+	ReturningIDLastInsertID ReturningIDMode = iota
+	// ReturningIDQueryRow 拼接返回语句后使用QueryRow查询,例如postgresql的 RETURNING id
+	// ReturningIDQueryRow Use QueryRow to query after appending the returning statement, such as RETURNING id of postgresql
+	ReturningIDQueryRow
+	// ReturningIDOutParam 拼接返回语句后使用名称为grmSQLOutReturningID的sql.Out参数接收,例如oracle的 RETURNING id INTO :grmSQLOutReturningID
+	// ReturningIDOutParam Use the sql.Out parameter named grmSQLOutReturningID to receive after appending the returning statement,
+	// such as RETURNING id INTO :grmSQLOutReturningID of oracle
+	ReturningIDOutParam
+)
+
+// dialectMap 注册的方言,key是Dialect.Name()
+// dialectMap Registered dialects, the key is Dialect.Name()
+var dialectMap *sync.Map = &sync.Map{}
+
+// customDriverValueLock 注册方言时添加CustomDriverValueMap的锁
+// customDriverValueLock The lock for adding CustomDriverValueMap when registering dialects
+var customDriverValueLock sync.Mutex
+
+func init() {
+	builtinDialects := []Dialect{MySQLDialect{}, PostgreSQLDialect{}, OracleDialect{}, MSSQLDialect{}, SQLiteDialect{}, ClickHouseDialect{},
+		DMDialect{}, KingbaseDialect{}, OpenGaussDialect{}}
+	for _, dialect := range builtinDialects {
+		dialectMap.Store(dialect.Name(), dialect)
+		registerCustomDriverValues(dialect)
+	}
+}
+
+// RegisterDialect 注册数据库方言,同名的方言会被覆盖,可以替换内置的方言.一般是放到init方法里注册
+// 方言实现了CustomDriverValueDialect时,同时添加到CustomDriverValueMap,查询时不加锁读取,所以要在查询之前注册
+// RegisterDialect Register the database dialect, the dialect with the same name will be overwritten,
+// and the built-in dialect can be replaced. Generally registered in the init method.
+// When the dialect implements CustomDriverValueDialect, it is also added to CustomDriverValueMap,
+// which is read without lock when querying, so it must be registered before querying
+func RegisterDialect(dialect Dialect) error {
+	if dialect == nil {
+		return errors.New("RegisterDialect-->dialect不能为nil")
+	}
+	name := strings.TrimSpace(dialect.Name())
+	if name == "" {
+		return errors.New("RegisterDialect-->dialect.Name()不能为空")
+	}
+	dialectMap.Store(name, dialect)
+	registerCustomDriverValues(dialect)
+	return nil
+}
+
+// getDialect 根据名称获取注册的方言
+// getDialect Get the registered dialect by name
+func getDialect(name string) (Dialect, error) {
+	dialect, ok := dialectMap.Load(name)
+	if !ok {
+		return nil, errors.New("getDialect-->不支持的数据库类型:" + name + ",请使用grm.RegisterDialect注册方言")
+	}
+	return dialect.(Dialect), nil
+}
+
+// registerCustomDriverValues 把方言默认的类型转换添加到CustomDriverValueMap,不覆盖已经存在的key
+// registerCustomDriverValues Add the default type conversions of the dialect to CustomDriverValueMap, existing keys are not overwritten
+func registerCustomDriverValues(dialect Dialect) {
+	customDialect, ok := dialect.(CustomDriverValueDialect)
+	if !ok {
+		return
+	}
+	customDriverValueLock.Lock()
+	defer customDriverValueLock.Unlock()
+	for key, convert := range customDialect.CustomDriverValues() {
+		if _, has := CustomDriverValueMap[key]; !has {
+			CustomDriverValueMap[key] = convert
+		}
+	}
+}
+
+// getConfigDialect 获取数据库配置的方言,DBConfig.DialectVersion大于0时使用主版本号对应的方言
+// getConfigDialect Get the dialect of the database configuration, when DBConfig.DialectVersion is greater than 0, use the dialect corresponding to the major version
+func getConfigDialect(config *DBConfig) (Dialect, error) {
+	if config == nil {
+		return nil, errors.New("getConfigDialect-->config不能为nil")
+	}
+	dialect, err := getDialect(config.Dialect)
+	if err != nil {
+		return nil, err
+	}
+	if versioner, ok := dialect.(DialectVersioner); ok && config.DialectVersion > 0 {
+		return versioner.ForVersion(config.DialectVersion), nil
+	}
+	return dialect, nil
+}
+
+// detectDialectVersion DBConfig.DialectVersion为0时,查询数据库的主版本号.查询失败记录日志,使用新版本的语法
+// detectDialectVersion When DBConfig.DialectVersion is 0, query the major version of the database.
+// If the query fails, log it and use the syntax of the new version
+func detectDialectVersion(config *DBConfig, db *sql.DB) {
+	if config.DialectVersion != 0 {
+		return
+	}
+	dialect, err := getDialect(config.Dialect)
+	if err != nil {
+		return
+	}
+	versioner, ok := dialect.(DialectVersioner)
+	if !ok {
+		return
+	}
+	var version string
+	if err = db.QueryRow(versioner.VersionSQL()).Scan(&version); err != nil {
+		LogErr("detectDialectVersion-->查询数据库版本错误,使用新版本的语法: " + err.Error())
+		return
+	}
+	version = strings.TrimSpace(version)
+	end := 0
+	for end < len(version) && version[end] >= '0' && version[end] <= '9' {
+		end++
+	}
+	major, err := strconv.Atoi(version[:end])
+	if err != nil {
+		LogErr("detectDialectVersion-->解析数据库版本错误,使用新版本的语法: " + version)
+		return
+	}
+	config.DialectVersion = major
+}
@@ -0,0 +1,642 @@
+package grm
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// userRole 联合主键测试的实体类
+// userRole The entity of the composite primary key tests
+type userRole struct {
+	EntityStruct
+	UserID int    `column:"user_id"`
+	RoleID int    `column:"role_id"`
+	Remark string `column:"remark"`
+}
+
+func (entity *userRole) TableName() string {
+	return "t_user_role"
+}
+
+func (entity *userRole) PKs() []string {
+	return []string{"user_id", "role_id"}
+}
+
+func TestWrapDeleteSQLCompositePK(t *testing.T) {
+	tests := []struct {
+		name   string
+		config DBConfig
+		tenant *tenantCondition
+		want   string
+	}{
+		{"mysql", DBConfig{Dialect: "mysql"}, nil, "DELETE FROM t_user_role WHERE user_id=? AND role_id=?"},
+		{"postgresql", DBConfig{Dialect: "postgresql"}, nil, "DELETE FROM t_user_role WHERE user_id=$1 AND role_id=$2"},
+		{"tenant", DBConfig{Dialect: "oracle"}, &tenantCondition{column: "tenant_id"}, "DELETE FROM t_user_role WHERE user_id=:1 AND role_id=:2 AND tenant_id=:3"},
+		{"quote", DBConfig{Dialect: "mssql", QuoteIdentifier: true}, nil, "DELETE FROM [t_user_role] WHERE [user_id]=@p1 AND [role_id]=@p2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := wrapDeleteSQL(&tt.config, "t_user_role", &userRole{}, tt.tenant)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("wrapDeleteSQL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompositePK(t *testing.T) {
+	tests := []struct {
+		name    string
+		entity  *userRole
+		write   func(ctx context.Context, entity *userRole) (int, error)
+		wantErr bool
+		want    string
+	}{
+		{
+			name:   "Update",
+			entity: &userRole{UserID: 1, RoleID: 2, Remark: "grm"},
+			write:  func(ctx context.Context, entity *userRole) (int, error) { return Update(ctx, entity) },
+			want:   "UPDATE t_user_role SET remark=? WHERE user_id=? AND role_id=?",
+		},
+		{
+			name:   "Delete",
+			entity: &userRole{UserID: 1, RoleID: 2},
+			write:  func(ctx context.Context, entity *userRole) (int, error) { return Delete(ctx, entity) },
+			want:   "DELETE FROM t_user_role WHERE user_id=? AND role_id=?",
+		},
+		{
+			name:    "zero value key",
+			entity:  &userRole{UserID: 1},
+			write:   func(ctx context.Context, entity *userRole) (int, error) { return Delete(ctx, entity) },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+			var err error
+			Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+				_, err = tt.write(ctx, tt.entity)
+				return nil, err
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				//联合主键有零值时不执行语句
+				//The statement is not executed when the composite primary key has a zero value
+				assertStatements(t, db.take(), []string{"BEGIN", "ROLLBACK"})
+				return
+			}
+			assertStatements(t, db.take(), []string{"BEGIN", tt.want, "COMMIT"})
+		})
+	}
+}
+
+func TestWrapUpsertSQL(t *testing.T) {
+	columns := []string{"id", "name", "update_time"}
+	tests := []struct {
+		dialect string
+		update  []string
+		want    string
+		wantErr bool
+	}{
+		{"mysql", []string{"name", "update_time"}, "INSERT INTO t_demo(id,name,update_time) VALUES (?,?,?) ON DUPLICATE KEY UPDATE name=VALUES(name),update_time=VALUES(update_time)", false},
+		{"mysql", nil, "INSERT INTO t_demo(id,name,update_time) VALUES (?,?,?) ON DUPLICATE KEY UPDATE id=id", false},
+		{"postgresql", []string{"name", "update_time"}, "INSERT INTO t_demo(id,name,update_time) VALUES (?,?,?) ON CONFLICT (id) DO UPDATE SET name=EXCLUDED.name,update_time=EXCLUDED.update_time", false},
+		{"sqlite", nil, "INSERT INTO t_demo(id,name,update_time) VALUES (?,?,?) ON CONFLICT (id) DO NOTHING", false},
+		{"opengauss", nil, "INSERT INTO t_demo(id,name,update_time) VALUES (?,?,?) ON DUPLICATE KEY UPDATE NOTHING", false},
+		{"oracle", []string{"name"}, "MERGE INTO t_demo grm_target USING (SELECT ? id,? name,? update_time FROM dual) grm_source ON (grm_target.id=grm_source.id) WHEN MATCHED THEN UPDATE SET grm_target.name=grm_source.name WHEN NOT MATCHED THEN INSERT (id,name,update_time) VALUES (grm_source.id,grm_source.name,grm_source.update_time)", false},
+		{"mssql", nil, "MERGE INTO t_demo grm_target USING (SELECT ? id,? name,? update_time) grm_source ON (grm_target.id=grm_source.id) WHEN NOT MATCHED THEN INSERT (id,name,update_time) VALUES (grm_source.id,grm_source.name,grm_source.update_time);", false},
+		{"clickhouse", nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			got, err := wrapUpsertSQL(&DBConfig{Dialect: tt.dialect}, "t_demo", columns, []string{"id"}, tt.update, "id", "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("wrapUpsertSQL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// tidbDialect 嵌入MySQLDialect的自定义方言
+// tidbDialect The custom dialect that embeds MySQLDialect
+type tidbDialect struct {
+	MySQLDialect
+}
+
+func (dialect tidbDialect) Name() string {
+	return "tidb"
+}
+
+func TestRegisterDialect(t *testing.T) {
+	if err := RegisterDialect(tidbDialect{}); err != nil {
+		t.Fatal(err)
+	}
+	defer dialectMap.Delete("tidb")
+	config := &DBConfig{Dialect: "tidb", QuoteIdentifier: true}
+	got, err := wrapDeleteSQL(config, "t_user_role", &userRole{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "DELETE FROM `t_user_role` WHERE `user_id`=? AND `role_id`=?"; got != want {
+		t.Errorf("wrapDeleteSQL() = %q, want %q", got, want)
+	}
+	if got, _ = wrapLimitSQL(config, "SELECT * FROM t_demo", 20, 10); got != "SELECT * FROM t_demo LIMIT 20,10" {
+		t.Errorf("wrapLimitSQL() = %q", got)
+	}
+	if err = RegisterDialect(nil); err == nil {
+		t.Error("RegisterDialect(nil)没有返回错误")
+	}
+}
+
+// clobDialect 实现了CustomDriverValueDialect的自定义方言
+// clobDialect The custom dialect that implements CustomDriverValueDialect
+type clobDialect struct {
+	DMDialect
+}
+
+func (dialect clobDialect) Name() string {
+	return "clob"
+}
+
+func (dialect clobDialect) CustomDriverValues() map[string]CustomDriverValueConvert {
+	return map[string]CustomDriverValueConvert{"*dm.DmClob": clobDialectConvert{}, "*clob.Clob": clobDialectConvert{}}
+}
+
+type clobDialectConvert struct {
+	dmClobConvert
+}
+
+func TestRegisterCustomDriverValues(t *testing.T) {
+	//内置的达梦方言在init时注册
+	//The built-in dm dialect is registered in init
+	if _, ok := CustomDriverValueMap["*dm.DmClob"].(dmClobConvert); !ok {
+		t.Fatalf("CustomDriverValueMap[*dm.DmClob] = %T, want dmClobConvert", CustomDriverValueMap["*dm.DmClob"])
+	}
+	if err := RegisterDialect(clobDialect{}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		dialectMap.Delete("clob")
+		delete(CustomDriverValueMap, "*clob.Clob")
+	}()
+	//RegisterDialect时添加,不覆盖已经存在的key
+	//Added when RegisterDialect, existing keys are not overwritten
+	if _, ok := CustomDriverValueMap["*clob.Clob"].(clobDialectConvert); !ok {
+		t.Errorf("CustomDriverValueMap[*clob.Clob] = %T, want clobDialectConvert", CustomDriverValueMap["*clob.Clob"])
+	}
+	if _, ok := CustomDriverValueMap["*dm.DmClob"].(dmClobConvert); !ok {
+		t.Errorf("CustomDriverValueMap[*dm.DmClob] = %T, overwritten", CustomDriverValueMap["*dm.DmClob"])
+	}
+}
+
+func TestDomesticDialectSQL(t *testing.T) {
+	tests := []struct {
+		dialect       string
+		wantBind      string
+		wantLimit     string
+		wantDelete    string
+		wantUpsert    string
+		wantSavepoint []string
+	}{
+		{
+			dialect:       "dm",
+			wantBind:      "SELECT * FROM t_demo WHERE id=? AND name=?",
+			wantLimit:     "SELECT * FROM t_demo LIMIT 20,10",
+			wantDelete:    `DELETE FROM "t_user_role" WHERE "user_id"=? AND "role_id"=?`,
+			wantUpsert:    "MERGE INTO t_demo grm_target USING (SELECT ? id,? name FROM dual) grm_source ON (grm_target.id=grm_source.id) WHEN MATCHED THEN UPDATE SET grm_target.name=grm_source.name WHEN NOT MATCHED THEN INSERT (id,name) VALUES (grm_source.id,grm_source.name)",
+			wantSavepoint: []string{"SAVEPOINT sp1", "ROLLBACK TO SAVEPOINT sp1", ""},
+		},
+		{
+			dialect:       "kingbase",
+			wantBind:      "SELECT * FROM t_demo WHERE id=$1 AND name=$2",
+			wantLimit:     "SELECT * FROM t_demo LIMIT 10 OFFSET 20",
+			wantDelete:    `DELETE FROM "t_user_role" WHERE "user_id"=$1 AND "role_id"=$2`,
+			wantUpsert:    "INSERT INTO t_demo(id,name) VALUES (?,?) ON CONFLICT (id) DO UPDATE SET name=EXCLUDED.name",
+			wantSavepoint: []string{"SAVEPOINT sp1", "ROLLBACK TO SAVEPOINT sp1", "RELEASE SAVEPOINT sp1"},
+		},
+		{
+			dialect:       "opengauss",
+			wantBind:      "SELECT * FROM t_demo WHERE id=$1 AND name=$2",
+			wantLimit:     "SELECT * FROM t_demo LIMIT 10 OFFSET 20",
+			wantDelete:    `DELETE FROM "t_user_role" WHERE "user_id"=$1 AND "role_id"=$2`,
+			wantUpsert:    "INSERT INTO t_demo(id,name) VALUES (?,?) ON DUPLICATE KEY UPDATE name=EXCLUDED.name",
+			wantSavepoint: []string{"SAVEPOINT sp1", "ROLLBACK TO SAVEPOINT sp1", "RELEASE SAVEPOINT sp1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			config := &DBConfig{Dialect: tt.dialect, QuoteIdentifier: true}
+			if got, err := reBindSQL(config, "SELECT * FROM t_demo WHERE id=? AND name=?"); err != nil || got != tt.wantBind {
+				t.Errorf("reBindSQL() = %q, %v, want %q", got, err, tt.wantBind)
+			}
+			if got, err := wrapLimitSQL(config, "SELECT * FROM t_demo", 20, 10); err != nil || got != tt.wantLimit {
+				t.Errorf("wrapLimitSQL() = %q, %v, want %q", got, err, tt.wantLimit)
+			}
+			if got, err := wrapDeleteSQL(config, "t_user_role", &userRole{}, nil); err != nil || got != tt.wantDelete {
+				t.Errorf("wrapDeleteSQL() = %q, %v, want %q", got, err, tt.wantDelete)
+			}
+			if got, err := wrapUpsertSQL(&DBConfig{Dialect: tt.dialect}, "t_demo", []string{"id", "name"}, []string{"id"}, []string{"name"}, "id", ""); err != nil || got != tt.wantUpsert {
+				t.Errorf("wrapUpsertSQL() = %q, %v, want %q", got, err, tt.wantUpsert)
+			}
+			for spType, want := range tt.wantSavepoint {
+				if got, err := wrapSavepointSQL(config, spType, "sp1"); err != nil || got != want {
+					t.Errorf("wrapSavepointSQL(%d) = %q, %v, want %q", spType, got, err, want)
+				}
+			}
+		})
+	}
+}
+
+// pgVersionDialect 区分版本的自定义方言,9之前的版本使用 ? 占位符,没有savepoint
+// pgVersionDialect The custom dialect that distinguishes versions, versions before 9 use the ? placeholder and have no savepoint
+type pgVersionDialect struct {
+	PostgreSQLDialect
+}
+
+func (dialect pgVersionDialect) Name() string {
+	return "pgversion"
+}
+
+func (dialect pgVersionDialect) VersionSQL() string {
+	return "SHOW server_version"
+}
+
+func (dialect pgVersionDialect) ForVersion(version int) Dialect {
+	if version < 9 {
+		return pgLegacyDialect{}
+	}
+	return dialect
+}
+
+type pgLegacyDialect struct {
+	pgVersionDialect
+}
+
+func (dialect pgLegacyDialect) BindVar(i int) string {
+	return "?"
+}
+
+func (dialect pgLegacyDialect) SavepointSQL(name string) string {
+	return ""
+}
+
+func TestConfigDialect(t *testing.T) {
+	if err := RegisterDialect(pgVersionDialect{}); err != nil {
+		t.Fatal(err)
+	}
+	defer dialectMap.Delete("pgversion")
+	tests := []struct {
+		version       int
+		wantBind      string
+		wantSavepoint string
+	}{
+		{0, "SELECT * FROM t_demo WHERE id=$1", "SAVEPOINT sp1"},
+		{8, "SELECT * FROM t_demo WHERE id=?", ""},
+	}
+	for _, tt := range tests {
+		config := &DBConfig{Dialect: "pgversion", DialectVersion: tt.version}
+		//绑定变量和savepoint使用版本对应的方言
+		//Bind variables and savepoints use the dialect corresponding to the version
+		got, err := reBindSQL(config, "SELECT * FROM t_demo WHERE id=?")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.wantBind {
+			t.Errorf("version %d reBindSQL() = %q, want %q", tt.version, got, tt.wantBind)
+		}
+		if got, err = wrapSavepointSQL(config, savepointCreate, "sp1"); err != nil || got != tt.wantSavepoint {
+			t.Errorf("version %d wrapSavepointSQL() = %q, %v, want %q", tt.version, got, err, tt.wantSavepoint)
+		}
+	}
+
+	//没有注册的方言返回错误
+	//Unregistered dialects return an error
+	config := &DBConfig{Dialect: "grmunknown"}
+	if _, err := reBindSQL(config, "SELECT 1"); err == nil {
+		t.Error("reBindSQL没有返回错误")
+	}
+	if _, err := wrapSavepointSQL(config, savepointCreate, "sp1"); err == nil {
+		t.Error("wrapSavepointSQL没有返回错误")
+	}
+	sqlStr := "UPDATE t_demo SET name=?"
+	if _, err := reUpdateFinderSQL(config, &sqlStr); err == nil {
+		t.Error("reUpdateFinderSQL没有返回错误")
+	}
+	if _, err := NewDao(&DBConfig{DSN: t.Name(), Driver: fakeDriverName, Dialect: "grmunknown"}); err == nil {
+		t.Error("NewDao没有返回错误")
+	}
+}
+
+func TestWrapXASQL(t *testing.T) {
+	tests := []struct {
+		dialect string
+		want    []string
+		wantErr bool
+	}{
+		{"mysql", []string{"XA START 'gid_1'", "XA END 'gid_1'", "XA PREPARE 'gid_1'", "XA COMMIT 'gid_1'", "XA ROLLBACK 'gid_1'", "XA ROLLBACK 'gid_1'"}, false},
+		{"postgresql", []string{"BEGIN", "", "PREPARE TRANSACTION 'gid_1'", "COMMIT PREPARED 'gid_1'", "ROLLBACK PREPARED 'gid_1'", "ROLLBACK"}, false},
+		{"kingbase", []string{"BEGIN", "", "PREPARE TRANSACTION 'gid_1'", "COMMIT PREPARED 'gid_1'", "ROLLBACK PREPARED 'gid_1'", "ROLLBACK"}, false},
+		{"mssql", nil, true},
+	}
+	xaTypes := []int{xaStart, xaEnd, xaPrepare, xaCommit, xaRollback, xaRollbackActive}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			for i, xaType := range xaTypes {
+				got, err := wrapXASQL(&DBConfig{Dialect: tt.dialect}, xaType, "gid_1")
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.wantErr {
+					continue
+				}
+				if got != tt.want[i] {
+					t.Errorf("wrapXASQL(%d) = %q, want %q", xaType, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWrapReplicaLagSQL(t *testing.T) {
+	tests := []struct {
+		dialect string
+		want    string
+		wantErr bool
+	}{
+		{"mysql", "SHOW SLAVE STATUS", false},
+		{"postgresql", "SELECT COALESCE(CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0 ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END, 0)", false},
+		{"opengauss", "SELECT COALESCE(CASE WHEN pg_last_xlog_receive_location() = pg_last_xlog_replay_location() THEN 0 ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END, 0)", false},
+		{"oracle", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			got, err := wrapReplicaLagSQL(&DBConfig{Dialect: tt.dialect})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("wrapReplicaLagSQL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockSkipSQL(t *testing.T) {
+	tests := []struct {
+		dialect string
+		want    string
+		wantErr bool
+	}{
+		{"mysql", "SELECT t.id FROM t_outbox t WHERE t.status=? ORDER BY t.id LIMIT 10 FOR UPDATE SKIP LOCKED", false},
+		{"postgresql", "SELECT t.id FROM t_outbox t WHERE t.status=? ORDER BY t.id LIMIT 10 FOR UPDATE SKIP LOCKED", false},
+		{"oracle", "SELECT t.id FROM t_outbox t WHERE t.ROWID IN (SELECT grm_lock_rid FROM (SELECT t.ROWID grm_lock_rid FROM t_outbox t WHERE t.status=? ORDER BY t.id) WHERE ROWNUM <= 10) ORDER BY t.id FOR UPDATE SKIP LOCKED", false},
+		{"mssql", "SELECT TOP (10) t.id FROM t_outbox t WITH (UPDLOCK, READPAST, ROWLOCK) WHERE t.status=? ORDER BY t.id", false},
+		{"sqlite", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect, func(t *testing.T) {
+			_, dao, _ := newFakeDao(t, &DBConfig{Dialect: tt.dialect})
+			got, err := dao.LockSkipSQL("t_outbox", "t", "t.id", "t.status=?", "t.id", 10)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("LockSkipSQL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLegacyLimitSQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  DBConfig
+		sqlStr  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "oracle 12c",
+			config: DBConfig{Dialect: "oracle", DialectVersion: 12},
+			sqlStr: "SELECT * FROM t_user ORDER BY id",
+			want:   "SELECT * FROM t_user ORDER BY id OFFSET 10 ROWS FETCH NEXT 5 ROWS ONLY ",
+		},
+		{
+			name:   "oracle 11g",
+			config: DBConfig{Dialect: "oracle", DialectVersion: 11},
+			sqlStr: "SELECT * FROM t_user ORDER BY id",
+			want:   "SELECT * FROM (SELECT grm_page_t.*,ROWNUM grm_page_rn FROM (SELECT * FROM t_user ORDER BY id) grm_page_t WHERE ROWNUM <= 15) WHERE grm_page_rn > 10",
+		},
+		{
+			name:   "mssql 2008",
+			config: DBConfig{Dialect: "mssql", DialectVersion: 10},
+			sqlStr: "SELECT * FROM t_user ORDER BY id",
+			want:   "SELECT * FROM (SELECT *,ROW_NUMBER() OVER (ORDER BY id) grm_page_rn FROM t_user) grm_page_o WHERE grm_page_rn > 10 AND grm_page_rn <= 15 ORDER BY grm_page_rn",
+		},
+		{
+			name:   "mssql 2008 without order by",
+			config: DBConfig{Dialect: "mssql", DialectVersion: 10},
+			sqlStr: "SELECT * FROM t_user",
+			want:   "SELECT * FROM (SELECT *,ROW_NUMBER() OVER (ORDER BY (SELECT 0)) grm_page_rn FROM t_user) grm_page_o WHERE grm_page_rn > 10 AND grm_page_rn <= 15 ORDER BY grm_page_rn",
+		},
+		{
+			name:   "mssql 2008 order by column of alias",
+			config: DBConfig{Dialect: "mssql", DialectVersion: 10},
+			sqlStr: "SELECT u.id,u.name AS uname FROM t_user u ORDER BY u.name",
+			want:   "SELECT * FROM (SELECT u.id,u.name AS uname,ROW_NUMBER() OVER (ORDER BY u.name) grm_page_rn FROM t_user u) grm_page_o WHERE grm_page_rn > 10 AND grm_page_rn <= 15 ORDER BY grm_page_rn",
+		},
+		{
+			name:   "mssql 2008 order by select alias",
+			config: DBConfig{Dialect: "mssql", DialectVersion: 10},
+			sqlStr: "SELECT u.id,u.name uname FROM t_user u ORDER BY uname",
+			want:   "SELECT * FROM (SELECT grm_page_t.*,ROW_NUMBER() OVER (ORDER BY uname) grm_page_rn FROM (SELECT u.id,u.name uname FROM t_user u) grm_page_t) grm_page_o WHERE grm_page_rn > 10 AND grm_page_rn <= 15 ORDER BY grm_page_rn",
+		},
+		{
+			name:   "mssql 2008 order by ordinal",
+			config: DBConfig{Dialect: "mssql", DialectVersion: 10},
+			sqlStr: "SELECT u.id,u.name FROM t_user u ORDER BY 2 DESC, u.id",
+			want:   "SELECT * FROM (SELECT grm_page_t.*,ROW_NUMBER() OVER (ORDER BY name DESC,id) grm_page_rn FROM (SELECT u.id,u.name FROM t_user u) grm_page_t) grm_page_o WHERE grm_page_rn > 10 AND grm_page_rn <= 15 ORDER BY grm_page_rn",
+		},
+		{
+			name:    "mssql 2008 ordinal without column name",
+			config:  DBConfig{Dialect: "mssql", DialectVersion: 10},
+			sqlStr:  "SELECT COUNT(*) FROM t_user u ORDER BY 1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := wrapLimitSQL(&tt.config, tt.sqlStr, 10, 5)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("wrapLimitSQL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectDialectVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		dialect string
+		version string
+		want    int
+	}{
+		{"oracle 11g", "oracle", "11.2.0.4.0", 11},
+		{"mssql 2008", "mssql", "10.50.6000.34", 10},
+		{"unparseable version", "mssql", "unknown", 0},
+		{"dialect without versions", "mysql", "8.0.32", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			//设置DialectVersion,NewDao不查询版本
+			//Set DialectVersion, NewDao does not query the version
+			config := &DBConfig{Dialect: tt.dialect, DialectVersion: -1}
+			_, dao, db := newFakeDao(t, config)
+			db.queryRows = func(query string) ([]string, [][]driver.Value) {
+				return []string{"version"}, [][]driver.Value{{tt.version}}
+			}
+			config.DialectVersion = 0
+			detectDialectVersion(config, dao.dataSource.DB)
+			if config.DialectVersion != tt.want {
+				t.Errorf("DialectVersion = %d, want %d", config.DialectVersion, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryIgnorePageRowNumber(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *DBConfig
+		idsQuery string
+		want     string
+	}{
+		{
+			name:     "oracle 11g",
+			config:   &DBConfig{Dialect: "oracle", DialectVersion: 11},
+			idsQuery: "(SELECT id FROM",
+			want:     "SELECT * FROM (SELECT grm_page_t.*,ROWNUM grm_page_rn FROM (SELECT * FROM t_shard_demo ORDER BY id) grm_page_t WHERE ROWNUM <= 4) WHERE grm_page_rn > 2",
+		},
+		{
+			name:     "mssql 2008",
+			config:   &DBConfig{Dialect: "mssql", DialectVersion: 10},
+			idsQuery: "(SELECT id,",
+			want:     "SELECT * FROM (SELECT *,ROW_NUMBER() OVER (ORDER BY id) grm_page_rn FROM t_shard_demo) grm_page_o WHERE grm_page_rn > 2 AND grm_page_rn <= 4 ORDER BY grm_page_rn",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _, db := newFakeDao(t, tt.config)
+			db.queryRows = func(query string) ([]string, [][]driver.Value) {
+				if strings.Contains(query, "SELECT COUNT(*)") {
+					return []string{"count"}, [][]driver.Value{{int64(3)}}
+				}
+				if strings.Contains(query, tt.idsQuery) {
+					return []string{"id", pageRowNumberColumn}, [][]driver.Value{{int64(3), int64(3)}}
+				}
+				return []string{"id", "name", pageRowNumberColumn}, [][]driver.Value{{int64(3), "c", int64(3)}}
+			}
+			page := NewPage()
+			page.PageNo = 2
+			page.PageSize = 2
+			//查询结构体,忽略多返回的行号列
+			//Query the struct, ignore the extra row number column
+			list := make([]shardDemo, 0)
+			if err := Query(ctx, NewSelectFinder("t_shard_demo").Append("ORDER BY id"), &list, page); err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != 1 || list[0].ID != 3 || list[0].Name != "c" {
+				t.Errorf("list = %+v", list)
+			}
+			if page.TotalCount != 3 {
+				t.Errorf("TotalCount = %d, want 3", page.TotalCount)
+			}
+			//查询基础类型,忽略最后多返回的行号列
+			//Query the basic type, ignore the extra row number column at the end
+			ids := make([]int, 0)
+			if err := Query(ctx, NewSelectFinder("t_shard_demo", "id").Append("ORDER BY id"), &ids, page); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ids, []int{3}) {
+				t.Errorf("ids = %v, want [3]", ids)
+			}
+			statements := db.take()
+			if !strings.Contains(strings.Join(statements, "\n"), tt.want) {
+				t.Errorf("statements = %q, want %q", statements, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		dialect string
+		name    string
+		want    string
+	}{
+		{"mysql", "order", "`order`"},
+		{"mysql", "t.order", "`t`.`order`"},
+		{"mysql", "a`b", "`a``b`"},
+		{"clickhouse", "group", "`group`"},
+		{"postgresql", "userName", `"userName"`},
+		{"postgresql", `a"b`, `"a""b"`},
+		{"oracle", "user", `"user"`},
+		{"sqlite", "t.group", `"t"."group"`},
+		{"mssql", "order", "[order]"},
+		{"mssql", "a]b", "[a]]b]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect+" "+tt.name, func(t *testing.T) {
+			dialect, err := getDialect(tt.dialect)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := dialect.QuoteIdentifier(tt.name); got != tt.want {
+				t.Errorf("QuoteIdentifier() = %q, want %q", got, tt.want)
+			}
+			//DBConfig.QuoteIdentifier为false时不转义
+			//No quoting when DBConfig.QuoteIdentifier is false
+			if got := identifierQuoter(&DBConfig{Dialect: tt.dialect})(tt.name); got != tt.name {
+				t.Errorf("identifierQuoter() = %q, want %q", got, tt.name)
+			}
+		})
+	}
+}
+
+func TestQuoteIdentifierSQL(t *testing.T) {
+	ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql", QuoteIdentifier: true})
+	_, err := Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		if _, err := UpdateNotZeroValue(ctx, &userRole{UserID: 1, RoleID: 2, Remark: "grm"}); err != nil {
+			return nil, err
+		}
+		entity := NewEntityMap("order")
+		entity.PkColumnName = "id"
+		entity.Set("id", 1)
+		entity.Set("group", "grm")
+		return UpdateEntityMap(ctx, entity)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertStatements(t, db.take(), []string{
+		"BEGIN",
+		"UPDATE `t_user_role` SET `remark`=? WHERE `user_id`=? AND `role_id`=?",
+		"UPDATE `order` SET `group`=? WHERE `id`=?",
+		"COMMIT",
+	})
+}
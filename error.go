@@ -1,5 +1,25 @@
 package grm
 
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrStaleEntity 乐观锁更新失败,grm:"version" 字段的版本已经被修改,没有更新数据.Update和UpdateNotZeroValue返回的就是ErrStaleEntity,可以直接比较
+// ErrStaleEntity Optimistic lock update failed, the version of the grm:"version" column has been modified and no data is updated.
+// Update and UpdateNotZeroValue return ErrStaleEntity itself, which can be compared directly
+var ErrStaleEntity = errors.New("乐观锁更新失败,数据的版本已经被修改")
+
+// txPanicError 事务函数panic时返回的错误,panic不会重试
+// txPanicError The error returned when the transaction function panics, the panic will not be retried
+type txPanicError struct {
+	value interface{}
+}
+
+func (e *txPanicError) Error() string {
+	return fmt.Sprintf("transaction panic: %v", e.value)
+}
+
 func ErrIsDuplicate(err error) {
 
 }
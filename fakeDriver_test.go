@@ -0,0 +1,198 @@
+package grm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDriverName 测试使用的数据库驱动,不连接数据库,记录执行的语句
+// fakeDriverName The database driver used by the tests, does not connect to the database and records the executed statements
+const fakeDriverName = "grmfake"
+
+// fakeDBMap 测试的数据库,key是DSN
+// fakeDBMap The databases of the tests, the key is the DSN
+var fakeDBMap = &sync.Map{}
+
+func init() {
+	sql.Register(fakeDriverName, fakeDriver{})
+}
+
+// fakeDB 记录执行的语句,execErr和queryRows为nil时执行成功,查询没有结果
+// fakeDB Record the executed statements, when execErr and queryRows are nil, the execution succeeds and the query has no result
+type fakeDB struct {
+	mu         sync.Mutex
+	statements []string
+	// execErr 返回执行语句的错误
+	// execErr Return the error of executing the statement
+	execErr func(query string) error
+	// queryRows 返回查询的列名和结果
+	// queryRows Return the column names and rows of the query
+	queryRows func(query string) ([]string, [][]driver.Value)
+	// affected 更新语句影响的行数,默认1
+	// affected The number of rows affected by the update statement, default 1
+	affected func(query string) int64
+	// pingErr 返回Ping的错误
+	// pingErr Return the error of Ping
+	pingErr func() error
+}
+
+// record 记录执行的语句
+// record Record the executed statement
+func (db *fakeDB) record(query string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.statements = append(db.statements, query)
+}
+
+// take 返回记录的语句并清空
+// take Return the recorded statements and clear them
+func (db *fakeDB) take() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	statements := db.statements
+	db.statements = nil
+	return statements
+}
+
+// newFakeDao 创建使用fakeDB的DBDao,返回绑定了dbConn的ctx.config.DSN为空时使用测试的名称
+// newFakeDao Create a DBDao that uses fakeDB and return the ctx bound to dbConn. The name of the test is used when config.DSN is empty
+func newFakeDao(t *testing.T, config *DBConfig) (context.Context, *DBDao, *fakeDB) {
+	db := &fakeDB{}
+	if config.DSN == "" {
+		config.DSN = t.Name()
+	}
+	dsn := config.DSN
+	fakeDBMap.Store(dsn, db)
+	config.Driver = fakeDriverName
+	dao, err := NewDao(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		dao.CloseDB()
+		fakeDBMap.Delete(dsn)
+	})
+	ctx, err := dao.BindCtxDBConn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.take()
+	return ctx, dao, db
+}
+
+// assertStatements 比较执行的语句
+// assertStatements Compare the executed statements
+func assertStatements(t *testing.T, got []string, want []string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("statements:\n got: %q\nwant: %q", got, want)
+	}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	db, ok := fakeDBMap.Load(dsn)
+	if !ok {
+		return nil, errors.New("fakeDriver-->没有注册的DSN:" + dsn)
+	}
+	return &fakeConn{db: db.(*fakeDB)}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (conn *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fakeConn-->不支持Prepare")
+}
+
+func (conn *fakeConn) Close() error {
+	return nil
+}
+
+func (conn *fakeConn) Ping(ctx context.Context) error {
+	if conn.db.pingErr != nil {
+		return conn.db.pingErr()
+	}
+	return nil
+}
+
+func (conn *fakeConn) Begin() (driver.Tx, error) {
+	return conn.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (conn *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if opts.ReadOnly {
+		conn.db.record("BEGIN READ ONLY")
+	} else {
+		conn.db.record("BEGIN")
+	}
+	return &fakeTx{db: conn.db}, nil
+}
+
+func (conn *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	conn.db.record(query)
+	if conn.db.execErr != nil {
+		if err := conn.db.execErr(query); err != nil {
+			return nil, err
+		}
+	}
+	affected := int64(1)
+	if conn.db.affected != nil {
+		affected = conn.db.affected(query)
+	}
+	return driver.RowsAffected(affected), nil
+}
+
+func (conn *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	conn.db.record(query)
+	rows := &fakeRows{}
+	if conn.db.queryRows != nil {
+		rows.columns, rows.rows = conn.db.queryRows(query)
+	}
+	return rows, nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (tx *fakeTx) Commit() error {
+	tx.db.record("COMMIT")
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.db.record("ROLLBACK")
+	return nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+	index   int
+}
+
+func (rows *fakeRows) Columns() []string {
+	return rows.columns
+}
+
+func (rows *fakeRows) Close() error {
+	return nil
+}
+
+func (rows *fakeRows) Next(dest []driver.Value) error {
+	if rows.index >= len(rows.rows) {
+		return io.EOF
+	}
+	copy(dest, rows.rows[rows.index])
+	rows.index++
+	return nil
+}
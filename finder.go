@@ -1,10 +1,12 @@
 package grm
 
 import (
+	"context"
 	"errors"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 )
 
 //Finder 查询数据库的载体,所有的sql语句都要通过Finder执行.
@@ -25,6 +27,24 @@ type Finder struct {
 	//是否自动查询总条数,默认true.同时需要Page不为nil,才查询总条数
 	//Whether to automatically query the total number of entries, the default is true. At the same time, the Page is not nil to query the total number of entries
 	SelectTotalCount bool
+	//是否是跨租户的语句,默认false.开启多租户时,没有使用AppendTenant的查询和更新语句需要设置为true,参见BindCtxTenant
+	//CrossTenant Whether it is a cross-tenant statement, the default is false. When multi-tenancy is enabled,
+	//query and update statements that do not use AppendTenant need to be set to true, see BindCtxTenant
+	CrossTenant bool
+	//是否使用AppendTenant增加了租户条件
+	//tenantPredicate Whether the tenant condition has been added using AppendTenant
+	tenantPredicate bool
+	//是否包含软删除的数据,默认false.查询有 grm:"softDelete" 字段的struct时,NewSelectFinder的查询默认排除软删除的数据,需要包含时设置为true
+	//WithDeleted Whether to include soft deleted data, the default is false. When querying a struct with a grm:"softDelete" field,
+	//the query of NewSelectFinder excludes soft deleted data by default, set to true to include them
+	WithDeleted bool
+	//是否已经增加了排除软删除数据的条件,参见NewNotDeletedFinder
+	//softDeletePredicate Whether the condition to exclude soft deleted data has been added, see NewNotDeletedFinder
+	softDeletePredicate bool
+	//NewSelectFinder的表名和在语句中的位置,用于默认排除软删除的数据
+	//selectTable The table name of NewSelectFinder and its position in the statement, used to exclude soft deleted data by default
+	selectTable      string
+	selectTableIndex int
 	//SQL语句
 	//SQL statement
 	sqlStr string
@@ -42,6 +62,8 @@ func NewFinder() *Finder {
 //NewSelectFinder 根据表名初始化查询的Finder | Finder that initializes the query based on the table name
 //NewSelectFinder("tableName") SELECT * FROM tableName
 //NewSelectFinder("tableName", "id,name") SELECT id,name FROM tableName
+//查询有 grm:"softDelete" 字段的struct时,默认排除软删除的数据,参见Finder.WithDeleted
+//When querying a struct with a grm:"softDelete" field, soft deleted data is excluded by default, see Finder.WithDeleted
 func NewSelectFinder(tableName string, strs ...string) *Finder {
 	finder := NewFinder()
 	finder.sqlBuilder.WriteString("SELECT ")
@@ -53,10 +75,71 @@ func NewSelectFinder(tableName string, strs ...string) *Finder {
 		finder.sqlBuilder.WriteString("*")
 	}
 	finder.sqlBuilder.WriteString(" FROM ")
+	finder.selectTable = tableName
+	finder.selectTableIndex = finder.sqlBuilder.Len()
 	finder.sqlBuilder.WriteString(tableName)
 	return finder
 }
 
+//NewSelectFinderByEntity 使用FuncTableNameResolver获取实体类的物理表名,初始化查询的Finder,用于按月分表等动态表名的场景
+//NewSelectFinderByEntity(ctx, &demo) SELECT * FROM t_demo_202610
+//NewSelectFinderByEntity Use FuncTableNameResolver to get the physical table name of the entity and initialize the query Finder,
+//used for scenarios with dynamic table names such as monthly tables
+func NewSelectFinderByEntity(ctx context.Context, entity interface{}, strs ...string) (*Finder, error) {
+	tableName, err := FuncTableNameResolver(ctx, entity)
+	if err != nil {
+		return nil, err
+	}
+	return NewSelectFinder(tableName, strs...), nil
+}
+
+//NewUnionAllFinder 对多个表执行相同的查询,使用UNION ALL合并,例如按月分表的日志表.tableFinder根据表名返回每个表的查询Finder
+//返回的Finder是 SELECT * FROM (f1 UNION ALL f2 ...) grm_union_table ,可以继续Append排序等语句,支持分页
+//NewUnionAllFinder Execute the same query on multiple tables and merge with UNION ALL, such as monthly log tables.
+//tableFinder returns the query Finder of each table according to the table name.
+//The returned Finder is SELECT * FROM (f1 UNION ALL f2 ...) grm_union_table, you can continue to Append order by and other statements, paging is supported
+func NewUnionAllFinder(tableNames []string, tableFinder func(tableName string) (*Finder, error)) (*Finder, error) {
+	if len(tableNames) < 1 || tableFinder == nil {
+		return nil, errors.New("finder-->NewUnionAllFinder的tableNames和tableFinder不能为空")
+	}
+	finder := NewFinder()
+	finder.sqlBuilder.WriteString("SELECT * FROM (")
+	//每个表的Finder都使用了AppendTenant,合并的Finder才有租户条件
+	//The merged Finder has the tenant condition only when the Finder of each table uses AppendTenant
+	finder.tenantPredicate = true
+	for i, tableName := range tableNames {
+		f, err := tableFinder(tableName)
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			return nil, errors.New("finder-->NewUnionAllFinder的tableFinder返回值不能为nil")
+		}
+		finder.tenantPredicate = finder.tenantPredicate && f.tenantPredicate
+		if i > 0 {
+			finder.sqlBuilder.WriteString(" UNION ALL ")
+		}
+		if _, err = finder.AppendFinder(f); err != nil {
+			return nil, err
+		}
+	}
+	finder.sqlBuilder.WriteString(") grm_union_table")
+	return finder, nil
+}
+
+//MonthlyTableNames 返回start到end(包含)之间的按月分表的表名,表名是prefix+年月,例如 t_log_202609,t_log_202610
+//MonthlyTableNames Return the table names of monthly tables between start and end (inclusive), the table name is prefix+year month, such as t_log_202609,t_log_202610
+func MonthlyTableNames(prefix string, start time.Time, end time.Time) []string {
+	tableNames := make([]string, 0)
+	month := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, start.Location())
+	end = end.In(start.Location())
+	for !month.After(end) {
+		tableNames = append(tableNames, prefix+month.Format("200601"))
+		month = month.AddDate(0, 1, 0)
+	}
+	return tableNames
+}
+
 //NewUpdateFinder 根据表名初始化更新的Finder,  UPDATE tableName SET
 //NewUpdateFinder Initialize the updated Finder according to the table name, UPDATE tableName SET
 func NewUpdateFinder(tableName string) *Finder {
@@ -137,9 +220,33 @@ func (finder *Finder) AppendFinder(f *Finder) (*Finder, error) {
 	//添加f的值
 	//Add the value of f
 	finder.values = append(finder.values, f.values...)
+	finder.softDeletePredicate = finder.softDeletePredicate || f.softDeletePredicate
 	return finder, nil
 }
 
+//clone 复制Finder,用于并发查询多个数据库,GetSQL会修改Finder的语句缓存和参数,并发时不能共用同一个Finder
+//clone Copy the Finder, used to query multiple databases concurrently. GetSQL modifies the statement cache and parameters of the Finder,
+//so the same Finder cannot be shared concurrently
+func (finder *Finder) clone() *Finder {
+	if finder == nil {
+		return nil
+	}
+	f := NewFinder()
+	f.sqlBuilder.WriteString(finder.sqlBuilder.String())
+	f.values = append(f.values, finder.values...)
+	f.InjectionCheck = finder.InjectionCheck
+	f.SelectTotalCount = finder.SelectTotalCount
+	f.CrossTenant = finder.CrossTenant
+	f.tenantPredicate = finder.tenantPredicate
+	f.WithDeleted = finder.WithDeleted
+	f.softDeletePredicate = finder.softDeletePredicate
+	f.selectTable = finder.selectTable
+	f.selectTableIndex = finder.selectTableIndex
+	f.CountFinder = finder.CountFinder.clone()
+	f.sqlStr = finder.sqlStr
+	return f
+}
+
 //GetSQL 返回Finder封装的SQL语句
 //GetSQL Return the SQL statement encapsulated by the Finder
 func (finder *Finder) GetSQL() (string, error) {
@@ -0,0 +1,133 @@
+package grm
+
+import (
+	"context"
+	"reflect"
+)
+
+// 实体类可选实现的生命周期钩子,ctx是调用grm方法时传入的ctx,在事务内可以继续使用ctx操作数据库
+// Before* 返回error会终止操作,After* 返回error会作为grm方法的error返回,在grm.Transaction内会回滚事务
+// 钩子在检查dbConn和事务之后调用,没有事务或者是只读事务时不会调用
+// Optional lifecycle hooks implemented by entities, ctx is the ctx passed in when calling the grm method, and the database can continue to be operated with ctx in the transaction
+// Before* returning error will abort the operation, After* returning error will be returned as the error of the grm method, and the transaction will be rolled back in grm.Transaction
+// The hooks are called after checking the dbConn and transaction, and are not called without a transaction or in a read-only transaction
+
+// IBeforeInsert Insert和InsertSlice保存之前调用,在填充租户字段和自动时间之前,可以用于校验和格式化数据
+// IBeforeInsert Called before Insert and InsertSlice, before filling the tenant column and auto time, can be used to validate and normalize data
+type IBeforeInsert interface {
+	BeforeInsert(ctx context.Context) error
+}
+
+// IAfterInsert Insert和InsertSlice保存成功之后调用,Insert的自增主键已经赋值
+// IAfterInsert Called after Insert and InsertSlice succeed, the auto-increment primary key of Insert has been assigned
+type IAfterInsert interface {
+	AfterInsert(ctx context.Context) error
+}
+
+// IBeforeUpdate Update和UpdateNotZeroValue更新之前调用
+// IBeforeUpdate Called before Update and UpdateNotZeroValue
+type IBeforeUpdate interface {
+	BeforeUpdate(ctx context.Context) error
+}
+
+// IAfterUpdate Update和UpdateNotZeroValue更新成功之后调用
+// IAfterUpdate Called after Update and UpdateNotZeroValue succeed
+type IAfterUpdate interface {
+	AfterUpdate(ctx context.Context) error
+}
+
+// IBeforeDelete Delete和HardDelete删除之前调用
+// IBeforeDelete Called before Delete and HardDelete
+type IBeforeDelete interface {
+	BeforeDelete(ctx context.Context) error
+}
+
+// IAfterDelete Delete和HardDelete删除成功之后调用
+// IAfterDelete Called after Delete and HardDelete succeed
+type IAfterDelete interface {
+	AfterDelete(ctx context.Context) error
+}
+
+// IAfterFind QueryRow和Query查询到struct之后调用,可以用于计算派生字段.查询结果读取完之后才调用
+// IAfterFind Called after QueryRow and Query find the struct, can be used to calculate derived fields. Called after the query result is read
+type IAfterFind interface {
+	AfterFind(ctx context.Context) error
+}
+
+// callBeforeInsert 调用IBeforeInsert钩子
+// callBeforeInsert Call the IBeforeInsert hook
+func callBeforeInsert(ctx context.Context, entity interface{}) error {
+	if hook, ok := entity.(IBeforeInsert); ok {
+		return hook.BeforeInsert(ctx)
+	}
+	return nil
+}
+
+// callAfterInsert 调用IAfterInsert钩子
+// callAfterInsert Call the IAfterInsert hook
+func callAfterInsert(ctx context.Context, entity interface{}) error {
+	if hook, ok := entity.(IAfterInsert); ok {
+		return hook.AfterInsert(ctx)
+	}
+	return nil
+}
+
+// callBeforeUpdate 调用IBeforeUpdate钩子
+// callBeforeUpdate Call the IBeforeUpdate hook
+func callBeforeUpdate(ctx context.Context, entity interface{}) error {
+	if hook, ok := entity.(IBeforeUpdate); ok {
+		return hook.BeforeUpdate(ctx)
+	}
+	return nil
+}
+
+// callAfterUpdate 调用IAfterUpdate钩子
+// callAfterUpdate Call the IAfterUpdate hook
+func callAfterUpdate(ctx context.Context, entity interface{}) error {
+	if hook, ok := entity.(IAfterUpdate); ok {
+		return hook.AfterUpdate(ctx)
+	}
+	return nil
+}
+
+// callBeforeDelete 调用IBeforeDelete钩子
+// callBeforeDelete Call the IBeforeDelete hook
+func callBeforeDelete(ctx context.Context, entity interface{}) error {
+	if hook, ok := entity.(IBeforeDelete); ok {
+		return hook.BeforeDelete(ctx)
+	}
+	return nil
+}
+
+// callAfterDelete 调用IAfterDelete钩子
+// callAfterDelete Call the IAfterDelete hook
+func callAfterDelete(ctx context.Context, entity interface{}) error {
+	if hook, ok := entity.(IAfterDelete); ok {
+		return hook.AfterDelete(ctx)
+	}
+	return nil
+}
+
+// callAfterFind 调用IAfterFind钩子
+// callAfterFind Call the IAfterFind hook
+func callAfterFind(ctx context.Context, entity interface{}) error {
+	if hook, ok := entity.(IAfterFind); ok {
+		return hook.AfterFind(ctx)
+	}
+	return nil
+}
+
+// callSliceAfterFind 对数组中从start开始的元素调用IAfterFind钩子,元素是struct或者*struct
+// callSliceAfterFind Call the IAfterFind hook on the elements starting from start in the slice, the element is struct or *struct
+func callSliceAfterFind(ctx context.Context, sliceValue reflect.Value, start int) error {
+	for i := start; i < sliceValue.Len(); i++ {
+		element := sliceValue.Index(i)
+		if element.Kind() != reflect.Ptr {
+			element = element.Addr()
+		}
+		if err := callAfterFind(ctx, element.Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package grm
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// hookDemo 钩子测试的实体类,记录调用的钩子,failHook返回错误
+// hookDemo The entity of the hook tests, records the called hooks, and failHook returns an error
+type hookDemo struct {
+	EntityStruct
+	ID       int    `column:"id"`
+	Name     string `column:"name"`
+	calls    []string
+	failHook string
+}
+
+func (entity *hookDemo) TableName() string {
+	return "t_hook"
+}
+
+// call 记录调用的钩子
+// call Record the called hook
+func (entity *hookDemo) call(hook string) error {
+	entity.calls = append(entity.calls, hook)
+	if hook == entity.failHook {
+		return errTestBusiness
+	}
+	return nil
+}
+
+func (entity *hookDemo) BeforeInsert(ctx context.Context) error {
+	return entity.call("BeforeInsert")
+}
+
+func (entity *hookDemo) AfterInsert(ctx context.Context) error {
+	return entity.call("AfterInsert")
+}
+
+func (entity *hookDemo) BeforeUpdate(ctx context.Context) error {
+	return entity.call("BeforeUpdate")
+}
+
+func (entity *hookDemo) AfterUpdate(ctx context.Context) error {
+	return entity.call("AfterUpdate")
+}
+
+func (entity *hookDemo) BeforeDelete(ctx context.Context) error {
+	return entity.call("BeforeDelete")
+}
+
+func (entity *hookDemo) AfterDelete(ctx context.Context) error {
+	return entity.call("AfterDelete")
+}
+
+func (entity *hookDemo) AfterFind(ctx context.Context) error {
+	return entity.call("AfterFind")
+}
+
+func TestHooks(t *testing.T) {
+	tests := []struct {
+		name      string
+		failHook  string
+		wantCalls []string
+		wantSQL   []string
+	}{
+		{
+			name:      "all succeed",
+			wantCalls: []string{"BeforeInsert", "AfterInsert", "BeforeUpdate", "AfterUpdate", "BeforeDelete", "AfterDelete"},
+			wantSQL:   []string{"BEGIN", "INSERT", "UPDATE", "DELETE", "COMMIT"},
+		},
+		{
+			name:      "before update aborts",
+			failHook:  "BeforeUpdate",
+			wantCalls: []string{"BeforeInsert", "AfterInsert", "BeforeUpdate"},
+			wantSQL:   []string{"BEGIN", "INSERT", "ROLLBACK"},
+		},
+		{
+			name:      "after insert rolls back",
+			failHook:  "AfterInsert",
+			wantCalls: []string{"BeforeInsert", "AfterInsert"},
+			wantSQL:   []string{"BEGIN", "INSERT", "ROLLBACK"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+			demo := &hookDemo{ID: 1, Name: "grm", failHook: tt.failHook}
+			var hookErr error
+			_, err := Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+				if _, hookErr = Insert(ctx, demo); hookErr != nil {
+					return nil, hookErr
+				}
+				if _, hookErr = Update(ctx, demo); hookErr != nil {
+					return nil, hookErr
+				}
+				_, hookErr = Delete(ctx, demo)
+				return nil, hookErr
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (hookErr != nil) != (tt.failHook != "") {
+				t.Fatalf("err = %v, failHook %q", hookErr, tt.failHook)
+			}
+			if !reflect.DeepEqual(demo.calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", demo.calls, tt.wantCalls)
+			}
+			statements := db.take()
+			if len(statements) != len(tt.wantSQL) {
+				t.Fatalf("statements = %q, want %v", statements, tt.wantSQL)
+			}
+			for i, statement := range statements {
+				if !strings.HasPrefix(strings.TrimSpace(statement), tt.wantSQL[i]) {
+					t.Errorf("statements = %q, want %v", statements, tt.wantSQL)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestHooksWithoutTx(t *testing.T) {
+	ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+	//没有事务时不调用钩子
+	//The hooks are not called without a transaction
+	demo := &hookDemo{ID: 1}
+	if _, err := Insert(ctx, demo); err != errDBConn {
+		t.Errorf("Insert err = %v, want errDBConn", err)
+	}
+	if _, err := Update(ctx, demo); err != errDBConn {
+		t.Errorf("Update err = %v, want errDBConn", err)
+	}
+	if _, err := Delete(ctx, demo); err != errDBConn {
+		t.Errorf("Delete err = %v, want errDBConn", err)
+	}
+	if _, err := Insert(context.Background(), demo); err == nil {
+		t.Error("Insert没有检查ctx中的dbConn")
+	}
+	//只读事务内不调用钩子
+	//The hooks are not called in a read-only transaction
+	_, err := TransactionReadOnly(ctx, func(ctx context.Context) (interface{}, error) {
+		if _, err := Insert(ctx, demo); err != errReadOnlyTx {
+			t.Errorf("Insert err = %v, want errReadOnlyTx", err)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(demo.calls) != 0 {
+		t.Errorf("calls = %v, want none", demo.calls)
+	}
+	if statements := db.take(); len(statements) != 2 {
+		t.Errorf("statements = %q, want only the read-only transaction", statements)
+	}
+}
+
+func TestAfterFind(t *testing.T) {
+	ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+	rows := [][]driver.Value{{int64(1), "a"}}
+	db.queryRows = func(query string) ([]string, [][]driver.Value) {
+		return []string{"id", "name"}, rows
+	}
+	demo := &hookDemo{}
+	if _, err := QueryRow(ctx, NewSelectFinder("t_hook"), demo); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(demo.calls, []string{"AfterFind"}) {
+		t.Errorf("calls = %v, want [AfterFind]", demo.calls)
+	}
+	rows = [][]driver.Value{{int64(1), "a"}, {int64(2), "b"}}
+	list := make([]hookDemo, 0)
+	if err := Query(ctx, NewSelectFinder("t_hook"), &list, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("list = %v, want 2", list)
+	}
+	for _, demo := range list {
+		if !reflect.DeepEqual(demo.calls, []string{"AfterFind"}) {
+			t.Errorf("calls of %d = %v, want [AfterFind]", demo.ID, demo.calls)
+		}
+	}
+}
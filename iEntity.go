@@ -42,6 +42,24 @@ type IEntityMap interface {
 	Set(key string, value interface{}) map[string]interface{}
 }
 
+//IEntityPKs IEntityStruct可选实现的接口,返回联合主键的数据库字段名,例如关联表的 []string{"user_id", "role_id"}
+//实现后Update,UpdateNotZeroValue,Delete等方法使用 WHERE user_id=? AND role_id=? 的条件,联合主键的值都不能是零值.联合主键不支持自增
+//IEntityPKs The optional interface of IEntityStruct, returns the database column names of the composite primary key, such as []string{"user_id", "role_id"} of the link table
+//After implementation, Update, UpdateNotZeroValue, Delete and other methods use the condition WHERE user_id=? AND role_id=?,
+//and the values of the composite primary key cannot be zero. Composite primary keys do not support auto-increment
+type IEntityPKs interface {
+	PKs() []string
+}
+
+//IEntityMapAutoTime IEntityMap可选实现的接口,返回自动填充的创建时间和更新时间的字段名,不需要的返回"",字段的值是time.Time
+//InsertEntityMap填充没有Set的创建时间和更新时间,UpdateEntityMap填充更新时间
+//IEntityMapAutoTime The optional interface of IEntityMap, returns the column names of the auto-filled create time and update time,
+//return "" if not needed, the value of the column is time.Time.
+//InsertEntityMap fills the create time and update time that are not Set, and UpdateEntityMap fills the update time
+type IEntityMapAutoTime interface {
+	AutoTimeColumns() (createTimeColumn string, updateTimeColumn string)
+}
+
 //EntityStruct "IBaseEntity" 的基础实现,所有的实体类都匿名注入.这样就类似实现继承了,如果接口增加方法,调整这个默认实现即可
 //EntityStruct The basic implementation of "IBaseEntity", all entity classes are injected anonymously
 //This is similar to implementation inheritance. If the interface adds methods, adjust the default implementation
@@ -79,7 +97,11 @@ type EntityMap struct {
 	tableName    string
 	PkColumnName string
 	PkSequence   map[string]string
-	dbFieldMap   map[string]interface{}
+	//自动填充的创建时间和更新时间的字段名,默认为空,参见IEntityMapAutoTime
+	//The column names of the auto-filled create time and update time, the default is empty, see IEntityMapAutoTime
+	AutoCreateTimeColumn string
+	AutoUpdateTimeColumn string
+	dbFieldMap           map[string]interface{}
 }
 
 //NewEntityMap Table name cannot be empty
@@ -116,6 +138,12 @@ func (entity *EntityMap) FieldMap() map[string]interface{} {
 	return entity.dbFieldMap
 }
 
+//AutoTimeColumns 自动填充的创建时间和更新时间的字段名,实现IEntityMapAutoTime接口
+//AutoTimeColumns The column names of the auto-filled create time and update time, implements the IEntityMapAutoTime interface
+func (entity *EntityMap) AutoTimeColumns() (string, string) {
+	return entity.AutoCreateTimeColumn, entity.AutoUpdateTimeColumn
+}
+
 //Set database fields
 func (entity *EntityMap) Set(key string, value interface{}) map[string]interface{} {
 	entity.dbFieldMap[key] = value
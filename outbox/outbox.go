@@ -0,0 +1,141 @@
+// Package outbox 基于grm.Transaction的事务发件箱,业务数据和事件记录在同一个事务里写入,由Relay异步发布
+// 保证数据持久化之后事件至少发布一次,消费方需要根据事件ID做幂等处理
+// Package outbox Transactional outbox based on grm.Transaction, business data and event records are written in the same transaction,
+// and published asynchronously by Relay. It guarantees that the event is published at least once after the data is durable,
+// and the consumer needs to be idempotent according to the event ID
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/athxx/grm"
+)
+
+/*
+发件箱表的建表语句,以mysql为例,其他数据库调整字段类型即可
+The table building statement of the outbox table, take mysql as an example, other databases can adjust the field type
+
+CREATE TABLE `t_outbox` (
+  `id` varchar(50) NOT NULL COMMENT 'Primary key,grm.FuncGenerateStringID,sorted by time',
+  `topic` varchar(200) NOT NULL COMMENT 'Topic',
+  `event_key` varchar(200) NOT NULL DEFAULT '' COMMENT 'Events with the same key are published in order',
+  `payload` longblob COMMENT 'Payload',
+  `headers` text COMMENT 'Headers,json',
+  `status` int NOT NULL DEFAULT 0 COMMENT '0 pending, 1 sent',
+  `create_time` datetime(6) NOT NULL,
+  `send_time` datetime(6) NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_outbox_status_id` (`status`,`id`),
+  KEY `idx_outbox_key_status_id` (`event_key`,`status`,`id`)
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COMMENT = 'outbox';
+*/
+
+// DefaultTableName 默认的发件箱表名
+// DefaultTableName Default outbox table name
+const DefaultTableName = "t_outbox"
+
+// 事件的状态
+// Event status
+const (
+	statusPending = 0
+	statusSent    = 1
+)
+
+// Event 发件箱的事件
+// Event Event of the outbox
+type Event struct {
+	// ID 事件ID,为空时使用grm.FuncGenerateStringID生成,按照时间排序
+	// ID Event ID, generated by grm.FuncGenerateStringID when empty, sorted by time
+	ID string
+	// Topic 事件的主题,不能为空
+	// Topic The topic of the event, cannot be empty
+	Topic string
+	// Key 相同Key的事件按照写入的顺序发布,为空不保证顺序
+	// Key Events with the same Key are published in the order of writing, empty does not guarantee the order
+	Key string
+	// Payload 事件内容
+	// Payload Event content
+	Payload []byte
+	// Headers 事件的头信息,保存为json
+	// Headers The header information of the event, saved as json
+	Headers map[string]string
+	// CreateTime 创建时间,为空时使用当前时间
+	// CreateTime Creation time, use the current time when empty
+	CreateTime time.Time
+}
+
+// eventRow 发件箱表的一行记录,用于grm.Query接收数据
+// eventRow A row of the outbox table, used by grm.Query to receive data
+type eventRow struct {
+	ID         string    `column:"id"`
+	Topic      string    `column:"topic"`
+	EventKey   string    `column:"event_key"`
+	Payload    []byte    `column:"payload"`
+	Headers    string    `column:"headers"`
+	CreateTime time.Time `column:"create_time"`
+}
+
+// toEvent 转换成Event
+// toEvent Convert to Event
+func (row *eventRow) toEvent() (*Event, error) {
+	event := &Event{
+		ID:         row.ID,
+		Topic:      row.Topic,
+		Key:        row.EventKey,
+		Payload:    row.Payload,
+		CreateTime: row.CreateTime,
+	}
+	if row.Headers != "" {
+		if err := json.Unmarshal([]byte(row.Headers), &event.Headers); err != nil {
+			return event, errors.New("outbox-->toEvent解析headers错误: " + err.Error())
+		}
+	}
+	return event, nil
+}
+
+// Write 把事件写入发件箱表,必须在grm.Transaction的事务函数内调用,和业务数据使用同一个事务
+// tableName为空时使用DefaultTableName
+// Write Write the events to the outbox table, it must be called in the transaction function of grm.Transaction,
+// using the same transaction as the business data. Use DefaultTableName when tableName is empty
+func Write(ctx context.Context, tableName string, events ...*Event) error {
+	if tableName == "" {
+		tableName = DefaultTableName
+	}
+	for _, event := range events {
+		if event == nil || event.Topic == "" {
+			return errors.New("outbox-->Write事件和事件的Topic不能为空")
+		}
+		if event.ID == "" {
+			event.ID = grm.FuncGenerateStringID()
+		}
+		if event.CreateTime.IsZero() {
+			event.CreateTime = time.Now()
+		}
+		headers := ""
+		if len(event.Headers) > 0 {
+			headerBytes, err := json.Marshal(event.Headers)
+			if err != nil {
+				return errors.New("outbox-->Write序列化headers错误: " + err.Error())
+			}
+			headers = string(headerBytes)
+		}
+
+		entityMap := grm.NewEntityMap(tableName)
+		entityMap.Set("id", event.ID)
+		entityMap.Set("topic", event.Topic)
+		entityMap.Set("event_key", event.Key)
+		entityMap.Set("payload", event.Payload)
+		entityMap.Set("headers", headers)
+		entityMap.Set("status", statusPending)
+		entityMap.Set("create_time", event.CreateTime)
+		//使用ctx中的事务写入,没有事务会返回错误
+		//Write with the transaction in ctx, an error will be returned if there is no transaction
+		if _, err := grm.InsertEntityMap(ctx, entityMap); err != nil {
+			return errors.New("outbox-->Write保存事件错误: " + err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/athxx/grm"
+)
+
+// Publisher 发布事件的接口,例如发送到kafka,rabbitmq.返回nil代表发布成功,事件会被标记为已发送
+// Publisher The interface for publishing events, such as sending to kafka, rabbitmq.
+// Returning nil means the publication is successful, and the event will be marked as sent
+type Publisher interface {
+	Publish(ctx context.Context, event *Event) error
+}
+
+// RelayConfig Relay的配置
+// RelayConfig Configuration of Relay
+type RelayConfig struct {
+	// TableName 发件箱表名,默认DefaultTableName
+	// TableName Outbox table name, default DefaultTableName
+	TableName string
+	// BatchSize 每次轮询获取的事件数量,默认100
+	// BatchSize The number of events obtained per poll, default 100
+	BatchSize int
+	// PollInterval 轮询的间隔,默认1秒.有事件发布成功时,立即进行下一次轮询
+	// PollInterval Polling interval, default 1 second. When some events are published successfully, the next poll is performed immediately
+	PollInterval time.Duration
+	// Retention 已发送事件的保留时间,超过时间的事件会被删除,默认0,不删除
+	// Retention The retention time of sent events, events exceeding the time will be deleted, default 0, not deleted
+	Retention time.Duration
+	// CleanupInterval 清理已发送事件的间隔,默认1小时
+	// CleanupInterval Interval for cleaning up sent events, default 1 hour
+	CleanupInterval time.Duration
+}
+
+// Relay 轮询发件箱表,把未发送的事件交给Publisher发布,并标记为已发送
+// 使用SELECT ... FOR UPDATE SKIP LOCKED锁定事件,可以部署多个Relay实例.数据库方言需要实现grm.LockSkipDialect,否则只能部署一个Relay实例
+// 每次轮询只获取每个Key最早的待发送事件,相同Key存在更早的待发送事件(包括被其他Relay锁定的)时跳过,保证相同Key的事件按照顺序发布
+// 某个事件发布失败时,相同Key的后续事件不会发布,等待下次轮询重试
+// Relay Poll the outbox table, hand over the unsent events to the Publisher, and mark them as sent
+// Use SELECT ... FOR UPDATE SKIP LOCKED to lock events, multiple Relay instances can be deployed.
+// The database dialect needs to implement grm.LockSkipDialect, otherwise only one Relay instance can be deployed
+// Each poll only gets the earliest pending event of each Key. When there is an earlier pending event with the same Key
+// (including those locked by other Relays), the event is skipped, ensuring that events with the same Key are published in order.
+// When an event fails to be published, subsequent events with the same Key will not be published, waiting for the next poll to retry
+type Relay struct {
+	dao       *grm.DBDao
+	publisher Publisher
+	config    RelayConfig
+	selectSQL string
+	// 数据库方言是否支持跳过被锁定的行,不支持时使用方言的分页语句获取一批事件
+	// Whether the database dialect supports skipping locked rows, when not supported, use the paging statement of the dialect to get a batch of events
+	lockSkip bool
+}
+
+// NewRelay 创建Relay,dao是发件箱表所在的数据库
+// NewRelay Create Relay, dao is the database where the outbox table is located
+func NewRelay(dao *grm.DBDao, publisher Publisher, config *RelayConfig) (*Relay, error) {
+	if dao == nil || publisher == nil {
+		return nil, errors.New("outbox-->NewRelay的dao和publisher不能为nil")
+	}
+	relay := &Relay{dao: dao, publisher: publisher}
+	if config != nil {
+		relay.config = *config
+	}
+	if relay.config.TableName == "" {
+		relay.config.TableName = DefaultTableName
+	}
+	if relay.config.BatchSize <= 0 {
+		relay.config.BatchSize = 100
+	}
+	if relay.config.PollInterval <= 0 {
+		relay.config.PollInterval = time.Second
+	}
+	if relay.config.CleanupInterval <= 0 {
+		relay.config.CleanupInterval = time.Hour
+	}
+	relay.selectSQL, relay.lockSkip = wrapLockSelectSQL(dao, relay.config.TableName, relay.config.BatchSize)
+	return relay, nil
+}
+
+// wrapLockSelectSQL 生成锁定一批待发送事件的语句,跳过已经被其他Relay锁定的事件,参见pendingHeadSQL和grm.LockSkipDialect
+// 方言不支持时返回没有分页的查询语句和false,由grm.Query使用方言的分页语句
+// wrapLockSelectSQL Generate a statement to lock a batch of pending events, skipping events locked by other Relays, see pendingHeadSQL and grm.LockSkipDialect.
+// When the dialect does not support it, return the query statement without paging and false, and grm.Query uses the paging statement of the dialect
+func wrapLockSelectSQL(dao *grm.DBDao, tableName string, batchSize int) (string, bool) {
+	columns := "t.id,t.topic,t.event_key,t.payload,t.headers,t.create_time"
+	where := pendingHeadSQL(tableName, "t")
+	sqlStr, err := dao.LockSkipSQL(tableName, "t", columns, where, "t.id", batchSize)
+	if err == nil {
+		return sqlStr, true
+	}
+	//方言不支持跳过锁定的行,只部署一个Relay实例
+	//The dialect does not support skipping locked rows, only deploy one Relay instance
+	return "SELECT " + columns + " FROM " + tableName + " t WHERE " + where + " ORDER BY t.id", false
+}
+
+// pendingHeadSQL 待发送事件的条件,相同Key存在更早的待发送事件时跳过.被其他Relay锁定的事件也是待发送状态,所以后续事件不会被提前发布
+// 参数依次是待发送状态,空字符串的Key,待发送状态.oracle的空字符串是NULL,NOT EXISTS对NULL的Key成立
+// pendingHeadSQL The condition of pending events, skipped when there is an earlier pending event with the same Key.
+// Events locked by other Relays are also pending, so subsequent events will not be published in advance.
+// The parameters are the pending status, the empty Key and the pending status. The empty string of oracle is NULL, and NOT EXISTS holds for the NULL Key
+func pendingHeadSQL(tableName string, alias string) string {
+	return alias + ".status=? AND (" + alias + ".event_key=? OR NOT EXISTS (SELECT 1 FROM " + tableName + " o2 WHERE o2.event_key=" + alias + ".event_key AND o2.status=? AND o2.id<" + alias + ".id))"
+}
+
+// RelayOnce 执行一次轮询,返回发布成功的事件数量
+// 事件发布和标记为已发送在同一个事务里,如果发布成功后事务提交失败,事件会被重复发布
+// RelayOnce Perform a poll and return the number of successfully published events
+// Event publishing and marking as sent are in the same transaction. If the transaction fails to commit after successful publishing,
+// the event will be published repeatedly
+func (relay *Relay) RelayOnce(ctx context.Context) (int, error) {
+	ctx, err := relay.dao.BindCtxDBConn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	_, err = grm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		rows := make([]eventRow, 0, relay.config.BatchSize)
+		finder := grm.NewFinder().Append(relay.selectSQL, statusPending, "", statusPending)
+		//发件箱表不区分租户
+		//The outbox table does not distinguish tenants
+		finder.CrossTenant = true
+		var page *grm.Page
+		if !relay.lockSkip {
+			//使用方言的分页语句获取一批事件,不查询总条数
+			//Use the paging statement of the dialect to get a batch of events, without querying the total count
+			page = grm.NewPage()
+			page.PageSize = relay.config.BatchSize
+			finder.SelectTotalCount = false
+		}
+		if err := grm.Query(ctx, finder, &rows, page); err != nil {
+			return nil, err
+		}
+
+		//发布失败的Key,同一批次里相同Key的后续事件不再发布
+		//Keys that failed to publish, subsequent events with the same Key in the same batch will no longer be published
+		failedKeys := make(map[string]bool)
+		sentIDs := make([]string, 0, len(rows))
+		for i := range rows {
+			row := &rows[i]
+			if row.EventKey != "" && failedKeys[row.EventKey] {
+				continue
+			}
+			event, err := row.toEvent()
+			if err == nil {
+				err = relay.publisher.Publish(ctx, event)
+			}
+			if err != nil {
+				grm.LogErr("outbox-->RelayOnce发布事件" + row.ID + "错误: " + err.Error())
+				if row.EventKey != "" {
+					failedKeys[row.EventKey] = true
+				}
+				continue
+			}
+			sentIDs = append(sentIDs, row.ID)
+		}
+		if len(sentIDs) < 1 {
+			return nil, nil
+		}
+
+		finder = grm.NewUpdateFinder(relay.config.TableName).Append("status=?,send_time=? WHERE id IN (?)", statusSent, time.Now(), sentIDs)
+		finder.CrossTenant = true
+		if _, err := grm.UpdateFinder(ctx, finder); err != nil {
+			return nil, err
+		}
+		sent = len(sentIDs)
+		return nil, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return sent, nil
+}
+
+// Cleanup 删除before之前已经发送的事件,返回删除的数量
+// Cleanup Delete the events that have been sent before before, and return the number of deletions
+func (relay *Relay) Cleanup(ctx context.Context, before time.Time) (int, error) {
+	ctx, err := relay.dao.BindCtxDBConn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	affected := 0
+	_, err = grm.Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		finder := grm.NewDeleteFinder(relay.config.TableName).Append("WHERE status=? AND send_time<?", statusSent, before)
+		finder.CrossTenant = true
+		var err error
+		affected, err = grm.UpdateFinder(ctx, finder)
+		return nil, err
+	})
+	return affected, err
+}
+
+// Run 循环轮询发件箱表,直到ctx结束,返回ctx的错误
+// Run Poll the outbox table in a loop until ctx is done, and return the error of ctx
+func (relay *Relay) Run(ctx context.Context) error {
+	pollTicker := time.NewTicker(relay.config.PollInterval)
+	defer pollTicker.Stop()
+	cleanupTicker := time.NewTicker(relay.config.CleanupInterval)
+	defer cleanupTicker.Stop()
+
+	for {
+		sent, err := relay.RelayOnce(ctx)
+		if err != nil {
+			grm.LogErr("outbox-->Run轮询错误: " + err.Error())
+		}
+		//有事件发布成功,可能还有待发送的事件,例如相同Key的后续事件,立即进行下一次轮询
+		//Some events are published successfully, there may be pending events, such as subsequent events with the same Key,
+		//and the next poll is performed immediately
+		if err == nil && sent > 0 {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-cleanupTicker.C:
+			if relay.config.Retention > 0 {
+				if _, err := relay.Cleanup(ctx, time.Now().Add(-relay.config.Retention)); err != nil {
+					grm.LogErr("outbox-->Run清理已发送事件错误: " + err.Error())
+				}
+			}
+		case <-pollTicker.C:
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/athxx/grm"
+)
+
+// fakeDriverName 测试使用的数据库驱动,不连接数据库,记录执行的语句和参数
+// fakeDriverName The database driver used by the tests, does not connect to the database and records the executed statements and arguments
+const fakeDriverName = "outboxfake"
+
+// fakeDBMap 测试的数据库,key是DSN
+// fakeDBMap The databases of the tests, the key is the DSN
+var fakeDBMap = &sync.Map{}
+
+func init() {
+	sql.Register(fakeDriverName, fakeDriver{})
+}
+
+// fakeStatement 执行的语句和参数
+// fakeStatement The executed statement and arguments
+type fakeStatement struct {
+	query string
+	args  []interface{}
+}
+
+// fakeDB 记录执行的语句,查询返回rows,更新语句影响affected行
+// fakeDB Record the executed statements, the query returns rows, and the update statement affects affected rows
+type fakeDB struct {
+	mu         sync.Mutex
+	statements []fakeStatement
+	rows       [][]driver.Value
+	affected   int64
+}
+
+// record 记录执行的语句,不记录事务语句
+// record Record the executed statement, transaction statements are not recorded
+func (db *fakeDB) record(query string, args []driver.NamedValue) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	db.statements = append(db.statements, fakeStatement{query: query, args: values})
+}
+
+// newTestRelay 创建使用fakeDB的Relay
+// newTestRelay Create a Relay that uses fakeDB
+func newTestRelay(t *testing.T, dialect string, publisher Publisher) (*Relay, *fakeDB) {
+	db := &fakeDB{affected: 1}
+	fakeDBMap.Store(t.Name(), db)
+	dao, err := grm.NewDao(&grm.DBConfig{DSN: t.Name(), Driver: fakeDriverName, Dialect: dialect})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		dao.CloseDB()
+		fakeDBMap.Delete(t.Name())
+	})
+	relay, err := NewRelay(dao, publisher, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return relay, db
+}
+
+// testPublisher 记录发布的事件ID,failIDs中的事件发布失败
+// testPublisher Record the published event IDs, the events in failIDs fail to publish
+type testPublisher struct {
+	published []string
+	failIDs   map[string]bool
+}
+
+func (publisher *testPublisher) Publish(ctx context.Context, event *Event) error {
+	publisher.published = append(publisher.published, event.ID)
+	if publisher.failIDs[event.ID] {
+		return errors.New("publish failed")
+	}
+	return nil
+}
+
+func TestRelayOnce(t *testing.T) {
+	createTime := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	rows := [][]driver.Value{
+		{"e1", "order", "a", []byte("1"), "", createTime},
+		{"e2", "order", "b", []byte("2"), "", createTime},
+		{"e3", "order", "a", []byte("3"), "", createTime},
+		{"e4", "order", "", []byte("4"), "", createTime},
+	}
+	tests := []struct {
+		name          string
+		failIDs       map[string]bool
+		wantPublished []string
+		wantSent      []string
+	}{
+		{"in order", nil, []string{"e1", "e2", "e3", "e4"}, []string{"e1", "e2", "e3", "e4"}},
+		{"failed key blocks later events", map[string]bool{"e1": true}, []string{"e1", "e2", "e4"}, []string{"e2", "e4"}},
+		{"all failed", map[string]bool{"e1": true, "e2": true, "e4": true}, []string{"e1", "e2", "e4"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := &testPublisher{failIDs: tt.failIDs}
+			relay, db := newTestRelay(t, "mysql", publisher)
+			db.rows = rows
+			sent, err := relay.RelayOnce(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sent != len(tt.wantSent) {
+				t.Errorf("sent = %d, want %d", sent, len(tt.wantSent))
+			}
+			if !reflect.DeepEqual(publisher.published, tt.wantPublished) {
+				t.Errorf("published = %v, want %v", publisher.published, tt.wantPublished)
+			}
+
+			wantSelect := "SELECT t.id,t.topic,t.event_key,t.payload,t.headers,t.create_time FROM t_outbox t WHERE t.status=? AND (t.event_key=? OR NOT EXISTS " +
+				"(SELECT 1 FROM t_outbox o2 WHERE o2.event_key=t.event_key AND o2.status=? AND o2.id<t.id)) ORDER BY t.id LIMIT 100 FOR UPDATE SKIP LOCKED"
+			if len(db.statements) < 1 || strings.TrimSpace(db.statements[0].query) != wantSelect {
+				t.Fatalf("statements = %v, want %q", db.statements, wantSelect)
+			}
+			if args := db.statements[0].args; !reflect.DeepEqual(args, []interface{}{int64(statusPending), "", int64(statusPending)}) {
+				t.Errorf("select args = %v", args)
+			}
+			if tt.wantSent == nil {
+				if len(db.statements) != 1 {
+					t.Errorf("statements = %v, want only the select", db.statements)
+				}
+				return
+			}
+			//只把发布成功的事件标记为已发送
+			//Only the successfully published events are marked as sent
+			if len(db.statements) != 2 || !strings.HasPrefix(strings.TrimSpace(db.statements[1].query), "UPDATE t_outbox SET") {
+				t.Fatalf("statements = %v, want the update", db.statements)
+			}
+			args := db.statements[1].args
+			if args[0] != int64(statusSent) {
+				t.Errorf("status = %v, want %d", args[0], statusSent)
+			}
+			sentIDs := make([]string, 0)
+			for _, arg := range args[2:] {
+				sentIDs = append(sentIDs, arg.(string))
+			}
+			if !reflect.DeepEqual(sentIDs, tt.wantSent) {
+				t.Errorf("sent ids = %v, want %v", sentIDs, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestRelayOncePaging(t *testing.T) {
+	//方言不支持跳过锁定的行,使用方言的分页语句
+	//The dialect does not support skipping locked rows, use the paging statement of the dialect
+	relay, db := newTestRelay(t, "sqlite", &testPublisher{})
+	if _, err := relay.RelayOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(db.statements) != 1 || !strings.HasSuffix(db.statements[0].query, " ORDER BY t.id LIMIT 0,100") {
+		t.Errorf("statements = %v, want the paging statement of sqlite", db.statements)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	relay, db := newTestRelay(t, "mysql", &testPublisher{})
+	db.affected = 3
+	before := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	affected, err := relay.Cleanup(context.Background(), before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if affected != 3 {
+		t.Errorf("affected = %d, want 3", affected)
+	}
+	if len(db.statements) != 1 || !strings.HasPrefix(strings.TrimSpace(db.statements[0].query), "DELETE FROM t_outbox") {
+		t.Fatalf("statements = %v, want the delete", db.statements)
+	}
+	if args := db.statements[0].args; !reflect.DeepEqual(args, []interface{}{int64(statusSent), before}) {
+		t.Errorf("delete args = %v", args)
+	}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	db, ok := fakeDBMap.Load(dsn)
+	if !ok {
+		return nil, errors.New("fakeDriver-->没有注册的DSN:" + dsn)
+	}
+	return &fakeConn{db: db.(*fakeDB)}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (conn *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fakeConn-->不支持Prepare")
+}
+
+func (conn *fakeConn) Close() error {
+	return nil
+}
+
+func (conn *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (conn *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	conn.db.record(query, args)
+	return driver.RowsAffected(conn.db.affected), nil
+}
+
+func (conn *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	conn.db.record(query, args)
+	return &fakeRows{rows: conn.db.rows}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeRows struct {
+	rows  [][]driver.Value
+	index int
+}
+
+func (rows *fakeRows) Columns() []string {
+	return []string{"id", "topic", "event_key", "payload", "headers", "create_time"}
+}
+
+func (rows *fakeRows) Close() error {
+	return nil
+}
+
+func (rows *fakeRows) Next(dest []driver.Value) error {
+	if rows.index >= len(rows.rows) {
+		return io.EOF
+	}
+	copy(dest, rows.rows[rows.index])
+	rows.index++
+	return nil
+}
@@ -0,0 +1,427 @@
+package grm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BalanceType 从库的负载均衡方式
+// BalanceType Load balancing method of replicas
+type BalanceType int
+
+const (
+	// BalanceRoundRobin 轮询
+	// BalanceRoundRobin Round robin
+	BalanceRoundRobin BalanceType = iota
+	// BalanceRandom 随机
+	// BalanceRandom Random
+	BalanceRandom
+	// BalanceLeastConn 使用中的连接数最少,根据sql.DB.Stats().InUse
+	// BalanceLeastConn The least number of connections in use, according to sql.DB.Stats().InUse
+	BalanceLeastConn
+)
+
+// ReplicaSetConfig 一主多从的配置
+// ReplicaSetConfig Configuration of one primary and multiple replicas
+type ReplicaSetConfig struct {
+	// Primary 主库的配置
+	// Primary Configuration of the primary
+	Primary *DBConfig
+	// Replicas 从库的配置
+	// Replicas Configuration of the replicas
+	Replicas []*DBConfig
+	// Balance 从库的负载均衡方式,默认BalanceRoundRobin
+	// Balance Load balancing method of replicas, default BalanceRoundRobin
+	Balance BalanceType
+	// HealthCheckInterval 健康检查的间隔,默认5秒
+	// HealthCheckInterval Health check interval, default 5 seconds
+	HealthCheckInterval time.Duration
+	// PingTimeout 健康检查Ping的超时时间,默认2秒
+	// PingTimeout Timeout of health check Ping, default 2 seconds
+	PingTimeout time.Duration
+	// FailThreshold 连续失败多少次后移除从库,默认3次
+	// FailThreshold The number of consecutive failures before removing the replica, default 3 times
+	FailThreshold int
+	// RecoverThreshold 连续成功多少次后重新加入从库,默认2次
+	// RecoverThreshold The number of consecutive successes before re-admitting the replica, default 2 times
+	RecoverThreshold int
+	// MeasureLag 健康检查时是否测量从库的复制延迟,用于BindCtxMaxReplicaLag.mysql使用Seconds_Behind_Master,postgresql使用pg_last_xact_replay_timestamp()
+	// 其他数据库的方言需要实现ReplicaLagDialect,或者使用HeartbeatTable
+	// MeasureLag Whether to measure the replication lag of replicas during health check, used for BindCtxMaxReplicaLag.
+	// mysql uses Seconds_Behind_Master, postgresql uses pg_last_xact_replay_timestamp().
+	// The dialect of other databases needs to implement ReplicaLagDialect, or use HeartbeatTable
+	MeasureLag bool
+	// HeartbeatTable 心跳表,不为空时所有数据库都使用心跳表测量延迟,主库在健康检查时写入当前时间,从库读取,延迟的精度受HealthCheckInterval影响
+	// 建表语句: CREATE TABLE grm_heartbeat (id INT NOT NULL PRIMARY KEY, heartbeat_time BIGINT NOT NULL)
+	// HeartbeatTable Heartbeat table, when not empty, all databases use the heartbeat table to measure the lag, the primary writes the current time during the health check,
+	// and the replicas read it, the accuracy of the lag is affected by HealthCheckInterval
+	HeartbeatTable string
+}
+
+// ctxMaxReplicaLagKey 查询允许的从库最大延迟
+// ctxMaxReplicaLagKey The maximum replica lag allowed by the query
+const ctxMaxReplicaLagKey = wrapCtxKey("ctxMaxReplicaLagKey")
+
+// BindCtxMaxReplicaLag 绑定查询允许的从库最大延迟,延迟超过maxLag或者无法测量延迟的从库会被跳过,所有从库都被跳过时使用主库
+// 需要设置ReplicaSetConfig.MeasureLag和grm.FuncReadWriteStrategyCtx = replicaSet.ReadWriteStrategyCtx.parent不能为空
+// BindCtxMaxReplicaLag Bind the maximum replica lag allowed by the query, replicas whose lag exceeds maxLag or cannot be measured will be skipped,
+// and the primary will be used when all replicas are skipped.
+// Need to set ReplicaSetConfig.MeasureLag and grm.FuncReadWriteStrategyCtx = replicaSet.ReadWriteStrategyCtx. parent cannot be nil
+func BindCtxMaxReplicaLag(parent context.Context, maxLag time.Duration) (context.Context, error) {
+	if parent == nil {
+		return nil, errors.New("BindCtxMaxReplicaLag context的parent不能为nil")
+	}
+	ctx := context.WithValue(parent, ctxMaxReplicaLagKey, maxLag)
+	return ctx, nil
+}
+
+// replica 从库和健康检查的状态
+// replica Replica and health check status
+type replica struct {
+	dao       *DBDao
+	healthy   bool
+	fails     int
+	successes int
+	// 复制延迟,纳秒,-1代表无法测量,原子操作
+	// Replication lag, nanoseconds, -1 means it cannot be measured, atomic operation
+	lag int64
+}
+
+// ReplicaSet 一主多从的读写分离,写操作使用主库,读操作按照负载均衡方式使用健康的从库,没有健康的从库时使用主库
+// 使用方式: grm.FuncReadWriteStrategy = replicaSet.ReadWriteStrategy,需要根据复制延迟选择从库时,再设置grm.FuncReadWriteStrategyCtx = replicaSet.ReadWriteStrategyCtx
+// ReplicaSet Read-write separation of one primary and multiple replicas, write operations use the primary,
+// read operations use healthy replicas according to the load balancing method, and use the primary when there are no healthy replicas
+// Usage: grm.FuncReadWriteStrategy = replicaSet.ReadWriteStrategy, when you need to select replicas according to the replication lag,
+// also set grm.FuncReadWriteStrategyCtx = replicaSet.ReadWriteStrategyCtx
+type ReplicaSet struct {
+	config   ReplicaSetConfig
+	primary  *DBDao
+	replicas []*replica
+	// 健康的从库,[]*replica,由健康检查更新
+	// Healthy replicas, []*replica, updated by health check
+	healthyReplicas atomic.Value
+	counter         uint64
+	cancel          context.CancelFunc
+	closeOnce       sync.Once
+}
+
+// NewReplicaSet 创建一主多从,并启动从库的健康检查
+// NewReplicaSet Create one primary and multiple replicas, and start the health check of the replicas
+func NewReplicaSet(config *ReplicaSetConfig) (*ReplicaSet, error) {
+	if config == nil || config.Primary == nil {
+		return nil, errors.New("NewReplicaSet-->config和主库的配置不能为nil")
+	}
+	replicaSet := &ReplicaSet{config: *config}
+	if replicaSet.config.HealthCheckInterval <= 0 {
+		replicaSet.config.HealthCheckInterval = 5 * time.Second
+	}
+	if replicaSet.config.PingTimeout <= 0 {
+		replicaSet.config.PingTimeout = 2 * time.Second
+	}
+	if replicaSet.config.FailThreshold <= 0 {
+		replicaSet.config.FailThreshold = 3
+	}
+	if replicaSet.config.RecoverThreshold <= 0 {
+		replicaSet.config.RecoverThreshold = 2
+	}
+
+	primary, err := NewDao(config.Primary)
+	if err != nil {
+		return nil, err
+	}
+	replicaSet.primary = primary
+	for i, replicaConfig := range config.Replicas {
+		dao, err := NewDao(replicaConfig)
+		if err != nil {
+			replicaSet.closeDB()
+			return nil, errors.New("NewReplicaSet-->创建第" + strconv.Itoa(i) + "个从库失败: " + err.Error())
+		}
+		replicaSet.replicas = append(replicaSet.replicas, &replica{dao: dao, healthy: true, lag: -1})
+	}
+	replicaSet.healthyReplicas.Store(append([]*replica{}, replicaSet.replicas...))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	replicaSet.cancel = cancel
+	if len(replicaSet.replicas) > 0 {
+		go replicaSet.healthCheck(ctx)
+	}
+	return replicaSet, nil
+}
+
+// ReadWriteStrategy 读写分离的策略,用于赋值给FuncReadWriteStrategy,rwType=0 read,rwType=1 write
+// ReadWriteStrategy Read-write separation strategy, used to assign to FuncReadWriteStrategy, rwType=0 read, rwType=1 write
+func (replicaSet *ReplicaSet) ReadWriteStrategy(rwType int) *DBDao {
+	if rwType == 1 {
+		return replicaSet.primary
+	}
+	return replicaSet.balance(replicaSet.healthyReplicas.Load().([]*replica))
+}
+
+// ReadWriteStrategyCtx 根据ctx的读写分离策略,用于赋值给FuncReadWriteStrategyCtx.跳过延迟超过BindCtxMaxReplicaLag的从库,所有从库都被跳过时使用主库
+// ReadWriteStrategyCtx Read-write separation strategy according to ctx, used to assign to FuncReadWriteStrategyCtx.
+// Skip replicas whose lag exceeds BindCtxMaxReplicaLag, and use the primary when all replicas are skipped
+func (replicaSet *ReplicaSet) ReadWriteStrategyCtx(ctx context.Context, rwType int) *DBDao {
+	if rwType == 1 {
+		return replicaSet.primary
+	}
+	replicas := replicaSet.healthyReplicas.Load().([]*replica)
+	maxLag, ok := ctx.Value(ctxMaxReplicaLagKey).(time.Duration)
+	if !ok {
+		return replicaSet.balance(replicas)
+	}
+	lagReplicas := make([]*replica, 0, len(replicas))
+	for _, r := range replicas {
+		lag := atomic.LoadInt64(&r.lag)
+		if lag >= 0 && lag <= int64(maxLag) {
+			lagReplicas = append(lagReplicas, r)
+		}
+	}
+	return replicaSet.balance(lagReplicas)
+}
+
+// balance 按照负载均衡方式选择从库,没有从库时使用主库
+// balance Select a replica according to the load balancing method, and use the primary when there are no replicas
+func (replicaSet *ReplicaSet) balance(replicas []*replica) *DBDao {
+	if len(replicas) < 1 {
+		return replicaSet.primary
+	}
+	if len(replicas) == 1 {
+		return replicas[0].dao
+	}
+	switch replicaSet.config.Balance {
+	case BalanceRandom:
+		return replicas[rand.Intn(len(replicas))].dao
+	case BalanceLeastConn:
+		leastDao := replicas[0].dao
+		leastInUse := leastDao.dataSource.Stats().InUse
+		for _, r := range replicas[1:] {
+			if inUse := r.dao.dataSource.Stats().InUse; inUse < leastInUse {
+				leastDao, leastInUse = r.dao, inUse
+			}
+		}
+		return leastDao
+	}
+	index := atomic.AddUint64(&replicaSet.counter, 1)
+	return replicas[index%uint64(len(replicas))].dao
+}
+
+// Primary 返回主库
+// Primary Return the primary
+func (replicaSet *ReplicaSet) Primary() *DBDao {
+	return replicaSet.primary
+}
+
+// HealthyReplicas 返回当前健康的从库
+// HealthyReplicas Return the currently healthy replicas
+func (replicaSet *ReplicaSet) HealthyReplicas() []*DBDao {
+	replicas := replicaSet.healthyReplicas.Load().([]*replica)
+	daos := make([]*DBDao, 0, len(replicas))
+	for _, r := range replicas {
+		daos = append(daos, r.dao)
+	}
+	return daos
+}
+
+// Close 停止健康检查,关闭主库和从库的数据库连接
+// Close Stop the health check and close the database connections of the primary and replicas
+func (replicaSet *ReplicaSet) Close() error {
+	var err error
+	replicaSet.closeOnce.Do(func() {
+		replicaSet.cancel()
+		err = replicaSet.closeDB()
+	})
+	return err
+}
+
+// closeDB 关闭主库和从库的数据库连接
+// closeDB Close the database connections of the primary and replicas
+func (replicaSet *ReplicaSet) closeDB() error {
+	err := replicaSet.primary.CloseDB()
+	for _, r := range replicaSet.replicas {
+		if closeErr := r.dao.CloseDB(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// healthCheck 定时Ping从库,连续失败FailThreshold次移除,连续成功RecoverThreshold次重新加入
+// healthCheck Ping the replicas regularly, remove after FailThreshold consecutive failures, and re-admit after RecoverThreshold consecutive successes
+func (replicaSet *ReplicaSet) healthCheck(ctx context.Context) {
+	ticker := time.NewTicker(replicaSet.config.HealthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		replicaSet.checkReplicas(ctx)
+	}
+}
+
+// checkReplicas 执行一次健康检查,Ping失败的从库延迟重置为无法测量,更新健康的从库
+// checkReplicas Perform a health check, the lag of the replica that fails to Ping is reset to unknown, and update the healthy replicas
+func (replicaSet *ReplicaSet) checkReplicas(ctx context.Context) {
+	if replicaSet.config.MeasureLag && replicaSet.config.HeartbeatTable != "" {
+		if err := replicaSet.writeHeartbeat(ctx); err != nil {
+			LogErr("ReplicaSet-->主库写入心跳失败: " + err.Error())
+		}
+	}
+
+	changed := false
+	for i, r := range replicaSet.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, replicaSet.config.PingTimeout)
+		err := r.dao.dataSource.PingContext(pingCtx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			//Ping失败时延迟已经无法测量,避免BindCtxMaxReplicaLag使用过期的延迟选择这个从库
+			//The lag cannot be measured when Ping fails, to avoid BindCtxMaxReplicaLag selecting this replica with a stale lag
+			atomic.StoreInt64(&r.lag, -1)
+			r.successes = 0
+			r.fails++
+			if r.healthy && r.fails >= replicaSet.config.FailThreshold {
+				r.healthy = false
+				changed = true
+				LogErr("ReplicaSet-->第" + strconv.Itoa(i) + "个从库不可用,已经移除: " + err.Error())
+			}
+			continue
+		}
+		if replicaSet.config.MeasureLag {
+			replicaSet.measureLag(ctx, r)
+		}
+		r.fails = 0
+		r.successes++
+		if !r.healthy && r.successes >= replicaSet.config.RecoverThreshold {
+			r.healthy = true
+			changed = true
+			LogErr("ReplicaSet-->第" + strconv.Itoa(i) + "个从库已经恢复,重新加入")
+		}
+	}
+
+	if changed {
+		replicas := make([]*replica, 0, len(replicaSet.replicas))
+		for _, r := range replicaSet.replicas {
+			if r.healthy {
+				replicas = append(replicas, r)
+			}
+		}
+		replicaSet.healthyReplicas.Store(replicas)
+	}
+}
+
+// writeHeartbeat 主库写入心跳表的当前时间,毫秒
+// writeHeartbeat The primary writes the current time to the heartbeat table, milliseconds
+func (replicaSet *ReplicaSet) writeHeartbeat(ctx context.Context) error {
+	dbConn, err := replicaSet.primary.newDBConn()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	updateSQL, err := reBindSQL(dbConn.cfg, "UPDATE "+replicaSet.config.HeartbeatTable+" SET heartbeat_time=? WHERE id=1")
+	if err != nil {
+		return err
+	}
+	res, err := dbConn.execCtx(ctx, &updateSQL, []interface{}{now})
+	if err != nil {
+		return err
+	}
+	if affected, err := (*res).RowsAffected(); err == nil && affected > 0 {
+		return nil
+	}
+	insertSQL, err := reBindSQL(dbConn.cfg, "INSERT INTO "+replicaSet.config.HeartbeatTable+" (id,heartbeat_time) VALUES (1,?)")
+	if err != nil {
+		return err
+	}
+	_, err = dbConn.execCtx(ctx, &insertSQL, []interface{}{now})
+	return err
+}
+
+// measureLag 测量从库的复制延迟,无法测量时记录为-1
+// measureLag Measure the replication lag of the replica, record -1 when it cannot be measured
+func (replicaSet *ReplicaSet) measureLag(ctx context.Context, r *replica) {
+	lag, err := replicaSet.queryLag(ctx, r.dao)
+	if err != nil {
+		if atomic.SwapInt64(&r.lag, -1) >= 0 {
+			LogErr("ReplicaSet-->测量从库延迟失败: " + err.Error())
+		}
+		return
+	}
+	atomic.StoreInt64(&r.lag, int64(lag))
+}
+
+// queryLag 查询从库的复制延迟
+// queryLag Query the replication lag of the replica
+func (replicaSet *ReplicaSet) queryLag(ctx context.Context, dao *DBDao) (time.Duration, error) {
+	dbConn, err := dao.newDBConn()
+	if err != nil {
+		return 0, err
+	}
+	queryCtx, cancel := context.WithTimeout(ctx, replicaSet.config.PingTimeout)
+	defer cancel()
+
+	if replicaSet.config.HeartbeatTable != "" {
+		heartbeatSQL := "SELECT heartbeat_time FROM " + replicaSet.config.HeartbeatTable + " WHERE id=1"
+		var heartbeat int64
+		if err = dbConn.queryRowCtx(queryCtx, &heartbeatSQL, nil).Scan(&heartbeat); err != nil {
+			return 0, err
+		}
+		lag := time.Duration(time.Now().UnixNano() - heartbeat*int64(time.Millisecond))
+		if lag < 0 {
+			lag = 0
+		}
+		return lag, nil
+	}
+
+	lagSQL, err := wrapReplicaLagSQL(dbConn.cfg)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := dbConn.queryCtx(queryCtx, &lagSQL, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	//不是从库,没有复制延迟
+	//Not a replica, no replication lag
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	//SHOW SLAVE STATUS的字段很多,按照字段名取Seconds_Behind_Master
+	//SHOW SLAVE STATUS has many fields, get Seconds_Behind_Master by field name
+	values := make([]sql.NullFloat64, len(columns))
+	dest := make([]interface{}, len(columns))
+	lagIndex := 0
+	for i, column := range columns {
+		if column == "Seconds_Behind_Master" || column == "Seconds_Behind_Source" {
+			lagIndex = i
+			dest[i] = &values[i]
+		} else {
+			dest[i] = new(sql.RawBytes)
+		}
+	}
+	if len(columns) == 1 {
+		dest[0] = &values[0]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	if !values[lagIndex].Valid {
+		return 0, errors.New("queryLag-->复制没有运行,无法获取复制延迟")
+	}
+	return time.Duration(values[lagIndex].Float64 * float64(time.Second)), nil
+}
@@ -0,0 +1,146 @@
+package grm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newFakeReplicaSet 创建一主两从的ReplicaSet,返回主库和从库的fakeDB.健康检查由测试调用checkReplicas执行
+// newFakeReplicaSet Create a ReplicaSet of one primary and two replicas, return the fakeDB of the primary and replicas.
+// The health check is performed by the test calling checkReplicas
+func newFakeReplicaSet(t *testing.T, config *ReplicaSetConfig) (*ReplicaSet, []*fakeDB) {
+	dbs := make([]*fakeDB, 3)
+	configs := make([]*DBConfig, 3)
+	for i := range dbs {
+		dbs[i] = &fakeDB{}
+		dsn := t.Name() + "_" + strconv.Itoa(i)
+		fakeDBMap.Store(dsn, dbs[i])
+		configs[i] = &DBConfig{DSN: dsn, Driver: fakeDriverName, Dialect: "mysql"}
+	}
+	config.Primary = configs[0]
+	config.Replicas = configs[1:]
+	config.HealthCheckInterval = time.Hour
+	replicaSet, err := NewReplicaSet(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		replicaSet.Close()
+		for _, dbConfig := range configs {
+			fakeDBMap.Delete(dbConfig.DSN)
+		}
+	})
+	return replicaSet, dbs
+}
+
+func TestReplicaSetBalance(t *testing.T) {
+	replicaSet, _ := newFakeReplicaSet(t, &ReplicaSetConfig{})
+	replicas := replicaSet.HealthyReplicas()
+	if len(replicas) != 2 {
+		t.Fatalf("HealthyReplicas() = %d, want 2", len(replicas))
+	}
+	if replicaSet.ReadWriteStrategy(1) != replicaSet.Primary() {
+		t.Error("写操作没有使用主库")
+	}
+	//轮询使用从库
+	//Use the replicas in round robin
+	first := replicaSet.ReadWriteStrategy(0)
+	second := replicaSet.ReadWriteStrategy(0)
+	if first == second || first == replicaSet.Primary() || second == replicaSet.Primary() {
+		t.Errorf("round robin = %p,%p, want the two replicas", first, second)
+	}
+	if replicaSet.ReadWriteStrategy(0) != first {
+		t.Error("轮询没有回到第一个从库")
+	}
+}
+
+func TestReplicaSetHealthCheck(t *testing.T) {
+	replicaSet, dbs := newFakeReplicaSet(t, &ReplicaSetConfig{FailThreshold: 2, RecoverThreshold: 1})
+	ctx := context.Background()
+	pingErr := errors.New("ping failed")
+	dbs[1].pingErr = func() error {
+		return pingErr
+	}
+	//连续失败FailThreshold次之后移除
+	//Removed after FailThreshold consecutive failures
+	replicaSet.checkReplicas(ctx)
+	if replicas := replicaSet.HealthyReplicas(); len(replicas) != 2 {
+		t.Fatalf("HealthyReplicas() = %d, want 2 after one failure", len(replicas))
+	}
+	replicaSet.checkReplicas(ctx)
+	replicas := replicaSet.HealthyReplicas()
+	if len(replicas) != 1 || replicas[0] != replicaSet.replicas[1].dao {
+		t.Fatalf("HealthyReplicas() = %v, want only the second replica", replicas)
+	}
+	for i := 0; i < 3; i++ {
+		if replicaSet.ReadWriteStrategy(0) != replicaSet.replicas[1].dao {
+			t.Error("使用了移除的从库")
+		}
+	}
+	//连续成功RecoverThreshold次之后重新加入
+	//Re-admitted after RecoverThreshold consecutive successes
+	dbs[1].pingErr = nil
+	replicaSet.checkReplicas(ctx)
+	if replicas = replicaSet.HealthyReplicas(); len(replicas) != 2 {
+		t.Errorf("HealthyReplicas() = %d, want 2 after recovery", len(replicas))
+	}
+	//所有从库都不可用时使用主库
+	//Use the primary when all replicas are unavailable
+	dbs[1].pingErr = func() error {
+		return pingErr
+	}
+	dbs[2].pingErr = dbs[1].pingErr
+	replicaSet.checkReplicas(ctx)
+	replicaSet.checkReplicas(ctx)
+	if replicaSet.ReadWriteStrategy(0) != replicaSet.Primary() {
+		t.Error("所有从库不可用时没有使用主库")
+	}
+}
+
+func TestReplicaSetMaxLag(t *testing.T) {
+	replicaSet, dbs := newFakeReplicaSet(t, &ReplicaSetConfig{MeasureLag: true})
+	lagRows := func(seconds float64) func(query string) ([]string, [][]driver.Value) {
+		return func(query string) ([]string, [][]driver.Value) {
+			return []string{"Slave_IO_State", "Seconds_Behind_Master"}, [][]driver.Value{{"Waiting", seconds}}
+		}
+	}
+	dbs[1].queryRows = lagRows(10)
+	dbs[2].queryRows = lagRows(1)
+	ctx := context.Background()
+	replicaSet.checkReplicas(ctx)
+
+	//跳过延迟超过maxLag的从库
+	//Skip replicas whose lag exceeds maxLag
+	lagCtx, err := BindCtxMaxReplicaLag(ctx, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if replicaSet.ReadWriteStrategyCtx(lagCtx, 0) != replicaSet.replicas[1].dao {
+			t.Error("没有跳过延迟10秒的从库")
+		}
+	}
+	//所有从库都被跳过时使用主库
+	//Use the primary when all replicas are skipped
+	strictCtx, _ := BindCtxMaxReplicaLag(ctx, 500*time.Millisecond)
+	if replicaSet.ReadWriteStrategyCtx(strictCtx, 0) != replicaSet.Primary() {
+		t.Error("所有从库都被跳过时没有使用主库")
+	}
+
+	//Ping失败时延迟重置为无法测量,不再使用过期的延迟
+	//The lag is reset to unknown when Ping fails, and the stale lag is no longer used
+	dbs[2].pingErr = func() error {
+		return errors.New("ping failed")
+	}
+	replicaSet.checkReplicas(ctx)
+	if lag := replicaSet.replicas[1].lag; lag != -1 {
+		t.Errorf("lag = %d, want -1 after the ping failed", lag)
+	}
+	if replicaSet.ReadWriteStrategyCtx(lagCtx, 0) != replicaSet.Primary() {
+		t.Error("使用了Ping失败的从库")
+	}
+}
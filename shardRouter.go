@@ -0,0 +1,719 @@
+package grm
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShardStrategy 分片策略,根据分片键计算分片的序号,序号从0开始,小于shardCount
+// ShardStrategy Sharding strategy, calculate the shard index according to the shard key, the index starts from 0 and is less than shardCount
+type ShardStrategy interface {
+	Shard(ctx context.Context, key interface{}, shardCount int) (int, error)
+}
+
+// HashModStrategy 哈希取模,整数类型的分片键直接取模,其他类型使用crc32取模
+// HashModStrategy Hash modulo, the shard key of integer type is directly modulo, other types use crc32 modulo
+type HashModStrategy struct{}
+
+// Shard 计算分片的序号
+// Shard Calculate the shard index
+func (strategy HashModStrategy) Shard(ctx context.Context, key interface{}, shardCount int) (int, error) {
+	if value, ok := shardKeyInt64(key); ok {
+		index := int(value % int64(shardCount))
+		if index < 0 {
+			index += shardCount
+		}
+		return index, nil
+	}
+	var bytes []byte
+	switch v := key.(type) {
+	case string:
+		bytes = []byte(v)
+	case []byte:
+		bytes = v
+	default:
+		bytes = []byte(fmt.Sprint(key))
+	}
+	return int(crc32.ChecksumIEEE(bytes) % uint32(shardCount)), nil
+}
+
+// RangeStrategy 范围分片,分片键必须是整数类型.Bounds是升序的分片上界(不包含),分片键小于Bounds[i]使用第i个分片,大于等于最后一个上界使用最后一个分片
+// 例如Bounds为[1000000,2000000],小于1000000是第0个分片,小于2000000是第1个分片,其他是第2个分片
+// RangeStrategy Range sharding, the shard key must be an integer type. Bounds are the ascending upper bounds (exclusive) of the shards,
+// if the shard key is less than Bounds[i], use the i-th shard, if it is greater than or equal to the last upper bound, use the last shard
+// For example, Bounds is [1000000,2000000], less than 1000000 is the 0th shard, less than 2000000 is the 1st shard, and the others are the 2nd shard
+type RangeStrategy struct {
+	Bounds []int64
+}
+
+// Shard 计算分片的序号
+// Shard Calculate the shard index
+func (strategy RangeStrategy) Shard(ctx context.Context, key interface{}, shardCount int) (int, error) {
+	value, ok := shardKeyInt64(key)
+	if !ok {
+		return -1, fmt.Errorf("RangeStrategy-->分片键必须是整数类型:%v", key)
+	}
+	index := sort.Search(len(strategy.Bounds), func(i int) bool {
+		return value < strategy.Bounds[i]
+	})
+	if index >= shardCount {
+		index = shardCount - 1
+	}
+	return index, nil
+}
+
+// LookupStrategy 查找表分片,优先使用Lookup函数,例如查询路由表,否则使用Table,key是fmt.Sprint(分片键)
+// LookupStrategy Lookup table sharding, the Lookup function is used first, such as querying the routing table,
+// otherwise use Table, the key is fmt.Sprint(shard key)
+type LookupStrategy struct {
+	Table  map[string]int
+	Lookup func(ctx context.Context, key interface{}) (int, error)
+}
+
+// Shard 计算分片的序号
+// Shard Calculate the shard index
+func (strategy LookupStrategy) Shard(ctx context.Context, key interface{}, shardCount int) (int, error) {
+	if strategy.Lookup != nil {
+		return strategy.Lookup(ctx, key)
+	}
+	index, ok := strategy.Table[fmt.Sprint(key)]
+	if !ok {
+		return -1, fmt.Errorf("LookupStrategy-->查找表中没有分片键:%v", key)
+	}
+	return index, nil
+}
+
+// shardKeyInt64 整数类型的分片键转换为int64
+// shardKeyInt64 Convert the shard key of integer type to int64
+func shardKeyInt64(key interface{}) (int64, bool) {
+	valueOf := reflect.Indirect(reflect.ValueOf(key))
+	switch valueOf.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return valueOf.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(valueOf.Uint()), true
+	}
+	return 0, false
+}
+
+// ctxShardKey 分片键
+// ctxShardKey Shard key
+const ctxShardKey = wrapCtxKey("ctxShardKey")
+
+// BindCtxShardKey 绑定分片键到ctx,ShardRouter的实体没有分片键字段,或者QueryRow,Query时使用.parent不能为空
+// BindCtxShardKey Bind the shard key to ctx, used when the entity of ShardRouter has no shard key field, or QueryRow, Query. parent cannot be nil
+func BindCtxShardKey(parent context.Context, key interface{}) (context.Context, error) {
+	if parent == nil {
+		return nil, errors.New("BindCtxShardKey context的parent不能为nil")
+	}
+	if key == nil {
+		return nil, errors.New("BindCtxShardKey 分片键不能为nil")
+	}
+	ctx := context.WithValue(parent, ctxShardKey, key)
+	return ctx, nil
+}
+
+// ShardRouter 水平分片的路由,根据分片键选择DBDao.分片键是实体类column字段的值,或者BindCtxShardKey绑定的值
+// Insert,Update,Delete,QueryRow自动选择分片,ctx中没有事务时自动开启分片的事务,不支持跨分片的事务
+// Query的ctx没有分片键时,查询所有的分片并按照ORDER BY归并结果,总条数是所有分片的总和,参见Query
+// ShardRouter Horizontal sharding router, select DBDao according to the shard key. The shard key is the value of the column field of the entity,
+// or the value bound by BindCtxShardKey. Insert, Update, Delete, QueryRow automatically select the shard,
+// and automatically start the transaction of the shard when there is no transaction in ctx, cross-shard transactions are not supported
+// When the ctx of Query has no shard key, query all shards and merge the results according to ORDER BY,
+// and the total count is the sum of all shards, see Query
+type ShardRouter struct {
+	shards   []*DBDao
+	column   string
+	strategy ShardStrategy
+}
+
+// NewShardRouter 创建分片路由,shards是按照序号排列的分片,column是实体类中分片键的数据库字段名,为空时只使用ctx绑定的分片键
+// NewShardRouter Create a shard router, shards are shards arranged by index, column is the database field name of the shard key in the entity,
+// when empty, only the shard key bound by ctx is used
+func NewShardRouter(shards []*DBDao, column string, strategy ShardStrategy) (*ShardRouter, error) {
+	if len(shards) < 1 || strategy == nil {
+		return nil, errors.New("NewShardRouter-->shards和strategy不能为空")
+	}
+	for i, shard := range shards {
+		if shard == nil || shard.dataSource == nil {
+			return nil, errors.New("NewShardRouter-->第" + strconv.Itoa(i) + "个分片不能为nil")
+		}
+	}
+	return &ShardRouter{shards: shards, column: strings.ToLower(column), strategy: strategy}, nil
+}
+
+// Shards 返回所有的分片
+// Shards Return all shards
+func (router *ShardRouter) Shards() []*DBDao {
+	return append([]*DBDao{}, router.shards...)
+}
+
+// Dao 根据分片键返回分片的DBDao
+// Dao Return the DBDao of the shard according to the shard key
+func (router *ShardRouter) Dao(ctx context.Context, key interface{}) (*DBDao, error) {
+	index, err := router.strategy.Shard(ctx, key, len(router.shards))
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(router.shards) {
+		return nil, fmt.Errorf("ShardRouter-->分片键%v的分片序号%d超出范围", key, index)
+	}
+	return router.shards[index], nil
+}
+
+// shardKey 获取分片键,优先使用实体类column字段的值,其次是ctx绑定的值
+// shardKey Get the shard key, the value of the column field of the entity is used first, followed by the value bound by ctx
+func (router *ShardRouter) shardKey(ctx context.Context, entity interface{}) (interface{}, error) {
+	if router.column != "" && entity != nil {
+		typeOf, err := checkEntityKind(entity)
+		if err != nil {
+			return nil, err
+		}
+		dbColumnFieldMap, err := getDBColumnFieldMap(&typeOf)
+		if err != nil {
+			return nil, err
+		}
+		if field, ok := dbColumnFieldMap[router.column]; ok {
+			return reflect.ValueOf(entity).Elem().FieldByName(field.Name).Interface(), nil
+		}
+	}
+	if key := ctx.Value(ctxShardKey); key != nil {
+		return key, nil
+	}
+	return nil, errors.New("ShardRouter-->没有分片键,实体类需要有" + router.column + "字段,或者使用BindCtxShardKey绑定分片键")
+}
+
+// bindShardCtx 绑定分片的dbConn到ctx,ctx中已经是这个分片的dbConn时直接使用
+// ctx中有其他分片的事务时,写操作返回错误,读操作使用新的dbConn
+// bindShardCtx Bind the dbConn of the shard to ctx, use it directly when ctx is already the dbConn of this shard.
+// When there is a transaction of other shards in ctx, the write operation returns an error, and the read operation uses a new dbConn
+func bindShardCtx(ctx context.Context, dao *DBDao, write bool) (context.Context, error) {
+	dbConn, err := getDBConn(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	if dbConn != nil && dbConn.db == dao.dataSource.DB {
+		return ctx, nil
+	}
+	if write && dbConn != nil && dbConn.inTx() {
+		return ctx, errors.New("ShardRouter-->不支持跨分片的事务,ctx中已经有其他分片的事务")
+	}
+	return dao.BindCtxDBConn(ctx)
+}
+
+// Transaction 根据分片键选择分片,开启分片的事务,事务函数内的操作都在这个分片上执行
+// Transaction Select the shard according to the shard key and start the transaction of the shard, the operations in the transaction function are all executed on this shard
+func (router *ShardRouter) Transaction(ctx context.Context, key interface{}, doTransaction func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	dao, err := router.Dao(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = bindShardCtx(ctx, dao, true)
+	if err != nil {
+		return nil, err
+	}
+	return Transaction(ctx, doTransaction)
+}
+
+// write 在实体的分片上执行写操作,ctx中没有事务时自动开启事务
+// write Perform the write operation on the shard of the entity, and automatically start the transaction when there is no transaction in ctx
+func (router *ShardRouter) write(ctx context.Context, entity IEntityStruct, doWrite func(ctx context.Context) (int, error)) (int, error) {
+	key, err := router.shardKey(ctx, entity)
+	if err != nil {
+		return 0, err
+	}
+	affected := 0
+	_, err = router.Transaction(ctx, key, func(ctx context.Context) (interface{}, error) {
+		var writeErr error
+		affected, writeErr = doWrite(ctx)
+		return nil, writeErr
+	})
+	return affected, err
+}
+
+// Insert 在实体的分片上保存
+// Insert Save on the shard of the entity
+func (router *ShardRouter) Insert(ctx context.Context, entity IEntityStruct) (int, error) {
+	return router.write(ctx, entity, func(ctx context.Context) (int, error) {
+		return Insert(ctx, entity)
+	})
+}
+
+// Update 在实体的分片上更新
+// Update Update on the shard of the entity
+func (router *ShardRouter) Update(ctx context.Context, entity IEntityStruct) (int, error) {
+	return router.write(ctx, entity, func(ctx context.Context) (int, error) {
+		return Update(ctx, entity)
+	})
+}
+
+// UpdateNotZeroValue 在实体的分片上更新不为零值的字段
+// UpdateNotZeroValue Update the fields that are not zero on the shard of the entity
+func (router *ShardRouter) UpdateNotZeroValue(ctx context.Context, entity IEntityStruct) (int, error) {
+	return router.write(ctx, entity, func(ctx context.Context) (int, error) {
+		return UpdateNotZeroValue(ctx, entity)
+	})
+}
+
+// Delete 在实体的分片上删除
+// Delete Delete on the shard of the entity
+func (router *ShardRouter) Delete(ctx context.Context, entity IEntityStruct) (int, error) {
+	return router.write(ctx, entity, func(ctx context.Context) (int, error) {
+		return Delete(ctx, entity)
+	})
+}
+
+// QueryRow 在ctx绑定的分片键对应的分片上查询一条数据
+// QueryRow Query a piece of data on the shard corresponding to the shard key bound by ctx
+func (router *ShardRouter) QueryRow(ctx context.Context, finder *Finder, entity interface{}) (bool, error) {
+	key, err := router.shardKey(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	dao, err := router.Dao(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	ctx, err = bindShardCtx(ctx, dao, false)
+	if err != nil {
+		return false, err
+	}
+	return QueryRow(ctx, finder, entity)
+}
+
+// Query ctx绑定了分片键时,只查询对应的分片.否则并发查询所有的分片,每个分片使用复制的Finder,page的总条数是所有分片的总和
+// 语句有ORDER BY时,按照排序字段归并所有分片的结果,分页时每个分片查询前offset+limit条数据,归并后取全局的分页数据
+// 跨分片的排序项只支持字段名,可以有表别名和ASC/DESC,字段必须在接收结果的struct中,NULL视为最小值
+// 没有ORDER BY时按照分片顺序合并,分页时先查询每个分片的总条数,按照分片顺序计算每个分片的offset和limit
+// Query When ctx is bound with a shard key, only the corresponding shard is queried. Otherwise, query all shards concurrently,
+// each shard uses a copied Finder, and the total count of page is the sum of all shards.
+// When the statement has ORDER BY, the results of all shards are merged according to the order columns. When paging,
+// each shard queries the first offset+limit rows, and the global page is taken after merging.
+// The cross-shard order items only support column names, with optional table alias and ASC/DESC,
+// the columns must be in the struct that receives the results, and NULL is regarded as the minimum value.
+// Without ORDER BY, the results are merged in shard order. When paging, first query the total count of each shard,
+// and calculate the offset and limit of each shard in shard order
+func (router *ShardRouter) Query(ctx context.Context, finder *Finder, rowsSlicePtr interface{}, page *Page) error {
+	if key := ctx.Value(ctxShardKey); key != nil {
+		dao, err := router.Dao(ctx, key)
+		if err != nil {
+			return err
+		}
+		ctx, err = bindShardCtx(ctx, dao, false)
+		if err != nil {
+			return err
+		}
+		return Query(ctx, finder, rowsSlicePtr, page)
+	}
+
+	if finder == nil || rowsSlicePtr == nil {
+		return errors.New("ShardRouter-->Query的finder和rowsSlicePtr不能为nil")
+	}
+	sliceValue := reflect.Indirect(reflect.ValueOf(rowsSlicePtr))
+	if reflect.ValueOf(rowsSlicePtr).Kind() != reflect.Ptr || sliceValue.Kind() != reflect.Slice {
+		return errors.New("ShardRouter-->Query数组必须是*[]struct类型或者*[]*struct或者基础类型数组的指针")
+	}
+	//在当前goroutine生成语句,之后每个分片使用复制的Finder,避免并发修改同一个Finder
+	//Generate the statement in the current goroutine, and then each shard uses a copied Finder to avoid concurrent modification of the same Finder
+	sqlStr, err := finder.GetSQL()
+	if err != nil {
+		return err
+	}
+	orderKeys, err := parseShardOrderBy(sqlStr)
+	if err != nil {
+		return err
+	}
+	var sortKeys []func(row reflect.Value) reflect.Value
+	if len(orderKeys) > 0 {
+		sortKeys, err = shardSortKeys(sliceValue.Type().Elem(), orderKeys)
+		if err != nil {
+			return err
+		}
+	}
+
+	shardCount := len(router.shards)
+	ctxs := make([]context.Context, shardCount)
+	for i, dao := range router.shards {
+		if ctxs[i], err = bindShardCtx(ctx, dao, false); err != nil {
+			return err
+		}
+	}
+
+	//每个分片查询的finder,nil代表不查询这个分片
+	//The finder of each shard query, nil means not to query this shard
+	shardFinders := make([]*Finder, shardCount)
+	for i := range shardFinders {
+		shardFinders[i] = finder.clone()
+	}
+	//归并后跳过的条数和取的条数,-1代表全部
+	//The number of rows skipped and taken after merging, -1 means all
+	offset, limit := 0, -1
+	if page != nil {
+		counts := make([]int, shardCount)
+		err = scatterShards(shardCount, func(i int) error {
+			var countErr error
+			counts[i], countErr = selectCount(ctxs[i], shardFinders[i])
+			return countErr
+		})
+		if err != nil {
+			return errors.New("ShardRouter-->Query查询分片总条数错误: " + err.Error())
+		}
+		total := 0
+		for _, count := range counts {
+			total += count
+		}
+		page.setTotalCount(total)
+
+		offset = page.PageSize * (page.PageNo - 1)
+		limit = page.PageSize
+		if len(sortKeys) > 0 {
+			//每个分片查询前offset+limit条数据,归并后再取分页的数据
+			//Each shard queries the first offset+limit rows, and the page data is taken after merging
+			for i := range shardFinders {
+				if limit <= 0 || counts[i] == 0 {
+					shardFinders[i] = nil
+					continue
+				}
+				limitSQL, err := wrapLimitSQL(router.shards[i].config, sqlStr, 0, offset+limit)
+				if err != nil {
+					return err
+				}
+				shardFinders[i] = shardLimitFinder(finder, limitSQL)
+			}
+		} else {
+			//没有排序,按照分片顺序计算每个分片的offset和limit
+			//No order, calculate the offset and limit of each shard in shard order
+			shardOffset, shardLimit := offset, limit
+			for i, count := range counts {
+				if shardLimit <= 0 || shardOffset >= count {
+					shardOffset -= count
+					if shardOffset < 0 {
+						shardOffset = 0
+					}
+					shardFinders[i] = nil
+					continue
+				}
+				size := count - shardOffset
+				if size > shardLimit {
+					size = shardLimit
+				}
+				limitSQL, err := wrapLimitSQL(router.shards[i].config, sqlStr, shardOffset, size)
+				if err != nil {
+					return err
+				}
+				shardFinders[i] = shardLimitFinder(finder, limitSQL)
+				shardLimit -= size
+				shardOffset = 0
+			}
+			offset, limit = 0, -1
+		}
+	}
+
+	//每个分片查询到的数据
+	//The data queried by each shard
+	results := make([]reflect.Value, shardCount)
+	err = scatterShards(shardCount, func(i int) error {
+		if shardFinders[i] == nil {
+			return nil
+		}
+		result := reflect.New(sliceValue.Type())
+		result.Elem().Set(reflect.MakeSlice(sliceValue.Type(), 0, 0))
+		if queryErr := Query(ctxs[i], shardFinders[i], result.Interface(), nil); queryErr != nil {
+			return queryErr
+		}
+		results[i] = result.Elem()
+		return nil
+	})
+	if err != nil {
+		return errors.New("ShardRouter-->Query查询分片错误: " + err.Error())
+	}
+	if len(sortKeys) > 0 {
+		merged, err := mergeShardResults(results, sliceValue.Type(), sortKeys, orderKeys, offset, limit)
+		if err != nil {
+			return errors.New("ShardRouter-->Query归并分片结果错误: " + err.Error())
+		}
+		sliceValue.Set(reflect.AppendSlice(sliceValue, merged))
+		return nil
+	}
+	for _, result := range results {
+		if result.IsValid() {
+			sliceValue.Set(reflect.AppendSlice(sliceValue, result))
+		}
+	}
+	return nil
+}
+
+// shardLimitFinder 使用分页语句创建分片查询的Finder,复制finder的参数和属性
+// shardLimitFinder Create the Finder of the shard query with the paging statement, copying the parameters and attributes of the finder
+func shardLimitFinder(finder *Finder, limitSQL string) *Finder {
+	shardFinder := finder.clone()
+	shardFinder.sqlBuilder.Reset()
+	shardFinder.sqlBuilder.WriteString(limitSQL)
+	shardFinder.sqlStr = ""
+	shardFinder.CountFinder = nil
+	return shardFinder
+}
+
+// shardOrderKey 跨分片归并的排序字段
+// shardOrderKey The order column of the cross-shard merge
+type shardOrderKey struct {
+	column string
+	desc   bool
+}
+
+// shardOrderColumnRegexp 跨分片排序支持的字段名
+// shardOrderColumnRegexp The column name supported by cross-shard order
+var shardOrderColumnRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseShardOrderBy 解析语句最外层的ORDER BY,没有ORDER BY返回nil.排序项只支持字段名,可以有表别名和ASC/DESC,其他的返回错误
+// parseShardOrderBy Parse the outermost ORDER BY of the statement, return nil if there is no ORDER BY.
+// The order items only support column names, with optional table alias and ASC/DESC, others return an error
+func parseShardOrderBy(sqlStr string) ([]shardOrderKey, error) {
+	locs := orderByRegexp.FindAllStringIndex(sqlStr, -1)
+	if len(locs) < 1 {
+		return nil, nil
+	}
+	orderBy := sqlStr[locs[len(locs)-1][1]:]
+	//括号不匹配,ORDER BY在子查询里,最外层没有排序
+	//The parentheses do not match, ORDER BY is in the subquery, and the outermost layer has no order
+	if strings.Count(orderBy, "(") != strings.Count(orderBy, ")") {
+		return nil, nil
+	}
+	keys := make([]shardOrderKey, 0)
+	for _, item := range splitOrderByItems(orderBy) {
+		fields := strings.Fields(item)
+		key := shardOrderKey{}
+		if len(fields) == 2 && strings.EqualFold(fields[1], "desc") {
+			key.desc = true
+		} else if !(len(fields) == 1 || (len(fields) == 2 && strings.EqualFold(fields[1], "asc"))) {
+			return nil, errors.New("ShardRouter-->跨分片排序只支持字段名和ASC/DESC,不支持:" + item)
+		}
+		column := fields[0]
+		if i := strings.LastIndex(column, "."); i >= 0 {
+			column = column[i+1:]
+		}
+		column = strings.Trim(column, "`\"[]")
+		if !shardOrderColumnRegexp.MatchString(column) {
+			return nil, errors.New("ShardRouter-->跨分片排序只支持字段名,不支持:" + item)
+		}
+		key.column = strings.ToLower(column)
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// shardSortKeys 返回获取每行数据排序字段值的函数,基础类型的数组只支持一个排序字段,使用数据本身的值
+// shardSortKeys Return the functions to get the value of the order columns of each row,
+// the slice of basic type only supports one order column, and uses the value of the row itself
+func shardSortKeys(elemType reflect.Type, keys []shardOrderKey) ([]func(row reflect.Value) reflect.Value, error) {
+	baseType := elemType
+	if baseType.Kind() == reflect.Ptr {
+		baseType = baseType.Elem()
+	}
+	sortKeys := make([]func(row reflect.Value) reflect.Value, len(keys))
+	if baseType.Kind() != reflect.Struct || baseType == reflect.TypeOf(time.Time{}) {
+		if len(keys) != 1 {
+			return nil, errors.New("ShardRouter-->基础类型的跨分片查询只支持一个排序字段")
+		}
+		sortKeys[0] = func(row reflect.Value) reflect.Value {
+			return row
+		}
+		return sortKeys, nil
+	}
+	dbColumnFieldMap, err := getDBColumnFieldMap(&baseType)
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		field, ok := dbColumnFieldMap[key.column]
+		if !ok {
+			return nil, errors.New("ShardRouter-->跨分片排序的字段" + key.column + "不在" + baseType.String() + "中")
+		}
+		name := field.Name
+		sortKeys[i] = func(row reflect.Value) reflect.Value {
+			return reflect.Indirect(row).FieldByName(name)
+		}
+	}
+	return sortKeys, nil
+}
+
+// mergeShardResults 多路归并各个分片已经排好序的结果,跳过offset条,最多取limit条,limit为-1取全部.排序相同时分片序号小的在前
+// mergeShardResults Multi-way merge the sorted results of each shard, skip offset rows, take up to limit rows, take all when limit is -1.
+// When the order is the same, the shard with the smaller index comes first
+func mergeShardResults(results []reflect.Value, sliceType reflect.Type, sortKeys []func(row reflect.Value) reflect.Value, keys []shardOrderKey, offset int, limit int) (reflect.Value, error) {
+	merged := reflect.MakeSlice(sliceType, 0, 0)
+	heads := make([]int, len(results))
+	skipped := 0
+	for limit < 0 || merged.Len() < limit {
+		minShard := -1
+		for i, result := range results {
+			if !result.IsValid() || heads[i] >= result.Len() {
+				continue
+			}
+			if minShard < 0 {
+				minShard = i
+				continue
+			}
+			cmp, err := compareShardRows(result.Index(heads[i]), results[minShard].Index(heads[minShard]), sortKeys, keys)
+			if err != nil {
+				return merged, err
+			}
+			if cmp < 0 {
+				minShard = i
+			}
+		}
+		if minShard < 0 {
+			break
+		}
+		if skipped < offset {
+			skipped++
+		} else {
+			merged = reflect.Append(merged, results[minShard].Index(heads[minShard]))
+		}
+		heads[minShard]++
+	}
+	return merged, nil
+}
+
+// compareShardRows 按照排序字段比较两行数据,a在b前面返回负数
+// compareShardRows Compare two rows according to the order columns, return a negative number if a comes before b
+func compareShardRows(a reflect.Value, b reflect.Value, sortKeys []func(row reflect.Value) reflect.Value, keys []shardOrderKey) (int, error) {
+	for i, sortKey := range sortKeys {
+		cmp, err := compareShardValue(sortKey(a), sortKey(b))
+		if err != nil {
+			return 0, err
+		}
+		if cmp != 0 {
+			if keys[i].desc {
+				return -cmp, nil
+			}
+			return cmp, nil
+		}
+	}
+	return 0, nil
+}
+
+// compareShardValue 比较两个排序字段的值,NULL视为最小值.支持数字,字符串,bool,[]byte,time.Time,driver.Valuer和有Cmp方法的类型,例如decimal.Decimal
+// compareShardValue Compare the values of two order columns, NULL is regarded as the minimum value. Supports numbers, strings, bool, []byte,
+// time.Time, driver.Valuer and types with the Cmp method, such as decimal.Decimal
+func compareShardValue(a reflect.Value, b reflect.Value) (int, error) {
+	a, aNull := shardSortValue(a)
+	b, bNull := shardSortValue(b)
+	if aNull || bNull {
+		if aNull && bNull {
+			return 0, nil
+		} else if aNull {
+			return -1, nil
+		}
+		return 1, nil
+	}
+	if a.Type() != b.Type() {
+		return 0, errors.New("compareShardValue-->排序字段的类型不一致:" + a.Type().String() + "," + b.Type().String())
+	}
+	if cmpMethod := a.MethodByName("Cmp"); cmpMethod.IsValid() && cmpMethod.Type().NumIn() == 1 && cmpMethod.Type().In(0) == b.Type() &&
+		cmpMethod.Type().NumOut() == 1 && cmpMethod.Type().Out(0).Kind() == reflect.Int {
+		return int(cmpMethod.Call([]reflect.Value{b})[0].Int()), nil
+	}
+	if aTime, ok := a.Interface().(time.Time); ok {
+		bTime := b.Interface().(time.Time)
+		if aTime.Before(bTime) {
+			return -1, nil
+		} else if aTime.After(bTime) {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareOrdered(a.Int() < b.Int(), a.Int() > b.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return compareOrdered(a.Uint() < b.Uint(), a.Uint() > b.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return compareOrdered(a.Float() < b.Float(), a.Float() > b.Float()), nil
+	case reflect.String:
+		return strings.Compare(a.String(), b.String()), nil
+	case reflect.Bool:
+		return compareOrdered(!a.Bool() && b.Bool(), a.Bool() && !b.Bool()), nil
+	case reflect.Slice:
+		if a.Type().Elem().Kind() == reflect.Uint8 {
+			return bytes.Compare(a.Bytes(), b.Bytes()), nil
+		}
+	}
+	return 0, errors.New("compareShardValue-->不支持排序的类型:" + a.Type().String())
+}
+
+// compareOrdered 根据小于和大于的结果返回-1,1,0
+// compareOrdered Return -1, 1, 0 according to the result of less than and greater than
+func compareOrdered(less bool, greater bool) int {
+	if less {
+		return -1
+	} else if greater {
+		return 1
+	}
+	return 0
+}
+
+// shardSortValue 获取排序字段的值,解开指针,接口和driver.Valuer,返回值是否是NULL
+// shardSortValue Get the value of the order column, unwrap the pointer, interface and driver.Valuer, and return whether the value is NULL
+func shardSortValue(value reflect.Value) (reflect.Value, bool) {
+	for value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return value, true
+		}
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		return value, true
+	}
+	if _, isTime := value.Interface().(time.Time); isTime {
+		return value, false
+	}
+	if value.MethodByName("Cmp").IsValid() {
+		return value, false
+	}
+	if valuer, ok := value.Interface().(driver.Valuer); ok {
+		driverValue, err := valuer.Value()
+		if err != nil || driverValue == nil {
+			return value, true
+		}
+		return shardSortValue(reflect.ValueOf(driverValue))
+	}
+	return value, false
+}
+
+// scatterShards 并发执行每个分片的函数,返回第一个错误
+// scatterShards Execute the function of each shard concurrently and return the first error
+func scatterShards(shardCount int, fn func(i int) error) error {
+	errs := make([]error, shardCount)
+	var wg sync.WaitGroup
+	for i := 0; i < shardCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					errs[i] = fmt.Errorf("第%d个分片recover error: %v", i, r)
+				}
+			}()
+			errs[i] = fn(i)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package grm
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// shardDemo 分片测试的实体类
+// shardDemo The entity of the shard tests
+type shardDemo struct {
+	EntityStruct
+	ID   int    `column:"id"`
+	Name string `column:"name"`
+}
+
+func (entity *shardDemo) TableName() string {
+	return "t_shard_demo"
+}
+
+func TestParseShardOrderBy(t *testing.T) {
+	tests := []struct {
+		name    string
+		sqlStr  string
+		want    []shardOrderKey
+		wantErr bool
+	}{
+		{"no order by", "SELECT * FROM t", nil, false},
+		{"columns", "SELECT * FROM t ORDER BY t.Name DESC, id", []shardOrderKey{{"name", true}, {"id", false}}, false},
+		{"quoted column", "SELECT * FROM t ORDER BY `id` ASC", []shardOrderKey{{"id", false}}, false},
+		{"order by in subquery", "SELECT * FROM (SELECT * FROM t ORDER BY id) a WHERE (a.id>1)", nil, false},
+		{"expression", "SELECT * FROM t ORDER BY COALESCE(a,b)", nil, true},
+		{"nulls last", "SELECT * FROM t ORDER BY id DESC NULLS LAST", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseShardOrderBy(tt.sqlStr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) && !(len(got) == 0 && len(tt.want) == 0) {
+				t.Errorf("parseShardOrderBy() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeShardResults(t *testing.T) {
+	shards := [][]int{{1, 4, 7}, {2, 5}, {3, 6, 8}}
+	tests := []struct {
+		name   string
+		desc   bool
+		offset int
+		limit  int
+		want   []int
+	}{
+		{"all", false, 0, -1, []int{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"page", false, 2, 3, []int{3, 4, 5}},
+		{"last page", false, 6, 3, []int{7, 8}},
+		{"desc", true, 0, 3, []int{8, 7, 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make([]reflect.Value, len(shards))
+			for i, shard := range shards {
+				rows := make([]int, len(shard))
+				copy(rows, shard)
+				if tt.desc {
+					for l, r := 0, len(rows)-1; l < r; l, r = l+1, r-1 {
+						rows[l], rows[r] = rows[r], rows[l]
+					}
+				}
+				results[i] = reflect.ValueOf(rows)
+			}
+			keys := []shardOrderKey{{"id", tt.desc}}
+			sortKeys, err := shardSortKeys(reflect.TypeOf(0), keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			merged, err := mergeShardResults(results, reflect.TypeOf([]int{}), sortKeys, keys, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := merged.Interface().([]int); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeShardResults() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShardRouterQueryPage(t *testing.T) {
+	shardRows := [][][]driver.Value{
+		{{int64(1), "a"}, {int64(4), "d"}, {int64(5), "e"}},
+		{{int64(2), "b"}, {int64(3), "c"}, {int64(6), "f"}},
+	}
+	daos := make([]*DBDao, len(shardRows))
+	queries := make([]*fakeDB, len(shardRows))
+	for i := range shardRows {
+		rows := shardRows[i]
+		_, daos[i], queries[i] = newFakeDao(t, &DBConfig{Dialect: "mysql", DSN: t.Name() + strconv.Itoa(i)})
+		queries[i].queryRows = func(query string) ([]string, [][]driver.Value) {
+			if strings.Contains(query, "SELECT COUNT(*)") {
+				return []string{"count"}, [][]driver.Value{{int64(len(rows))}}
+			}
+			return []string{"id", "name"}, rows
+		}
+	}
+	router, err := NewShardRouter(daos, "id", HashModStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := NewPage()
+	page.PageNo = 2
+	page.PageSize = 2
+	list := make([]shardDemo, 0)
+	finder := NewSelectFinder("t_shard_demo").Append("ORDER BY id")
+	if err = router.Query(context.Background(), finder, &list, page); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int, 0)
+	for _, demo := range list {
+		ids = append(ids, demo.ID)
+	}
+	if !reflect.DeepEqual(ids, []int{3, 4}) {
+		t.Errorf("ids = %v, want [3 4]", ids)
+	}
+	if page.TotalCount != 6 {
+		t.Errorf("TotalCount = %d, want 6", page.TotalCount)
+	}
+	//每个分片查询前offset+limit条数据
+	//Each shard queries the first offset+limit rows
+	for _, db := range queries {
+		statements := db.take()
+		if want := "SELECT * FROM t_shard_demo ORDER BY id LIMIT 0,4"; statements[len(statements)-1] != want {
+			t.Errorf("statement = %q, want %q", statements[len(statements)-1], want)
+		}
+	}
+}
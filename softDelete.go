@@ -0,0 +1,270 @@
+package grm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 软删除字段支持的时间类型,软删除时设置为当前时间,恢复时设置为NULL
+// Time types supported by the soft delete column, set to the current time when soft deleted, and set to NULL when restored
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	timePtrType  = reflect.TypeOf(&time.Time{})
+	nullTimeType = reflect.TypeOf(sql.NullTime{})
+)
+
+// Restore 根据主键恢复软删除的对象,必须是IEntityStruct类型,并且有 grm:"softDelete" 的字段
+// ctx不能为nil,参照使用grm.Transaction方法传入ctx.也不要自己构建DBConnection
+// affected影响的行数,如果异常或者驱动不支持,返回-1
+// Restore Restore the soft deleted object according to the primary key, it must be of type IEntityStruct and have the column of grm:"softDelete"
+// ctx cannot be nil, refer to grm.Transaction method to pass in ctx. Don't build DB Connection yourself
+// The number of rows affected by "affected", if it is abnormal or the driver does not support it, return -1
+func Restore(ctx context.Context, entity IEntityStruct) (int, error) {
+	if err := checkWriteCtx(ctx); err != nil {
+		return -1, err
+	}
+	typeOf, err := checkEntityKind(entity)
+	if err != nil {
+		return -1, err
+	}
+	softDeleteField, hasSoftDelete, err := getGrmTagField(&typeOf, tagSoftDelete)
+	if err != nil {
+		return -1, errors.New("Restore-->getGrmTagField获取软删除字段错误 " + err.Error())
+	}
+	if !hasSoftDelete {
+		return -1, errors.New("Restore-->" + typeOf.String() + "没有 grm:\"softDelete\" 的字段")
+	}
+	return softDeleteStructFunc(ctx, entity, &softDeleteField, true)
+}
+
+// NewSoftDeleteSelectFinder 根据实体类初始化查询的Finder,排除已经软删除的数据,表名使用FuncTableNameResolver获取
+// NewSoftDeleteSelectFinder(ctx, &demo) SELECT * FROM t_demo WHERE deleted_at IS NULL
+// 后续的条件使用 AND 拼接,例如 finder.Append(" AND id=?", id).需要包含软删除的数据,使用NewSelectFinderByEntity并设置Finder.WithDeleted
+// NewSoftDeleteSelectFinder Initialize the query Finder according to the entity, excluding the soft deleted data, the table name is obtained by FuncTableNameResolver
+// Subsequent conditions are concatenated with AND, such as finder.Append(" AND id=?", id).
+// To include soft deleted data, use NewSelectFinderByEntity and set Finder.WithDeleted
+func NewSoftDeleteSelectFinder(ctx context.Context, entity IEntityStruct, strs ...string) (*Finder, error) {
+	notDeletedFinder, err := NewNotDeletedFinder(entity, "")
+	if err != nil {
+		return nil, err
+	}
+	finder, err := NewSelectFinderByEntity(ctx, entity, strs...)
+	if err != nil {
+		return nil, err
+	}
+	finder.Append(" WHERE")
+	return finder.AppendFinder(notDeletedFinder)
+}
+
+// NewNotDeletedFinder 返回排除软删除数据的条件,例如 deleted_at IS NULL ,用于关联查询等自己拼接的语句,使用finder.AppendFinder拼接
+// alias是表的别名,可以为空.拼接了这个条件的Finder,查询时不再默认排除软删除的数据
+// NewNotDeletedFinder Return the condition to exclude soft deleted data, such as deleted_at IS NULL,
+// used for self-spliced statements such as join queries, use finder.AppendFinder to splice. alias is the alias of the table, can be empty.
+// The Finder spliced with this condition no longer excludes soft deleted data by default when querying
+func NewNotDeletedFinder(entity IEntityStruct, alias string) (*Finder, error) {
+	typeOf, err := checkEntityKind(entity)
+	if err != nil {
+		return nil, err
+	}
+	finder, hasSoftDelete, err := notDeletedFinder(typeOf, alias)
+	if err != nil {
+		return nil, err
+	}
+	if !hasSoftDelete {
+		return nil, errors.New("NewNotDeletedFinder-->" + typeOf.String() + "没有 grm:\"softDelete\" 的字段")
+	}
+	return finder, nil
+}
+
+// notDeletedFinder 返回struct类型排除软删除数据的条件,没有 grm:"softDelete" 的字段时返回false
+// notDeletedFinder Return the condition of the struct type to exclude soft deleted data, return false when there is no grm:"softDelete" field
+func notDeletedFinder(typeOf reflect.Type, alias string) (*Finder, bool, error) {
+	softDeleteField, hasSoftDelete, err := getGrmTagField(&typeOf, tagSoftDelete)
+	if err != nil || !hasSoftDelete {
+		return nil, false, err
+	}
+	column := getFieldTagName(&softDeleteField)
+	if alias != "" {
+		column = alias + "." + column
+	}
+	finder := NewFinder()
+	finder.softDeletePredicate = true
+	if isSoftDeleteTime(softDeleteField.Type) {
+		finder.Append(" " + column + " IS NULL ")
+		return finder, true, nil
+	}
+	notDeleted, _, err := softDeleteValue(&softDeleteField, true, time.Time{})
+	if err != nil {
+		return nil, false, err
+	}
+	finder.Append(" "+column+"=? ", notDeleted)
+	return finder, true, nil
+}
+
+// softDeleteQueryFinder 查询有 grm:"softDelete" 字段的struct时,把NewSelectFinder的表替换为排除软删除数据的子查询,返回新的Finder
+// SELECT * FROM t_demo WHERE id=? 替换为 SELECT * FROM (SELECT * FROM t_demo WHERE deleted_at IS NULL ) t_demo WHERE id=?
+// Finder.WithDeleted为true,已经拼接了NewNotDeletedFinder的条件,或者不是NewSelectFinder的语句时,返回原Finder
+// softDeleteQueryFinder When querying a struct with a grm:"softDelete" field, replace the table of NewSelectFinder with a subquery excluding soft deleted data,
+// and return a new Finder. When Finder.WithDeleted is true, the condition of NewNotDeletedFinder has been spliced,
+// or it is not a statement of NewSelectFinder, return the original Finder
+func softDeleteQueryFinder(finder *Finder, typeOf reflect.Type) (*Finder, error) {
+	if finder == nil || finder.WithDeleted || finder.softDeletePredicate || finder.selectTable == "" || typeOf.Kind() != reflect.Struct {
+		return finder, nil
+	}
+	//表名后面可以有别名,例如 t_demo t 或者 t_demo AS t
+	//The table name can be followed by an alias, such as t_demo t or t_demo AS t
+	tables := strings.Fields(finder.selectTable)
+	alias := tables[len(tables)-1]
+	if len(tables) > 3 || (len(tables) == 3 && !strings.EqualFold(tables[1], "AS")) || strings.ContainsAny(finder.selectTable, "(,") {
+		return finder, nil
+	}
+	condition, hasSoftDelete, err := notDeletedFinder(typeOf, "")
+	if err != nil || !hasSoftDelete {
+		return finder, err
+	}
+	//GetSQL已经展开了数组参数时,使用展开后的语句.NewSelectFinder的表名之前没有参数,表名的位置不变
+	//When GetSQL has expanded the array parameters, use the expanded statement.
+	//There are no parameters before the table name of NewSelectFinder, and the position of the table name does not change
+	sqlStr := finder.sqlStr
+	if sqlStr == "" {
+		sqlStr = finder.sqlBuilder.String()
+	}
+	conditionSQL, err := condition.GetSQL()
+	if err != nil {
+		return nil, err
+	}
+	f := finder.clone()
+	f.sqlBuilder.Reset()
+	f.sqlBuilder.WriteString(sqlStr[:finder.selectTableIndex])
+	f.sqlBuilder.WriteString("(SELECT * FROM " + tables[0] + " WHERE " + strings.TrimSpace(conditionSQL) + ") " + alias)
+	f.sqlBuilder.WriteString(sqlStr[finder.selectTableIndex+len(finder.selectTable):])
+	f.values = append(condition.values, f.values...)
+	f.sqlStr = ""
+	f.selectTable = ""
+	f.softDeletePredicate = true
+	f.CountFinder, err = softDeleteQueryFinder(f.CountFinder, typeOf)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// softDeleteStructFunc 软删除或者恢复对象,更新软删除字段的值,执行成功后同步修改entity的字段值
+// ctx不能为nil,参照使用grm.Transaction方法传入ctx.也不要自己构建DBConnection
+// affected影响的行数,如果异常或者驱动不支持,返回-1
+// softDeleteStructFunc Soft delete or restore the object, update the value of the soft delete column,
+// and modify the field value of entity after successful execution
+// ctx cannot be nil, refer to grm.Transaction method to pass in ctx. Don't build DB Connection yourself
+// The number of rows affected by "affected", if it is abnormal or the driver does not support it, return -1
+func softDeleteStructFunc(ctx context.Context, entity IEntityStruct, softDeleteField *reflect.StructField, restore bool) (int, error) {
+	affected := -1
+	typeOf, err := checkEntityKind(entity)
+	if err != nil {
+		return affected, err
+	}
+	pkValues, err := entityPKValues(entity, &typeOf)
+	if err != nil {
+		return affected, errors.New("softDeleteStructFunc-->entityPKValues获取主键值错误 " + err.Error())
+	}
+
+	//从context中获取数据库连接,可能为nil
+	//Get database connection from context, may be nil
+	dbConn, err := getDBConn(ctx)
+	if err != nil {
+		return affected, err
+	}
+	//自己构建的dbConn
+	//dbConn built by yourself
+	if dbConn != nil && dbConn.db == nil {
+		return affected, errDBConn
+	}
+	dbValue, fieldValue, err := softDeleteValue(softDeleteField, restore, autoTimeNow(getDBConfig(ctx, dbConn, 1)))
+	if err != nil {
+		return affected, errors.New("softDeleteStructFunc-->softDeleteValue获取软删除字段的值错误 " + err.Error())
+	}
+
+	var config *DBConfig
+	//dbConn为nil,使用defaultDao
+	//dbConn is nil, use default Dao
+	if dbConn == nil {
+		config = FuncReadWriteStrategy(1).config
+	} else {
+		config = dbConn.cfg
+	}
+
+	tableName, err := FuncTableNameResolver(ctx, entity)
+	if err != nil {
+		return affected, errors.New("softDeleteStructFunc-->FuncTableNameResolver获取表名错误: " + err.Error())
+	}
+	tenant, err := structTenantCondition(ctx, dbConn, entity)
+	if err != nil {
+		return affected, errors.New("softDeleteStructFunc-->structTenantCondition获取租户条件错误: " + err.Error())
+	}
+	values := append([]interface{}{dbValue}, pkValues...)
+	sqlStr, err := wrapSoftDeleteSQL(config, tableName, entity, getFieldTagName(softDeleteField), &values, tenant)
+	if err != nil {
+		return affected, errors.New("softDeleteStructFunc-->wrapSoftDeleteSQL获取SQL语句错误: " + err.Error())
+	}
+
+	//包装update执行,赋值给影响的函数指针变量,返回*sql.Result
+	_, execErr := wrapExecUpdateValuesAffected(ctx, &affected, &sqlStr, values, nil)
+	if execErr != nil {
+		return affected, errors.New("softDeleteStructFunc-->wrapExecUpdateValuesAffected执行更新错误 " + execErr.Error())
+	}
+	reflect.ValueOf(entity).Elem().FieldByName(softDeleteField.Name).Set(fieldValue)
+	return affected, nil
+}
+
+// isSoftDeleteTime 软删除字段是否是时间类型,时间类型使用NULL表示没有删除,其他类型使用零值表示没有删除
+// isSoftDeleteTime Whether the soft delete column is a time type, time types use NULL to indicate not deleted, and other types use zero value
+func isSoftDeleteTime(fieldType reflect.Type) bool {
+	return fieldType == timeType || fieldType == timePtrType || fieldType == nullTimeType
+}
+
+// softDeleteValue 获取软删除字段更新到数据库的值和struct字段的值,now是软删除的时间,参见FuncNow
+// 时间类型软删除是当前时间,恢复是NULL.整数类型软删除是1,恢复是0.bool类型软删除是true,恢复是false
+// softDeleteValue Get the value of the soft delete column updated to the database and the value of the struct field, now is the time of soft delete, see FuncNow
+// Time types: soft delete is the current time, restore is NULL. Integer types: soft delete is 1, restore is 0. bool type: soft delete is true, restore is false
+func softDeleteValue(field *reflect.StructField, restore bool, now time.Time) (interface{}, reflect.Value, error) {
+	fieldType := field.Type
+	if restore {
+		fieldValue := reflect.Zero(fieldType)
+		if isSoftDeleteTime(fieldType) {
+			return nil, fieldValue, nil
+		}
+		if fieldType.Kind() == reflect.Bool || isIntKind(fieldType.Kind()) {
+			return fieldValue.Interface(), fieldValue, nil
+		}
+	} else {
+		switch {
+		case fieldType == timeType:
+			return now, reflect.ValueOf(now), nil
+		case fieldType == timePtrType:
+			return now, reflect.ValueOf(&now), nil
+		case fieldType == nullTimeType:
+			return now, reflect.ValueOf(sql.NullTime{Time: now, Valid: true}), nil
+		case fieldType.Kind() == reflect.Bool:
+			fieldValue := reflect.ValueOf(true).Convert(fieldType)
+			return fieldValue.Interface(), fieldValue, nil
+		case isIntKind(fieldType.Kind()):
+			fieldValue := reflect.ValueOf(1).Convert(fieldType)
+			return fieldValue.Interface(), fieldValue, nil
+		}
+	}
+	return nil, reflect.Value{}, errors.New("softDeleteValue-->软删除字段" + field.Name + "的类型" + fieldType.String() + "不支持,支持时间,整数和bool类型")
+}
+
+// isIntKind 是否是整数类型
+// isIntKind Whether it is an integer type
+func isIntKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package grm
+
+import (
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// softDemo 软删除测试的实体类,删除时间为NULL表示没有删除
+// softDemo The entity of the soft delete tests, a NULL delete time means not deleted
+type softDemo struct {
+	EntityStruct
+	ID        int        `column:"id"`
+	Name      string     `column:"name"`
+	DeletedAt *time.Time `column:"deleted_at" grm:"softDelete"`
+}
+
+func (entity *softDemo) TableName() string {
+	return "t_soft"
+}
+
+// softFlagDemo 使用整数标记软删除的实体类,0表示没有删除
+// softFlagDemo The entity that marks soft delete with an integer, 0 means not deleted
+type softFlagDemo struct {
+	EntityStruct
+	ID      int `column:"id"`
+	Deleted int `column:"deleted" grm:"softDelete"`
+}
+
+func (entity *softFlagDemo) TableName() string {
+	return "t_soft_flag"
+}
+
+func TestSoftDeleteRestore(t *testing.T) {
+	ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+	demo := &softDemo{ID: 1}
+	_, err := Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		return Delete(ctx, demo)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//软删除更新删除时间,不删除数据
+	//Soft delete updates the delete time and does not delete the data
+	statements := db.take()
+	if len(statements) != 3 || !strings.HasPrefix(strings.TrimSpace(statements[1]), "UPDATE t_soft SET deleted_at=?") {
+		t.Fatalf("statements = %q, want the soft delete update", statements)
+	}
+	if demo.DeletedAt == nil {
+		t.Error("软删除之后没有设置DeletedAt")
+	}
+
+	_, err = Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		return Restore(ctx, demo)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements = db.take()
+	if len(statements) != 3 || !strings.HasPrefix(strings.TrimSpace(statements[1]), "UPDATE t_soft SET deleted_at=?") {
+		t.Fatalf("statements = %q, want the restore update", statements)
+	}
+	if demo.DeletedAt != nil {
+		t.Errorf("DeletedAt = %v, want nil after restore", demo.DeletedAt)
+	}
+
+	//HardDelete物理删除
+	//HardDelete deletes physically
+	_, err = Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		return HardDelete(ctx, demo)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statements = db.take(); len(statements) != 3 || !strings.HasPrefix(strings.TrimSpace(statements[1]), "DELETE FROM t_soft") {
+		t.Errorf("statements = %q, want the delete", statements)
+	}
+	var restoreErr error
+	_, err = Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		_, restoreErr = Restore(ctx, &txDemo{ID: 1})
+		return nil, restoreErr
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restoreErr == nil {
+		t.Error("Restore没有检查软删除字段")
+	}
+}
+
+func TestSoftDeleteQueryFinder(t *testing.T) {
+	softType := reflect.TypeOf(softDemo{})
+	flagType := reflect.TypeOf(softFlagDemo{})
+	tests := []struct {
+		name       string
+		finder     func() *Finder
+		typeOf     reflect.Type
+		wantSQL    string
+		wantValues []interface{}
+	}{
+		{
+			name: "time",
+			finder: func() *Finder {
+				return NewSelectFinder("t_soft").Append("WHERE id=?", 1)
+			},
+			typeOf:     softType,
+			wantSQL:    "SELECT * FROM (SELECT * FROM t_soft WHERE deleted_at IS NULL) t_soft WHERE id=?",
+			wantValues: []interface{}{1},
+		},
+		{
+			name: "alias and array parameter",
+			finder: func() *Finder {
+				return NewSelectFinder("t_soft_flag AS t", "t.id").Append("WHERE t.id IN (?)", []int{1, 2})
+			},
+			typeOf:     flagType,
+			wantSQL:    "SELECT t.id FROM (SELECT * FROM t_soft_flag WHERE deleted=?) t WHERE t.id IN (?,?)",
+			wantValues: []interface{}{0, 1, 2},
+		},
+		{
+			name: "with deleted",
+			finder: func() *Finder {
+				finder := NewSelectFinder("t_soft")
+				finder.WithDeleted = true
+				return finder
+			},
+			typeOf:     softType,
+			wantSQL:    "SELECT * FROM t_soft",
+			wantValues: []interface{}{},
+		},
+		{
+			name: "not deleted finder",
+			finder: func() *Finder {
+				finder, _ := NewSoftDeleteSelectFinder(context.Background(), &softDemo{})
+				return finder
+			},
+			typeOf:     softType,
+			wantSQL:    "SELECT * FROM t_soft  WHERE  deleted_at IS NULL ",
+			wantValues: []interface{}{},
+		},
+		{
+			name: "no soft delete field",
+			finder: func() *Finder {
+				return NewSelectFinder("t_demo")
+			},
+			typeOf:     reflect.TypeOf(txDemo{}),
+			wantSQL:    "SELECT * FROM t_demo",
+			wantValues: []interface{}{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			finder, err := softDeleteQueryFinder(tt.finder(), tt.typeOf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sqlStr, err := finder.GetSQL()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sqlStr != tt.wantSQL {
+				t.Errorf("sql = %q, want %q", sqlStr, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(finder.values, tt.wantValues) {
+				t.Errorf("values = %v, want %v", finder.values, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestSoftDeleteQuery(t *testing.T) {
+	ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+	//Query和QueryRow默认排除软删除的数据,不修改传入的Finder
+	//Query and QueryRow exclude soft deleted data by default and do not modify the passed Finder
+	finder := NewSelectFinder("t_soft").Append("WHERE name=?", "grm")
+	if _, err := QueryRow(ctx, finder, &softDemo{}); err != nil {
+		t.Fatal(err)
+	}
+	list := make([]*softDemo, 0)
+	if err := Query(ctx, finder, &list, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "FROM (SELECT * FROM t_soft WHERE deleted_at IS NULL) t_soft WHERE name=?"
+	if sqlStr, _ := finder.GetSQL(); sqlStr != "SELECT * FROM t_soft WHERE name=?" {
+		t.Errorf("finder sql = %q", sqlStr)
+	}
+	//分页查询的总条数也排除软删除的数据
+	//The total count of paging queries also excludes soft deleted data
+	db.queryRows = func(query string) ([]string, [][]driver.Value) {
+		if strings.Contains(query, "COUNT(*)") {
+			return []string{"count"}, [][]driver.Value{{int64(0)}}
+		}
+		return nil, nil
+	}
+	if err := Query(ctx, finder, &list, NewPage()); err != nil {
+		t.Fatal(err)
+	}
+	statements := db.take()
+	if len(statements) != 4 {
+		t.Fatalf("statements = %q, want 4", statements)
+	}
+	for _, statement := range statements {
+		if !strings.Contains(statement, want) {
+			t.Errorf("statement = %q, want to contain %q", statement, want)
+		}
+	}
+
+	//WithDeleted包含软删除的数据
+	//WithDeleted includes soft deleted data
+	finder = NewSelectFinder("t_soft")
+	finder.WithDeleted = true
+	if err := Query(ctx, finder, &list, nil); err != nil {
+		t.Fatal(err)
+	}
+	assertStatements(t, db.take(), []string{"SELECT * FROM t_soft"})
+}
@@ -35,6 +35,19 @@ var allowBaseTypeMap = map[reflect.Kind]bool{
 const (
 	//default tag name
 	tagColumnName = "column"
+	//grm选项的tag,多个选项用逗号隔开,例如 grm:"softDelete"
+	//The tag of grm options, multiple options are separated by commas, such as grm:"softDelete"
+	tagGrmName = "grm"
+	//软删除的选项
+	//The option of soft delete
+	tagSoftDelete = "softdelete"
+	//乐观锁版本号的选项
+	//The option of optimistic lock version
+	tagVersion = "version"
+	//自动填充创建时间和更新时间的选项,可以指定精度,例如 grm:"autoCreateTime:milli"
+	//The options to auto-fill create time and update time, the precision can be specified, such as grm:"autoCreateTime:milli"
+	tagAutoCreateTime = "autocreatetime"
+	tagAutoUpdateTime = "autoupdatetime"
 
 	//输出字段 缓存的前缀
 	exportPrefix = "_exportStructFields_"
@@ -42,6 +55,8 @@ const (
 	privatePrefix = "_privateStructFields_"
 	//数据库列名 缓存的前缀
 	dbColumnNamePrefix = "_dbColumnName_"
+	//grm选项 缓存的前缀,key是选项名称的小写
+	grmTagPrefix = "_grmTag_"
 
 	//field对应的column的tag值 缓存的前缀
 	//structFieldTagPrefix = "_structFieldTag_"
@@ -73,6 +88,8 @@ func structFieldInfo(typeOf *reflect.Type) error {
 	privateCacheKey := privatePrefix + entityName
 	//所有数据库的属性,key是数据库的字段名称,不区分大小写
 	dbColumnCacheKey := dbColumnNamePrefix + entityName
+	//数据库字段的grm选项,key是选项名称,不区分大小写
+	grmTagCacheKey := grmTagPrefix + entityName
 	//structFieldTagCacheKey := structFieldTagPrefix + entityName
 	//dbPKNameCacheKey := dbPKNamePrefix + entityName
 	//缓存的数据库主键值
@@ -110,6 +127,7 @@ func structFieldInfo(typeOf *reflect.Type) error {
 	exportStructFieldMap := make(map[string]reflect.StructField)
 	privateStructFieldMap := make(map[string]reflect.StructField)
 	dbColumnFieldMap := make(map[string]reflect.StructField)
+	grmTagFieldMap := make(map[string]reflect.StructField)
 	//structFieldTagMap := make(map[string]string)
 
 	//遍历sync.Map,要求输入一个func作为参数
@@ -127,6 +145,16 @@ func structFieldInfo(typeOf *reflect.Type) error {
 				//dbColumnFieldMap[tagColumnValue] = field
 				//使用数据库字段的小写,处理oracle和达梦数据库的sql返回值大写
 				dbColumnFieldMap[strings.ToLower(tagColumnValue)] = field
+				//grm选项,例如 grm:"softDelete"
+				for _, option := range strings.Split(field.Tag.Get(tagGrmName), ",") {
+					option = strings.ToLower(strings.TrimSpace(option))
+					if i := strings.Index(option, ":"); i >= 0 {
+						option = option[:i]
+					}
+					if len(option) > 0 {
+						grmTagFieldMap[option] = field
+					}
+				}
 				//structFieldTagMap[fieldName] = tagColumnValue
 			}
 		} else { //私有属性
@@ -145,6 +173,7 @@ func structFieldInfo(typeOf *reflect.Type) error {
 	cacheStructFieldInfoMap[exportCacheKey] = exportStructFieldMap
 	cacheStructFieldInfoMap[privateCacheKey] = privateStructFieldMap
 	cacheStructFieldInfoMap[dbColumnCacheKey] = dbColumnFieldMap
+	cacheStructFieldInfoMap[grmTagCacheKey] = grmTagFieldMap
 	//cacheStructFieldTagInfoMap[structFieldTagCacheKey] = structFieldTagMap
 	return nil
 }
@@ -270,6 +299,16 @@ func getDBColumnFieldMap(typeOf *reflect.Type) (map[string]reflect.StructField,
 	return getCacheStructFieldInfoMap(typeOf, dbColumnNamePrefix)
 }
 
+//getGrmTagField 获取grm选项对应的数据库字段,例如 grm:"softDelete" 的字段.option是选项名称的小写
+func getGrmTagField(typeOf *reflect.Type, option string) (reflect.StructField, bool, error) {
+	grmTagFieldMap, err := getCacheStructFieldInfoMap(typeOf, grmTagPrefix)
+	if err != nil {
+		return reflect.StructField{}, false, err
+	}
+	field, has := grmTagFieldMap[option]
+	return field, has, nil
+}
+
 //getCacheStructFieldInfoMap 根据类型和key,获取缓存的字段信息
 func getCacheStructFieldInfoMap(typeOf *reflect.Type, keyPrefix string) (map[string]reflect.StructField, error) {
 	if typeOf == nil {
@@ -374,8 +413,11 @@ func columnAndValue(entity interface{}) (reflect.Type, []reflect.StructField, []
 	return typeOf, columns, values, nil
 }
 
-//entityPKFieldName 获取实体类主键属性名称
+//entityPKFieldName 获取实体类主键属性名称,联合主键返回"",参见entityPKColumns
 func entityPKFieldName(entity IEntityStruct, typeOf *reflect.Type) (string, error) {
+	if len(entityPKColumns(entity)) > 1 {
+		return "", nil
+	}
 
 	//检查是否是指针对象
 	//typeOf, checkErr := checkEntityKind(entity)
@@ -394,6 +436,39 @@ func entityPKFieldName(entity IEntityStruct, typeOf *reflect.Type) (string, erro
 	return field.Name, nil
 }
 
+//entityPKColumns 获取实体类主键的数据库字段名,实现了IEntityPKs返回联合主键,否则返回PK()
+func entityPKColumns(entity IEntityStruct) []string {
+	if entityPKs, ok := entity.(IEntityPKs); ok {
+		if pkColumns := entityPKs.PKs(); len(pkColumns) > 0 {
+			return pkColumns
+		}
+	}
+	return []string{entity.PK()}
+}
+
+//entityPKValues 获取实体类主键的值,顺序和entityPKColumns一致.联合主键的值都不能是零值
+func entityPKValues(entity IEntityStruct, typeOf *reflect.Type) ([]interface{}, error) {
+	pkColumns := entityPKColumns(entity)
+	dbMap, err := getDBColumnFieldMap(typeOf)
+	if err != nil {
+		return nil, err
+	}
+	valueOf := reflect.ValueOf(entity).Elem()
+	pkValues := make([]interface{}, 0, len(pkColumns))
+	for _, pkColumn := range pkColumns {
+		field, ok := dbMap[strings.ToLower(pkColumn)]
+		if !ok {
+			return nil, errors.New("entityPKValues-->主键" + pkColumn + "没有对应的struct属性")
+		}
+		fieldValue := valueOf.FieldByName(field.Name)
+		if len(pkColumns) > 1 && fieldValue.IsZero() {
+			return nil, errors.New("entityPKValues-->联合主键" + pkColumn + "的值不能是零值")
+		}
+		pkValues = append(pkValues, fieldValue.Interface())
+	}
+	return pkValues, nil
+}
+
 //checkEntityKind 检查entity类型必须是*struct类型或者基础类型的指针
 func checkEntityKind(entity interface{}) (reflect.Type, error) {
 	if entity == nil {
@@ -0,0 +1,210 @@
+package grm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// ctxTenantKey 绑定租户ID的key
+// ctxTenantKey The key to bind the tenant ID
+const ctxTenantKey = wrapCtxKey("ctxTenantKey")
+
+// ctxCrossTenantKey 绑定跨租户执行的key
+// ctxCrossTenantKey The key to bind cross-tenant execution
+const ctxCrossTenantKey = wrapCtxKey("ctxCrossTenantKey")
+
+// errNoTenant 开启多租户后没有绑定租户
+// errNoTenant No tenant is bound after multi-tenancy is enabled
+var errNoTenant = errors.New("多租户模式下ctx没有绑定租户,请使用grm.BindCtxTenant绑定租户,或者使用grm.BindCtxCrossTenant跨租户执行")
+
+// tenantCondition 多租户的条件,租户字段和租户ID
+// tenantCondition Multi-tenant condition, tenant column and tenant ID
+type tenantCondition struct {
+	column string
+	value  interface{}
+}
+
+// BindCtxTenant 绑定租户ID到ctx,DBConfig.TenantColumn不为空时开启多租户.parent不能为空,tenantID不能为nil
+// 包含租户字段的struct,Insert和InsertSlice会设置租户字段的值,Update和Delete会增加 AND 租户字段=? 的条件
+// IEntityMap的InsertEntityMap会设置租户字段的值,UpdateEntityMap会增加 AND 租户字段=? 的条件
+// Query,QueryRow,QueryMap和UpdateFinder的Finder必须使用Finder.AppendTenant增加租户条件,或者设置Finder.CrossTenant=true
+// 一般在请求开始时调用,例如web框架的中间件
+// BindCtxTenant Bind the tenant ID to ctx, multi-tenancy is enabled when DBConfig.TenantColumn is not empty. parent cannot be nil, tenantID cannot be nil
+// For structs that contain the tenant column, Insert and InsertSlice set the value of the tenant column, Update and Delete add the condition AND tenant column=?
+// InsertEntityMap of IEntityMap sets the value of the tenant column, UpdateEntityMap adds the condition AND tenant column=?
+// The Finder of Query, QueryRow, QueryMap and UpdateFinder must use Finder.AppendTenant to add the tenant condition, or set Finder.CrossTenant=true
+// Generally called at the beginning of the request, such as the middleware of the web framework
+func BindCtxTenant(parent context.Context, tenantID interface{}) (context.Context, error) {
+	if parent == nil {
+		return nil, errors.New("BindCtxTenant context的parent不能为nil")
+	}
+	if tenantID == nil {
+		return nil, errors.New("BindCtxTenant的tenantID不能为nil")
+	}
+	ctx := context.WithValue(parent, ctxTenantKey, tenantID)
+	return ctx, nil
+}
+
+// BindCtxCrossTenant 绑定跨租户执行到ctx,ctx(包括子context)不再注入和检查租户条件,用于后台任务和运营管理.parent不能为空
+// BindCtxCrossTenant Bind cross-tenant execution to ctx, ctx (including sub-contexts) no longer injects and checks tenant conditions,
+// used for background tasks and operation management. parent cannot be nil
+func BindCtxCrossTenant(parent context.Context) (context.Context, error) {
+	if parent == nil {
+		return nil, errors.New("BindCtxCrossTenant context的parent不能为nil")
+	}
+	ctx := context.WithValue(parent, ctxCrossTenantKey, true)
+	return ctx, nil
+}
+
+// isCrossTenant ctx是否是跨租户执行
+// isCrossTenant Whether ctx is cross-tenant execution
+func isCrossTenant(ctx context.Context) bool {
+	crossTenant, _ := ctx.Value(ctxCrossTenantKey).(bool)
+	return crossTenant
+}
+
+// getTenantColumn 获取多租户字段,没有开启多租户返回空字符串
+// getTenantColumn Get the tenant column, return an empty string if multi-tenancy is not enabled
+func getTenantColumn(ctx context.Context, dbConn *dbConnection, rwType int) string {
+	config := getDBConfig(ctx, dbConn, rwType)
+	if config == nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(config.TenantColumn))
+}
+
+// getTenantCondition 获取ctx的租户条件,跨租户执行返回nil,没有绑定租户返回错误
+// getTenantCondition Get the tenant condition of ctx, return nil for cross-tenant execution, and return an error if no tenant is bound
+func getTenantCondition(ctx context.Context, column string) (*tenantCondition, error) {
+	if isCrossTenant(ctx) {
+		return nil, nil
+	}
+	tenantID := ctx.Value(ctxTenantKey)
+	if tenantID == nil {
+		return nil, errNoTenant
+	}
+	return &tenantCondition{column: column, value: tenantID}, nil
+}
+
+// structTenantCondition 获取struct的租户条件,没有开启多租户或者struct没有租户字段返回nil
+// structTenantCondition Get the tenant condition of the struct, return nil if multi-tenancy is not enabled or the struct has no tenant column
+func structTenantCondition(ctx context.Context, dbConn *dbConnection, entity IEntityStruct) (*tenantCondition, error) {
+	column := getTenantColumn(ctx, dbConn, 1)
+	if column == "" {
+		return nil, nil
+	}
+	typeOf, err := checkEntityKind(entity)
+	if err != nil {
+		return nil, err
+	}
+	dbColumnFieldMap, err := getDBColumnFieldMap(&typeOf)
+	if err != nil {
+		return nil, err
+	}
+	if _, has := dbColumnFieldMap[column]; !has {
+		return nil, nil
+	}
+	return getTenantCondition(ctx, column)
+}
+
+// mapTenantCondition 获取IEntityMap的租户条件,没有开启多租户返回nil.FieldMap有租户字段时使用FieldMap中的key,没有时使用配置的租户字段
+// IEntityMap没有固定的字段,只要ctx绑定了租户,更新就增加租户条件,保存就设置租户字段的值
+// mapTenantCondition Get the tenant condition of IEntityMap, return nil if multi-tenancy is not enabled.
+// Use the key in FieldMap when FieldMap has the tenant column, otherwise use the configured tenant column.
+// IEntityMap has no fixed columns, as long as ctx is bound to a tenant, update adds the tenant condition and insert sets the value of the tenant column
+func mapTenantCondition(ctx context.Context, dbConn *dbConnection, entity IEntityMap) (*tenantCondition, error) {
+	column := getTenantColumn(ctx, dbConn, 1)
+	if column == "" {
+		return nil, nil
+	}
+	for k := range entity.FieldMap() {
+		if strings.ToLower(k) == column {
+			return getTenantCondition(ctx, k)
+		}
+	}
+	return getTenantCondition(ctx, column)
+}
+
+// setStructTenant 设置struct租户字段的值为ctx绑定的租户ID
+// setStructTenant Set the value of the struct tenant column to the tenant ID bound to ctx
+func setStructTenant(entity IEntityStruct, tenant *tenantCondition) error {
+	typeOf, err := checkEntityKind(entity)
+	if err != nil {
+		return err
+	}
+	dbColumnFieldMap, err := getDBColumnFieldMap(&typeOf)
+	if err != nil {
+		return err
+	}
+	field, has := dbColumnFieldMap[tenant.column]
+	if !has {
+		return nil
+	}
+	fieldValue := reflect.ValueOf(entity).Elem().FieldByName(field.Name)
+	tenantValue := reflect.ValueOf(tenant.value)
+	if !tenantValue.Type().ConvertibleTo(fieldValue.Type()) {
+		return errors.New("setStructTenant-->租户ID的类型" + tenantValue.Type().String() + "不能转换为字段" + field.Name + "的类型" + fieldValue.Type().String())
+	}
+	fieldValue.Set(tenantValue.Convert(fieldValue.Type()))
+	return nil
+}
+
+// checkTenantFinder 检查Finder是否使用Finder.AppendTenant增加了租户条件,没有开启多租户,跨租户执行或者Finder.CrossTenant=true时不检查
+// 不检查语句的内容,查询字段,注释和字符串里的租户字段都不能代替租户条件
+// checkTenantFinder Check whether the Finder has added the tenant condition using Finder.AppendTenant, not checked if multi-tenancy is not enabled,
+// cross-tenant execution or Finder.CrossTenant=true. The content of the statement is not checked,
+// the tenant column in the select list, comments and strings cannot replace the tenant condition
+func checkTenantFinder(ctx context.Context, dbConn *dbConnection, rwType int, finder *Finder) error {
+	if finder.CrossTenant || finder.tenantPredicate || isCrossTenant(ctx) {
+		return nil
+	}
+	column := getTenantColumn(ctx, dbConn, rwType)
+	if column == "" {
+		return nil
+	}
+	sqlStr, err := finder.GetSQL()
+	if err != nil {
+		return err
+	}
+	return errors.New("多租户模式下Finder没有使用AppendTenant增加租户条件" + column + ",跨租户的语句请设置Finder.CrossTenant=true: " + sqlStr)
+}
+
+// AppendTenant 拼接租户条件 alias.租户字段=? ,参数值是ctx绑定的租户ID,需要自己拼接 WHERE 或者 AND ,例如:
+// finder.Append(" WHERE")
+// finder.AppendTenant(ctx, "t")
+// finder.Append(" AND t.status=?", 1)
+// 开启多租户时,Query,QueryRow,QueryMap和UpdateFinder的Finder必须使用AppendTenant.alias是表的别名,可以为空
+// 没有开启多租户或者跨租户执行时拼接 1=1 ,保持语句正确.ctx没有绑定租户返回错误
+// AppendTenant Append the tenant condition alias.tenant column=?, the parameter value is the tenant ID bound to ctx,
+// WHERE or AND needs to be spliced by yourself, see the example above.
+// When multi-tenancy is enabled, the Finder of Query, QueryRow, QueryMap and UpdateFinder must use AppendTenant. alias is the alias of the table, can be empty.
+// Append 1=1 to keep the statement correct when multi-tenancy is not enabled or cross-tenant execution. Return an error if ctx is not bound to a tenant
+func (finder *Finder) AppendTenant(ctx context.Context, alias string) (*Finder, error) {
+	//不要自己构建finder,使用Newxxx方法
+	//Don't build finder by yourself, use Newxxx method
+	if finder.values == nil {
+		return nil, errors.New("finder-->AppendTenant不要自己构建finder,使用Newxxx方法")
+	}
+	dbConn, err := getDBConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	column := getTenantColumn(ctx, dbConn, 0)
+	if column == "" || isCrossTenant(ctx) {
+		finder.Append(" 1=1 ")
+		return finder, nil
+	}
+	tenant, err := getTenantCondition(ctx, column)
+	if err != nil {
+		return nil, err
+	}
+	column = identifierQuoter(getDBConfig(ctx, dbConn, 0))(column)
+	if alias != "" {
+		column = alias + "." + column
+	}
+	finder.Append(" "+column+"=? ", tenant.value)
+	finder.tenantPredicate = true
+	return finder, nil
+}
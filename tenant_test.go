@@ -0,0 +1,159 @@
+package grm
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCheckTenantFinder(t *testing.T) {
+	tests := []struct {
+		name    string
+		finder  func(ctx context.Context) *Finder
+		cross   bool
+		wantErr bool
+	}{
+		{
+			name: "AppendTenant",
+			finder: func(ctx context.Context) *Finder {
+				finder, _ := NewSelectFinder("t_demo").Append("WHERE").AppendTenant(ctx, "")
+				return finder
+			},
+		},
+		{
+			name: "tenant column in select list",
+			finder: func(ctx context.Context) *Finder {
+				return NewSelectFinder("t_demo", "id,tenant_id")
+			},
+			wantErr: true,
+		},
+		{
+			name: "tenant column in comment",
+			finder: func(ctx context.Context) *Finder {
+				return NewSelectFinder("t_demo").Append("/* tenant_id=1 */ WHERE id=?", 1)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Finder.CrossTenant",
+			finder: func(ctx context.Context) *Finder {
+				finder := NewSelectFinder("t_demo")
+				finder.CrossTenant = true
+				return finder
+			},
+		},
+		{
+			name: "BindCtxCrossTenant",
+			finder: func(ctx context.Context) *Finder {
+				return NewSelectFinder("t_demo")
+			},
+			cross: true,
+		},
+		{
+			name: "UNION ALL with a table without tenant",
+			finder: func(ctx context.Context) *Finder {
+				finder, _ := NewUnionAllFinder([]string{"t_demo_1", "t_demo_2"}, func(tableName string) (*Finder, error) {
+					if tableName == "t_demo_2" {
+						return NewSelectFinder(tableName), nil
+					}
+					return NewSelectFinder(tableName).Append("WHERE").AppendTenant(ctx, "")
+				})
+				return finder
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _, _ := newFakeDao(t, &DBConfig{Dialect: "mysql", TenantColumn: "tenant_id"})
+			ctx, _ = BindCtxTenant(ctx, 7)
+			if tt.cross {
+				ctx, _ = BindCtxCrossTenant(ctx)
+			}
+			dbConn, _ := getDBConn(ctx)
+			err := checkTenantFinder(ctx, dbConn, 0, tt.finder(ctx))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAppendTenant(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     DBConfig
+		cross      bool
+		alias      string
+		want       string
+		wantValues int
+	}{
+		{"tenant", DBConfig{Dialect: "mysql", TenantColumn: "tenant_id"}, false, "", "SELECT * FROM t_demo WHERE  tenant_id=? ", 1},
+		{"alias and quote", DBConfig{Dialect: "mysql", TenantColumn: "tenant_id", QuoteIdentifier: true}, false, "t", "SELECT * FROM t_demo WHERE  t.`tenant_id`=? ", 1},
+		{"cross tenant", DBConfig{Dialect: "mysql", TenantColumn: "tenant_id"}, true, "", "SELECT * FROM t_demo WHERE  1=1 ", 0},
+		{"not multi-tenant", DBConfig{Dialect: "mysql"}, false, "", "SELECT * FROM t_demo WHERE  1=1 ", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			ctx, _, _ := newFakeDao(t, &config)
+			ctx, _ = BindCtxTenant(ctx, 7)
+			if tt.cross {
+				ctx, _ = BindCtxCrossTenant(ctx)
+			}
+			finder, err := NewSelectFinder("t_demo").Append("WHERE").AppendTenant(ctx, tt.alias)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sqlStr, _ := finder.GetSQL()
+			if sqlStr != tt.want || len(finder.values) != tt.wantValues {
+				t.Errorf("AppendTenant() = %q %v, want %q", sqlStr, finder.values, tt.want)
+			}
+		})
+	}
+}
+
+func TestAppendTenantWithoutTenant(t *testing.T) {
+	ctx, _, _ := newFakeDao(t, &DBConfig{Dialect: "mysql", TenantColumn: "tenant_id"})
+	if _, err := NewSelectFinder("t_demo").Append("WHERE").AppendTenant(ctx, ""); err != errNoTenant {
+		t.Errorf("err = %v, want errNoTenant", err)
+	}
+}
+
+func TestEntityMapTenant(t *testing.T) {
+	ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql", TenantColumn: "tenant_id"})
+	ctx, _ = BindCtxTenant(ctx, 7)
+	_, err := Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		//FieldMap没有租户字段,保存时设置租户字段
+		//FieldMap has no tenant column, the tenant column is set when inserting
+		entity := NewEntityMap("t_demo")
+		entity.Set("id", 1)
+		entity.Set("name", "grm")
+		if _, err := InsertEntityMap(ctx, entity); err != nil {
+			return nil, err
+		}
+		if entity.FieldMap()["tenant_id"] != 7 {
+			t.Errorf("tenant_id = %v, want 7", entity.FieldMap()["tenant_id"])
+		}
+
+		//FieldMap没有租户字段,更新时增加租户条件
+		//FieldMap has no tenant column, the tenant condition is added when updating
+		entity = NewEntityMap("t_demo")
+		entity.Set("id", 1)
+		entity.Set("name", "grm")
+		return UpdateEntityMap(ctx, entity)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	statements := db.take()
+	if len(statements) != 4 {
+		t.Fatalf("statements = %q", statements)
+	}
+	if !strings.Contains(statements[1], "tenant_id") {
+		t.Errorf("insert = %q, want tenant_id", statements[1])
+	}
+	if !strings.HasSuffix(statements[2], " AND tenant_id=?") {
+		t.Errorf("update = %q, want the tenant condition", statements[2])
+	}
+}
@@ -0,0 +1,413 @@
+package grm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// errTestBusiness 测试的业务错误
+// errTestBusiness The business error of the tests
+var errTestBusiness = errors.New("业务错误")
+
+// txDemo 事务测试的实体类
+// txDemo The entity of the transaction tests
+type txDemo struct {
+	EntityStruct
+	ID         int       `column:"id"`
+	Name       string    `column:"name"`
+	Version    int       `column:"version" grm:"version"`
+	UpdateTime time.Time `column:"update_time" grm:"autoUpdateTime"`
+}
+
+func (entity *txDemo) TableName() string {
+	return "t_demo"
+}
+
+func TestTransactionSavepoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		inner   func(ctx context.Context) (interface{}, error)
+		wantErr bool
+		want    []string
+	}{
+		{
+			name: "nested commit",
+			inner: func(ctx context.Context) (interface{}, error) {
+				return nil, nil
+			},
+			want: []string{"BEGIN", "SAVEPOINT grm_sp_1", "RELEASE SAVEPOINT grm_sp_1", "COMMIT"},
+		},
+		{
+			name: "nested error only rolls back the savepoint",
+			inner: func(ctx context.Context) (interface{}, error) {
+				return nil, errTestBusiness
+			},
+			want: []string{"BEGIN", "SAVEPOINT grm_sp_1", "ROLLBACK TO SAVEPOINT grm_sp_1", "COMMIT"},
+		},
+		{
+			name: "nested panic rolls back the opener",
+			inner: func(ctx context.Context) (interface{}, error) {
+				panic("nested panic")
+			},
+			wantErr: true,
+			want:    []string{"BEGIN", "SAVEPOINT grm_sp_1", "ROLLBACK TO SAVEPOINT grm_sp_1", "ROLLBACK"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+			_, err := Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+				//外层忽略嵌套事务的错误
+				//The outer layer ignores the error of the nested transaction
+				Transaction(ctx, tt.inner)
+				return nil, nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			assertStatements(t, db.take(), tt.want)
+		})
+	}
+}
+
+func TestTransactionPanic(t *testing.T) {
+	ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+	calls := 0
+	_, err := TransactionWithOptions(ctx, &TransactionOption{Retry: &TxRetryPolicy{MaxAttempts: 3}}, func(ctx context.Context) (interface{}, error) {
+		calls++
+		panic(errors.New("Error 1213: Deadlock found when trying to get lock"))
+	})
+	if err == nil {
+		t.Fatal("panic的事务返回了nil")
+	}
+	if _, ok := err.(*txPanicError); !ok {
+		t.Errorf("err = %T, want *txPanicError", err)
+	}
+	//panic不重试,即使错误信息是死锁
+	//The panic is not retried, even if the message is a deadlock
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	assertStatements(t, db.take(), []string{"BEGIN", "ROLLBACK"})
+}
+
+func TestTransactionCallbacks(t *testing.T) {
+	tests := []struct {
+		name     string
+		innerErr error
+		outerErr error
+		want     []string
+	}{
+		{"commit", nil, nil, []string{"outer commit", "inner commit"}},
+		{"nested rolled back to savepoint", errTestBusiness, nil, []string{"outer commit"}},
+		{"opener rolls back", nil, errTestBusiness, []string{"outer rollback", "inner rollback"}},
+		{"nested and opener roll back", errTestBusiness, errTestBusiness, []string{"outer rollback", "inner rollback"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _, _ := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+			called := make([]string, 0)
+			register := func(ctx context.Context, name string) {
+				OnCommit(ctx, func(ctx context.Context) { called = append(called, name+" commit") })
+				OnRollback(ctx, func(ctx context.Context) { called = append(called, name+" rollback") })
+			}
+			Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+				register(ctx, "outer")
+				Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+					register(ctx, "inner")
+					return nil, tt.innerErr
+				})
+				return nil, tt.outerErr
+			})
+			if len(called) != len(tt.want) {
+				t.Fatalf("called = %q, want %q", called, tt.want)
+			}
+			for i := range called {
+				if called[i] != tt.want[i] {
+					t.Fatalf("called = %q, want %q", called, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestTransactionReadOnly(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(ctx context.Context, demo *txDemo) (int, error)
+	}{
+		{"Insert", func(ctx context.Context, demo *txDemo) (int, error) { return Insert(ctx, demo) }},
+		{"Update", func(ctx context.Context, demo *txDemo) (int, error) { return Update(ctx, demo) }},
+		{"UpdateNotZeroValue", func(ctx context.Context, demo *txDemo) (int, error) { return UpdateNotZeroValue(ctx, demo) }},
+		{"Delete", func(ctx context.Context, demo *txDemo) (int, error) { return Delete(ctx, demo) }},
+		{"UpdateFinder", func(ctx context.Context, demo *txDemo) (int, error) {
+			return UpdateFinder(ctx, NewUpdateFinder("t_demo").Append("name=? WHERE id=?", demo.Name, demo.ID))
+		}},
+		{"InsertSlice", func(ctx context.Context, demo *txDemo) (int, error) { return InsertSlice(ctx, []IEntityStruct{demo}) }},
+		{"HardDelete", func(ctx context.Context, demo *txDemo) (int, error) { return HardDelete(ctx, demo) }},
+		{"Restore", func(ctx context.Context, demo *txDemo) (int, error) { return Restore(ctx, demo) }},
+		{"Upsert", func(ctx context.Context, demo *txDemo) (int, error) { return Upsert(ctx, demo, []string{"id"}, nil) }},
+		{"InsertEntityMap", func(ctx context.Context, demo *txDemo) (int, error) {
+			entityMap := NewEntityMap("t_demo")
+			entityMap.Set("name", demo.Name)
+			return InsertEntityMap(ctx, entityMap)
+		}},
+		{"UpdateEntityMap", func(ctx context.Context, demo *txDemo) (int, error) {
+			entityMap := NewEntityMap("t_demo")
+			entityMap.Set("id", demo.ID)
+			entityMap.Set("name", demo.Name)
+			return UpdateEntityMap(ctx, entityMap)
+		}},
+		{"UpsertEntityMap", func(ctx context.Context, demo *txDemo) (int, error) {
+			entityMap := NewEntityMap("t_demo")
+			entityMap.Set("id", demo.ID)
+			return UpsertEntityMap(ctx, entityMap, []string{"id"}, nil)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+			demo := &txDemo{ID: 1, Name: "grm", Version: 1}
+			var writeErr error
+			_, err := TransactionReadOnly(ctx, func(ctx context.Context) (interface{}, error) {
+				_, writeErr = tt.write(ctx, demo)
+				return nil, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if writeErr != errReadOnlyTx {
+				t.Errorf("err = %v, want errReadOnlyTx", writeErr)
+			}
+			//拒绝写入之前没有修改实体
+			//The entity is not changed before the write is rejected
+			if demo.Version != 1 || !demo.UpdateTime.IsZero() {
+				t.Errorf("entity changed: %+v", demo)
+			}
+			assertStatements(t, db.take(), []string{"BEGIN READ ONLY", "COMMIT"})
+		})
+	}
+}
+
+func TestUpdateVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		affected    int64
+		wantErr     error
+		wantVersion int
+	}{
+		{"updated", 1, nil, 2},
+		{"stale", 0, ErrStaleEntity, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+			db.affected = func(query string) int64 {
+				return tt.affected
+			}
+			demo := &txDemo{ID: 1, Name: "grm", Version: 1}
+			_, err := Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+				return Update(ctx, demo)
+			})
+			//乐观锁失败返回ErrStaleEntity本身
+			//Return ErrStaleEntity itself when the optimistic lock fails
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if demo.Version != tt.wantVersion {
+				t.Errorf("Version = %d, want %d", demo.Version, tt.wantVersion)
+			}
+			statements := db.take()
+			if len(statements) != 3 || !strings.Contains(statements[1], "version=version+1") || !strings.HasSuffix(statements[1], " WHERE id=? AND version=?") {
+				t.Errorf("statements = %q", statements)
+			}
+		})
+	}
+}
+
+func TestTransactionPropagation(t *testing.T) {
+	update := "UPDATE t_demo SET  name=? WHERE id=?"
+	tests := []struct {
+		name         string
+		outer        func(ctx context.Context, doTransaction func(ctx context.Context) (interface{}, error)) (interface{}, error)
+		propagation  Propagation
+		outerErr     error
+		wantErr      bool
+		wantWriteErr error
+		want         []string
+	}{
+		{
+			name:        "Required joins with a savepoint",
+			outer:       Transaction,
+			propagation: PropagationRequired,
+			want:        []string{"BEGIN", "SAVEPOINT grm_sp_1", update, "RELEASE SAVEPOINT grm_sp_1", "COMMIT"},
+		},
+		{
+			name:        "RequiresNew commits when the outer rolls back",
+			outer:       Transaction,
+			propagation: PropagationRequiresNew,
+			outerErr:    errTestBusiness,
+			want:        []string{"BEGIN", "BEGIN", update, "COMMIT", "ROLLBACK"},
+		},
+		{
+			name:         "RequiresNew in a read-only transaction is read-only",
+			outer:        TransactionReadOnly,
+			propagation:  PropagationRequiresNew,
+			wantWriteErr: errReadOnlyTx,
+			want:         []string{"BEGIN READ ONLY", "BEGIN READ ONLY", "ROLLBACK", "COMMIT"},
+		},
+		{
+			name:         "NotSupported runs without the transaction",
+			outer:        Transaction,
+			propagation:  PropagationNotSupported,
+			wantErr:      true,
+			wantWriteErr: errDBConn,
+			want:         []string{"BEGIN", "COMMIT"},
+		},
+		{
+			name:        "Mandatory joins with a savepoint",
+			outer:       Transaction,
+			propagation: PropagationMandatory,
+			want:        []string{"BEGIN", "SAVEPOINT grm_sp_1", update, "RELEASE SAVEPOINT grm_sp_1", "COMMIT"},
+		},
+		{
+			name:        "Mandatory without transaction",
+			propagation: PropagationMandatory,
+			wantErr:     true,
+		},
+		{
+			name:        "Never in a transaction",
+			outer:       Transaction,
+			propagation: PropagationNever,
+			wantErr:     true,
+			want:        []string{"BEGIN", "COMMIT"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+			var innerErr, writeErr error
+			inner := func(ctx context.Context) (interface{}, error) {
+				_, innerErr = TransactionWithOptions(ctx, &TransactionOption{Propagation: tt.propagation}, func(ctx context.Context) (interface{}, error) {
+					_, writeErr = UpdateFinder(ctx, NewUpdateFinder("t_demo").Append("name=? WHERE id=?", "grm", 1))
+					return nil, writeErr
+				})
+				return nil, tt.outerErr
+			}
+			if tt.outer == nil {
+				inner(ctx)
+			} else {
+				tt.outer(ctx, inner)
+			}
+			if (innerErr != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", innerErr, tt.wantErr)
+			}
+			if writeErr != tt.wantWriteErr {
+				t.Errorf("write err = %v, want %v", writeErr, tt.wantWriteErr)
+			}
+			assertStatements(t, db.take(), tt.want)
+		})
+	}
+}
+
+func TestReadYourWrites(t *testing.T) {
+	tests := []struct {
+		name string
+		tx   func(ctx context.Context, write func(ctx context.Context)) error
+		want bool
+	}{
+		{
+			name: "commit",
+			tx: func(ctx context.Context, write func(ctx context.Context)) error {
+				_, err := Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+					write(ctx)
+					return nil, nil
+				})
+				return err
+			},
+			want: true,
+		},
+		{
+			name: "rollback",
+			tx: func(ctx context.Context, write func(ctx context.Context)) error {
+				Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+					write(ctx)
+					return nil, errTestBusiness
+				})
+				return nil
+			},
+			want: false,
+		},
+		{
+			name: "nested rollback to savepoint",
+			tx: func(ctx context.Context, write func(ctx context.Context)) error {
+				_, err := Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+					Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+						write(ctx)
+						return nil, errTestBusiness
+					})
+					return nil, nil
+				})
+				return err
+			},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, dao, _ := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+			oldFunc := FuncReadWriteStrategyCtx
+			defer func() {
+				FuncReadWriteStrategyCtx = oldFunc
+			}()
+			rwType := -1
+			FuncReadWriteStrategyCtx = func(ctx context.Context, rw int) *DBDao {
+				rwType = rw
+				return dao
+			}
+			ctx, err := BindCtxReadYourWrites(ctx, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ryw := ctx.Value(ctxReadYourWritesKey).(*readYourWrites)
+			var lastWriteInTx int64
+			var beforeCommit time.Time
+			err = tt.tx(ctx, func(ctx context.Context) {
+				if _, writeErr := UpdateFinder(ctx, NewUpdateFinder("t_demo").Append("name=? WHERE id=?", "grm", 1)); writeErr != nil {
+					t.Fatal(writeErr)
+				}
+				//事务内不记录,长事务提交时从提交的时间开始计算
+				//Not recorded in the transaction, when a long transaction commits, the window starts from the commit time
+				lastWriteInTx = atomic.LoadInt64(&ryw.lastWrite)
+				beforeCommit = time.Now()
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if lastWriteInTx != 0 {
+				t.Errorf("lastWrite = %d in the transaction, want 0", lastWriteInTx)
+			}
+			lastWrite := atomic.LoadInt64(&ryw.lastWrite)
+			if tt.want && lastWrite < beforeCommit.UnixNano() {
+				t.Errorf("lastWrite = %d, want after %d", lastWrite, beforeCommit.UnixNano())
+			}
+			if !tt.want && lastWrite != 0 {
+				t.Errorf("lastWrite = %d, want 0 after the rollback", lastWrite)
+			}
+			//时间窗口内的查询使用主库
+			//Queries within the window use the primary
+			getReadWriteDao(ctx, 0)
+			wantRWType := 0
+			if tt.want {
+				wantRWType = 1
+			}
+			if rwType != wantRWType {
+				t.Errorf("rwType = %d, want %d", rwType, wantRWType)
+			}
+		})
+	}
+}
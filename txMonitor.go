@@ -0,0 +1,179 @@
+package grm
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TxInfo 正在执行的事务的信息,用于排查长事务和没有结束的事务
+// TxInfo Information of the executing transaction, used to troubleshoot long transactions and unfinished transactions
+type TxInfo struct {
+	// ID 事务的序号
+	// ID The sequence number of the transaction
+	ID uint64
+	// Driver 数据库类型
+	// Driver Database type
+	Driver string
+	// XID XA分支事务的xid,本地事务为空
+	// XID The xid of the XA branch transaction, empty for local transactions
+	XID string
+	// StartTime 事务开启的时间
+	// StartTime The time when the transaction was started
+	StartTime time.Time
+	// Deadline 开启事务时ctx的截止时间,没有截止时间为零值
+	// Deadline The deadline of ctx when the transaction was started, zero value if there is no deadline
+	Deadline time.Time
+	// Stack 开启事务时的调用栈
+	// Stack The call stack when the transaction was started
+	Stack string
+}
+
+// txRecord 登记的事务
+// txRecord Registered transaction
+type txRecord struct {
+	info TxInfo
+	pcs  []uintptr
+	// 是否已经报告过长事务,每个事务只报告一次
+	// Whether the long transaction has been reported, each transaction is only reported once
+	reported bool
+}
+
+var (
+	txSeq     uint64
+	txLock    sync.Mutex
+	txRecords = make(map[*dbConnection]*txRecord)
+)
+
+// FuncLongTransaction 报告长事务的函数,默认使用LogErr记录日志,方便自定义扩展,例如上报监控
+// FuncLongTransaction The function to report long transactions, LogErr is used by default, convenient for custom extension, such as reporting to monitoring
+var FuncLongTransaction func(info TxInfo, duration time.Duration) = logLongTransaction
+
+// logLongTransaction 使用LogErr记录长事务
+// logLongTransaction Use LogErr to record long transactions
+func logLongTransaction(info TxInfo, duration time.Duration) {
+	LogErr("长事务,事务" + strconv.FormatUint(info.ID, 10) + "已经执行" + duration.String() + ",开启事务的调用栈:\n" + info.Stack)
+}
+
+// registerTx 登记开启的事务,在开启事务成功后调用
+// registerTx Register the opened transaction, called after the transaction is opened successfully
+func registerTx(ctx context.Context, dbConn *dbConnection) {
+	record := &txRecord{}
+	record.info.ID = atomic.AddUint64(&txSeq, 1)
+	record.info.Driver = dbConn.cfg.Driver
+	record.info.XID = dbConn.xid
+	record.info.StartTime = time.Now()
+	if deadline, ok := ctx.Deadline(); ok {
+		record.info.Deadline = deadline
+	}
+	//只记录调用栈的指针,查询时再解析,减少开启事务的开销
+	//Only record the pointers of the call stack and parse it when querying, reducing the overhead of opening the transaction
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	record.pcs = pcs[:n]
+
+	txLock.Lock()
+	txRecords[dbConn] = record
+	txLock.Unlock()
+}
+
+// unregisterTx 移除结束的事务,在提交或者回滚后调用
+// unregisterTx Remove the finished transaction, called after commit or rollback
+func unregisterTx(dbConn *dbConnection) {
+	txLock.Lock()
+	delete(txRecords, dbConn)
+	txLock.Unlock()
+}
+
+// OpenTransactions 返回当前正在执行的事务,按照开启时间排序
+// OpenTransactions Return the currently executing transactions, sorted by start time
+func OpenTransactions() []TxInfo {
+	txLock.Lock()
+	records := make([]*txRecord, 0, len(txRecords))
+	for _, record := range txRecords {
+		records = append(records, record)
+	}
+	txLock.Unlock()
+
+	infos := make([]TxInfo, 0, len(records))
+	for _, record := range records {
+		info := record.info
+		info.Stack = formatTxStack(record.pcs)
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartTime.Before(infos[j].StartTime)
+	})
+	return infos
+}
+
+// formatTxStack 解析调用栈
+// formatTxStack Parse the call stack
+func formatTxStack(pcs []uintptr) string {
+	var builder strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		builder.WriteString(frame.Function)
+		builder.WriteString("\n\t")
+		builder.WriteString(frame.File)
+		builder.WriteString(":")
+		builder.WriteString(strconv.Itoa(frame.Line))
+		builder.WriteString("\n")
+		if !more {
+			break
+		}
+	}
+	return builder.String()
+}
+
+// StartTxWatchdog 启动长事务的检查,每隔interval检查一次,执行时间超过threshold的事务使用FuncLongTransaction报告,每个事务只报告一次
+// ctx结束时停止检查.例如在闭包里调用http接口,长时间持有行锁,可以尽早发现
+// StartTxWatchdog Start checking long transactions, check every interval, and transactions whose execution time exceeds threshold are reported by FuncLongTransaction,
+// each transaction is only reported once. Stop checking when ctx is done. For example, calling the http interface in the closure and holding the row lock for a long time can be found as soon as possible
+func StartTxWatchdog(ctx context.Context, threshold time.Duration, interval time.Duration) {
+	if interval <= 0 {
+		interval = threshold
+	}
+	if interval <= 0 {
+		interval = time.Second
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				checkLongTransactions(threshold)
+			}
+		}
+	}()
+}
+
+// checkLongTransactions 检查并报告执行时间超过threshold的事务
+// checkLongTransactions Check and report transactions whose execution time exceeds threshold
+func checkLongTransactions(threshold time.Duration) {
+	now := time.Now()
+	longTxs := make([]*txRecord, 0)
+	txLock.Lock()
+	for _, record := range txRecords {
+		if !record.reported && now.Sub(record.info.StartTime) > threshold {
+			record.reported = true
+			longTxs = append(longTxs, record)
+		}
+	}
+	txLock.Unlock()
+
+	for _, record := range longTxs {
+		info := record.info
+		info.Stack = formatTxStack(record.pcs)
+		FuncLongTransaction(info, now.Sub(info.StartTime))
+	}
+}
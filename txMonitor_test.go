@@ -0,0 +1,58 @@
+package grm
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTxMonitor(t *testing.T) {
+	ctx, _, _ := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+	oldFunc := FuncLongTransaction
+	defer func() {
+		FuncLongTransaction = oldFunc
+	}()
+	reported := make([]TxInfo, 0)
+	FuncLongTransaction = func(info TxInfo, duration time.Duration) {
+		reported = append(reported, info)
+	}
+
+	deadline := time.Now().Add(time.Hour)
+	txCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+	var txID uint64
+	_, err := Transaction(txCtx, func(ctx context.Context) (interface{}, error) {
+		//事务执行中可以查询到,记录开启事务的调用栈和截止时间
+		//It can be queried during the transaction, and the call stack and deadline of opening the transaction are recorded
+		infos := OpenTransactions()
+		if len(infos) != 1 {
+			t.Fatalf("OpenTransactions() = %+v, want 1", infos)
+		}
+		info := infos[0]
+		txID = info.ID
+		if info.Driver != fakeDriverName || !info.Deadline.Equal(deadline) || !strings.Contains(info.Stack, "TestTxMonitor") {
+			t.Errorf("info = %+v", info)
+		}
+		//长事务只报告一次
+		//Long transactions are only reported once
+		checkLongTransactions(time.Hour)
+		if len(reported) != 0 {
+			t.Errorf("reported = %+v, want none under the threshold", reported)
+		}
+		checkLongTransactions(0)
+		checkLongTransactions(0)
+		if len(reported) != 1 || reported[0].ID != txID {
+			t.Errorf("reported = %+v, want transaction %d once", reported, txID)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//事务结束后移除
+	//Removed after the transaction ends
+	if infos := OpenTransactions(); len(infos) != 0 {
+		t.Errorf("OpenTransactions() = %+v, want empty after commit", infos)
+	}
+}
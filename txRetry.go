@@ -0,0 +1,116 @@
+package grm
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// TxRetryPolicy 事务遇到死锁或者序列化失败时的重试策略,只有grm开启的事务(事务的开启方)才会回滚后重新执行
+// 重试会重新执行整个事务函数,事务函数内不要有不能重复执行的操作,例如调用外部接口
+// TxRetryPolicy The retry policy when the transaction encounters deadlock or serialization failure,
+// only the transaction opened by grm (the opener of the transaction) will be rolled back and re-executed
+// The retry will re-execute the entire transaction function, do not have operations that cannot be repeated in the function, such as calling external interfaces
+type TxRetryPolicy struct {
+	//MaxAttempts 最多执行的次数,包含第一次执行,小于2不重试
+	//MaxAttempts The maximum number of executions, including the first execution, less than 2 does not retry
+	MaxAttempts int
+	//Backoff 第一次重试前等待的时间,之后每次重试翻倍
+	//Backoff The time to wait before the first retry, doubled for each subsequent retry
+	Backoff time.Duration
+	//MaxBackoff 等待时间的上限,0不限制
+	//MaxBackoff The upper limit of the waiting time, 0 is not limited
+	MaxBackoff time.Duration
+	//Jitter 在等待时间上增加[0,Jitter)的随机时间,避免并发的事务同时重试再次死锁
+	//Jitter Add a random time of [0, Jitter) to the waiting time to avoid concurrent transactions retrying at the same time and deadlocking again
+	Jitter time.Duration
+}
+
+// backoff 计算第attempt次执行失败后的等待时间
+// backoff Calculate the waiting time after the attempt execution fails
+func (policy *TxRetryPolicy) backoff(attempt int) time.Duration {
+	d := policy.Backoff
+	//限制翻倍的次数,避免溢出
+	//Limit the number of doubling to avoid overflow
+	for i := 1; i < attempt && i < 30; i++ {
+		if policy.MaxBackoff > 0 && d >= policy.MaxBackoff {
+			break
+		}
+		d = d * 2
+	}
+	if policy.MaxBackoff > 0 && d > policy.MaxBackoff {
+		d = policy.MaxBackoff
+	}
+	if policy.Jitter > 0 {
+		d = d + time.Duration(rand.Int63n(int64(policy.Jitter)))
+	}
+	return d
+}
+
+// wait 等待重试,ctx结束时返回ctx的错误
+// wait Wait for retry, return the error of ctx when ctx is done
+func (policy *TxRetryPolicy) wait(ctx context.Context, attempt int) error {
+	d := policy.backoff(attempt)
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// FuncTxRetryable 判断事务的错误是否可以重试,默认识别死锁和序列化失败,用于外部复写实现自定义的逻辑,drv是数据库类型
+// FuncTxRetryable Determine whether the error of the transaction can be retried, deadlock and serialization failure are recognized by default,
+// used for external replication to implement custom logic, drv is the database type
+var FuncTxRetryable func(drv string, err error) bool = isTxRetryable
+
+// txRetryableMessages 死锁和序列化失败的错误信息,grm的错误是字符串拼接的,只能根据错误信息判断
+// MySQL 1213, PostgreSQL 40001/40P01, MSSQL 1205, Oracle ORA-00060/ORA-08177
+// txRetryableMessages Error messages for deadlock and serialization failure, grm errors are concatenated strings, can only be judged based on the message
+var txRetryableMessages = []string{
+	//mysql
+	"Error 1213",
+	"Deadlock found when trying to get lock",
+	//postgresql,只匹配带SQLSTATE标识的错误码,避免匹配到错误信息里的id或者值
+	//postgresql, only match the error code with the SQLSTATE mark, to avoid matching the id or value in the error message
+	"SQLSTATE 40001",
+	"SQLSTATE 40P01",
+	"(40001)",
+	"(40P01)",
+	"could not serialize access",
+	"deadlock detected",
+	//mssql
+	"deadlocked on lock",
+	//oracle
+	"ORA-00060",
+	"ORA-08177",
+}
+
+// isTxRetryable 默认的判断事务错误是否可以重试的实现
+// isTxRetryable The default implementation to determine whether the transaction error can be retried
+func isTxRetryable(drv string, err error) bool {
+	if err == nil {
+		return false
+	}
+	//pgx等驱动的错误实现了SQLState方法
+	//The errors of drivers such as pgx implement the SQLState method
+	if stateErr, ok := err.(interface{ SQLState() string }); ok {
+		state := stateErr.SQLState()
+		if state == "40001" || state == "40P01" {
+			return true
+		}
+	}
+	msg := err.Error()
+	for _, m := range txRetryableMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package grm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// sqlStateError 实现SQLState方法的错误,例如pgx的错误
+// sqlStateError The error that implements the SQLState method, such as the error of pgx
+type sqlStateError string
+
+func (e sqlStateError) Error() string {
+	return "sql state error"
+}
+
+func (e sqlStateError) SQLState() string {
+	return string(e)
+}
+
+func TestIsTxRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"mysql deadlock", errors.New("Error 1213: Deadlock found when trying to get lock; try restarting transaction"), true},
+		{"postgresql serialization", errors.New("ERROR: could not serialize access due to concurrent update (SQLSTATE 40001)"), true},
+		{"postgresql deadlock", errors.New("pq: deadlock detected (40P01)"), true},
+		{"mssql deadlock", errors.New("Transaction (Process ID 52) was deadlocked on lock resources"), true},
+		{"oracle deadlock", errors.New("ORA-00060: deadlock detected while waiting for resource"), true},
+		{"sql state", sqlStateError("40001"), true},
+		{"other sql state", sqlStateError("23505"), false},
+		{"bare code in value", errors.New("Duplicate entry '40001' for key 'PRIMARY'"), false},
+		{"bare deadlock code in id", errors.New("order 40P01 not found"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTxRetryable("mysql", tt.err); got != tt.want {
+				t.Errorf("isTxRetryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransactionRetry(t *testing.T) {
+	ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+	failures := 1
+	db.execErr = func(query string) error {
+		if strings.HasPrefix(query, "UPDATE") && failures > 0 {
+			failures--
+			return errors.New("Error 1213: Deadlock found when trying to get lock")
+		}
+		return nil
+	}
+	calls := 0
+	_, err := TransactionWithOptions(ctx, &TransactionOption{Retry: &TxRetryPolicy{MaxAttempts: 3}}, func(ctx context.Context) (interface{}, error) {
+		calls++
+		finder := NewUpdateFinder("t_demo").Append("name=? WHERE id=?", "grm", 1)
+		return UpdateFinder(ctx, finder)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2", calls)
+	}
+	update := "UPDATE t_demo SET  name=? WHERE id=?"
+	assertStatements(t, db.take(), []string{"BEGIN", update, "ROLLBACK", "BEGIN", update, "COMMIT"})
+}
+
+func TestTransactionRetryNested(t *testing.T) {
+	ctx, _, db := newFakeDao(t, &DBConfig{Dialect: "mysql"})
+	db.execErr = func(query string) error {
+		if strings.HasPrefix(query, "UPDATE") {
+			return errors.New("Error 1213: Deadlock found when trying to get lock")
+		}
+		return nil
+	}
+	calls := 0
+	var updateErr error
+	_, err := Transaction(ctx, func(ctx context.Context) (interface{}, error) {
+		//只有事务的开启方才会重试,嵌套事务回滚到savepoint,不重试
+		//Only the opener of the transaction retries, the nested transaction rolls back to the savepoint without retrying
+		TransactionWithOptions(ctx, &TransactionOption{Retry: &TxRetryPolicy{MaxAttempts: 3}}, func(ctx context.Context) (interface{}, error) {
+			calls++
+			finder := NewUpdateFinder("t_demo").Append("name=? WHERE id=?", "grm", 1)
+			var affected int
+			affected, updateErr = UpdateFinder(ctx, finder)
+			return affected, updateErr
+		})
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updateErr == nil {
+		t.Fatal("死锁的更新返回了nil")
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+	assertStatements(t, db.take(), []string{"BEGIN", "SAVEPOINT grm_sp_1", "UPDATE t_demo SET  name=? WHERE id=?", "ROLLBACK TO SAVEPOINT grm_sp_1", "COMMIT"})
+}
@@ -0,0 +1,293 @@
+package grm
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Upsert 保存Struct对象,conflictColumns的唯一索引冲突时更新updateColumns的字段,一条语句完成,避免先查询再保存或者更新的并发问题
+// mysql使用 ON DUPLICATE KEY UPDATE,postgresql和sqlite使用 ON CONFLICT DO UPDATE,oracle和mssql使用 MERGE
+// conflictColumns不能为空,mysql根据表的唯一索引判断冲突,conflictColumns只用于计算默认的更新字段.开启多租户时必须包含租户字段
+// updateColumns为nil时更新除冲突字段,主键,grm:"autoCreateTime" 和租户字段之外的所有字段,为空数组时冲突不更新
+// 自增主键的值不会赋值给entity.ctx不能为nil,参照使用grm.Transaction方法传入ctx.也不要自己构建DBConnection
+// affected影响的行数,和数据库的返回值一致,例如mysql插入是1,更新是2.如果异常或者驱动不支持,返回-1
+// Upsert Save the Struct object, and update the columns of updateColumns when the unique index of conflictColumns conflicts.
+// It is completed in one statement to avoid the concurrency problem of querying first and then inserting or updating
+// mysql uses ON DUPLICATE KEY UPDATE, postgresql and sqlite use ON CONFLICT DO UPDATE, oracle and mssql use MERGE
+// conflictColumns cannot be empty, mysql judges the conflict according to the unique index of the table, and conflictColumns is only used to calculate the default update columns.
+// When multi-tenancy is enabled, the tenant column must be included
+// When updateColumns is nil, all columns except the conflict columns, primary key, grm:"autoCreateTime" and tenant column are updated,
+// when it is an empty slice, no update on conflict
+// The value of the auto-increment primary key will not be assigned to entity. ctx cannot be nil, refer to grm.Transaction method to pass in ctx. Don't build DB Connection yourself
+// The number of rows affected by "affected" is consistent with the return value of the database, for example, mysql insert is 1 and update is 2.
+// If it is abnormal or the driver does not support it, return -1
+func Upsert(ctx context.Context, entity IEntityStruct, conflictColumns []string, updateColumns []string) (int, error) {
+	if err := checkWriteCtx(ctx); err != nil {
+		return -1, err
+	}
+	affected := -1
+	if entity == nil {
+		return affected, errors.New("Upsert-->对象不能为空")
+	}
+	if len(conflictColumns) < 1 {
+		return affected, errors.New("Upsert-->conflictColumns不能为空")
+	}
+	//从context中获取数据库连接,可能为nil
+	//Get database connection from context, may be nil
+	dbConn, err := getDBConn(ctx)
+	if err != nil {
+		return affected, err
+	}
+	//自己构建的dbConn
+	//dbConn built by yourself
+	if dbConn != nil && dbConn.db == nil {
+		return affected, errDBConn
+	}
+
+	var config *DBConfig
+	//dbConn为nil,使用defaultDao
+	//dbConn is nil, use default Dao
+	if dbConn == nil {
+		config = FuncReadWriteStrategy(1).config
+	} else {
+		config = dbConn.cfg
+	}
+
+	//多租户,设置租户字段的值,租户字段必须是冲突字段,避免更新其他租户的数据
+	//Multi-tenant, set the value of the tenant column, the tenant column must be a conflict column to avoid updating the data of other tenants
+	tenant, err := structTenantCondition(ctx, dbConn, entity)
+	if err != nil {
+		return affected, errors.New("Upsert-->structTenantCondition获取租户条件错误: " + err.Error())
+	}
+	if tenant != nil {
+		if !containsColumnName(conflictColumns, tenant.column) {
+			return affected, errors.New("Upsert-->多租户模式下conflictColumns必须包含租户字段" + tenant.column)
+		}
+		if err = setStructTenant(entity, tenant); err != nil {
+			return affected, errors.New("Upsert-->setStructTenant设置租户字段错误: " + err.Error())
+		}
+	}
+	//自动填充更新时间,没有值的创建时间
+	//Auto-fill update time and create time without value
+	now := autoTimeNow(getDBConfig(ctx, dbConn, 1))
+	if err = setStructAutoTime(entity, now, false); err != nil {
+		return affected, errors.New("Upsert-->setStructAutoTime自动填充时间错误: " + err.Error())
+	}
+	if err = setStructAutoTime(entity, now, true); err != nil {
+		return affected, errors.New("Upsert-->setStructAutoTime自动填充时间错误: " + err.Error())
+	}
+
+	typeOf, columns, values, err := columnAndValue(entity)
+	if err != nil {
+		return affected, errors.New("Upsert-->columnAndValue获取实体类的列和值异常: " + err.Error())
+	}
+	tableName, err := FuncTableNameResolver(ctx, entity)
+	if err != nil {
+		return affected, errors.New("Upsert-->FuncTableNameResolver获取表名错误: " + err.Error())
+	}
+	//和Insert一样处理主键,去掉自增主键,生成字符串主键
+	//Process the primary key the same as Insert, remove the auto-increment primary key and generate the string primary key
+	_, autoIncrement, _, err := wrapInsertSQLNOreBuild(config, tableName, &typeOf, entity, &columns, &values)
+	if err != nil {
+		return affected, errors.New("Upsert-->wrapInsertSQLNOreBuild获取保存语句错误: " + err.Error())
+	}
+	pkSequence := ""
+	if autoIncrement == 2 {
+		pkSequence = entity.GetPkSequence()[config.Dialect]
+	}
+	insertColumns := make([]string, len(columns))
+	for i := range columns {
+		insertColumns[i] = getFieldTagName(&columns[i])
+	}
+
+	//默认不更新主键,创建时间和租户字段
+	//The primary key, create time and tenant column are not updated by default
+	excludeColumns := entityPKColumns(entity)
+	createTimeField, hasCreateTime, err := getGrmTagField(&typeOf, tagAutoCreateTime)
+	if err != nil {
+		return affected, err
+	}
+	if hasCreateTime {
+		excludeColumns = append(excludeColumns, getFieldTagName(&createTimeField))
+	}
+	if tenant != nil {
+		excludeColumns = append(excludeColumns, tenant.column)
+	}
+	updateColumns, err = upsertUpdateColumns(insertColumns, conflictColumns, updateColumns, excludeColumns)
+	if err != nil {
+		return affected, err
+	}
+
+	sqlStr, err := wrapUpsertSQL(config, tableName, insertColumns, conflictColumns, updateColumns, entity.PK(), pkSequence)
+	if err != nil {
+		return affected, errors.New("Upsert-->wrapUpsertSQL获取SQL语句错误: " + err.Error())
+	}
+	sqlStr, err = reBindSQL(config, sqlStr)
+	if err != nil {
+		return affected, errors.New("Upsert-->reBindSQL获取SQL语句错误: " + err.Error())
+	}
+
+	//包装update执行,赋值给影响的函数指针变量,返回*sql.Result
+	_, execErr := wrapExecUpdateValuesAffected(ctx, &affected, &sqlStr, values, nil)
+	if execErr != nil {
+		return affected, errors.New("Upsert-->wrapExecUpdateValuesAffected执行错误 " + execErr.Error())
+	}
+	return affected, nil
+}
+
+// UpsertEntityMap 保存IEntityMap对象,conflictColumns的唯一索引冲突时更新updateColumns的字段,参见Upsert
+// updateColumns为nil时更新除冲突字段,主键,IEntityMapAutoTime的创建时间和租户字段之外的所有字段,为空数组时冲突不更新
+// ctx不能为nil,参照使用grm.Transaction方法传入ctx.也不要自己构建DBConnection
+// affected影响的行数,如果异常或者驱动不支持,返回-1
+// UpsertEntityMap Save the IEntityMap object, and update the columns of updateColumns when the unique index of conflictColumns conflicts, see Upsert
+// When updateColumns is nil, all columns except the conflict columns, primary key, create time of IEntityMapAutoTime and tenant column are updated,
+// when it is an empty slice, no update on conflict
+// ctx cannot be nil, refer to grm.Transaction method to pass in ctx. Don't build DB Connection yourself
+// The number of rows affected by "affected", if it is abnormal or the driver does not support it, return -1
+func UpsertEntityMap(ctx context.Context, entity IEntityMap, conflictColumns []string, updateColumns []string) (int, error) {
+	if err := checkWriteCtx(ctx); err != nil {
+		return -1, err
+	}
+	affected := -1
+	//检查是否是指针对象
+	//Check if it is a pointer
+	_, err := checkEntityKind(entity)
+	if err != nil {
+		return affected, err
+	}
+	if len(conflictColumns) < 1 {
+		return affected, errors.New("UpsertEntityMap-->conflictColumns不能为空")
+	}
+	//从context中获取数据库连接,可能为nil
+	//Get database connection from context, may be nil
+	dbConn, err := getDBConn(ctx)
+	if err != nil {
+		return affected, err
+	}
+	//自己构建的dbConn
+	//dbConn built by yourself
+	if dbConn != nil && dbConn.db == nil {
+		return affected, errDBConn
+	}
+
+	var config *DBConfig
+	//dbConn为nil,使用defaultDao
+	//dbConn is nil, use default Dao
+	if dbConn == nil {
+		config = FuncReadWriteStrategy(1).config
+	} else {
+		config = dbConn.cfg
+	}
+
+	//多租户,设置租户字段为ctx绑定的租户ID,租户字段必须是冲突字段
+	//Multi-tenant, set the tenant column to the tenant ID bound to ctx, the tenant column must be a conflict column
+	tenant, err := mapTenantCondition(ctx, dbConn, entity)
+	if err != nil {
+		return affected, errors.New("UpsertEntityMap-->mapTenantCondition获取租户条件错误: " + err.Error())
+	}
+	if tenant != nil {
+		if !containsColumnName(conflictColumns, tenant.column) {
+			return affected, errors.New("UpsertEntityMap-->多租户模式下conflictColumns必须包含租户字段" + tenant.column)
+		}
+		entity.Set(tenant.column, tenant.value)
+	}
+	//自动填充更新时间,没有Set的创建时间
+	//Auto-fill update time and create time that is not Set
+	now := autoTimeNow(getDBConfig(ctx, dbConn, 1))
+	setMapAutoTime(entity, now, false)
+	setMapAutoTime(entity, now, true)
+
+	dbFieldMap := entity.FieldMap()
+	if len(dbFieldMap) < 1 {
+		return affected, errors.New("UpsertEntityMap-->FieldMap返回值不能为空")
+	}
+	columns := make([]string, 0, len(dbFieldMap))
+	values := make([]interface{}, 0, len(dbFieldMap))
+	for k, v := range dbFieldMap {
+		columns = append(columns, k)
+		values = append(values, v)
+	}
+	//没有Set主键,使用序列生成主键
+	//The primary key is not Set, use the sequence to generate the primary key
+	pkSequence := ""
+	if _, hasPK := dbFieldMap[entity.PK()]; !hasPK {
+		pkSequence = entity.GetPkSequence()[config.Dialect]
+	}
+
+	//默认不更新主键,创建时间和租户字段
+	//The primary key, create time and tenant column are not updated by default
+	excludeColumns := []string{entity.PK()}
+	if autoTime, ok := entity.(IEntityMapAutoTime); ok {
+		createTimeColumn, _ := autoTime.AutoTimeColumns()
+		excludeColumns = append(excludeColumns, createTimeColumn)
+	}
+	if tenant != nil {
+		excludeColumns = append(excludeColumns, tenant.column)
+	}
+	updateColumns, err = upsertUpdateColumns(columns, conflictColumns, updateColumns, excludeColumns)
+	if err != nil {
+		return affected, err
+	}
+
+	tableName, err := FuncTableNameResolver(ctx, entity)
+	if err != nil {
+		return affected, errors.New("UpsertEntityMap-->FuncTableNameResolver获取表名错误: " + err.Error())
+	}
+	sqlStr, err := wrapUpsertSQL(config, tableName, columns, conflictColumns, updateColumns, entity.PK(), pkSequence)
+	if err != nil {
+		return affected, errors.New("UpsertEntityMap-->wrapUpsertSQL获取SQL语句错误: " + err.Error())
+	}
+	sqlStr, err = reBindSQL(config, sqlStr)
+	if err != nil {
+		return affected, errors.New("UpsertEntityMap-->reBindSQL获取SQL语句错误: " + err.Error())
+	}
+
+	//包装update执行,赋值给影响的函数指针变量,返回*sql.Result
+	_, execErr := wrapExecUpdateValuesAffected(ctx, &affected, &sqlStr, values, nil)
+	if execErr != nil {
+		return affected, errors.New("UpsertEntityMap-->wrapExecUpdateValuesAffected执行错误 " + execErr.Error())
+	}
+	return affected, nil
+}
+
+// upsertUpdateColumns 计算冲突时更新的字段.updateColumns为nil时是columns去掉冲突字段和excludeColumns,否则检查updateColumns都是保存的字段,并去掉冲突字段
+// upsertUpdateColumns Calculate the columns updated when there is a conflict. When updateColumns is nil, it is columns minus conflict columns and excludeColumns,
+// otherwise check that updateColumns are all inserted columns and remove the conflict columns
+func upsertUpdateColumns(columns []string, conflictColumns []string, updateColumns []string, excludeColumns []string) ([]string, error) {
+	for _, column := range conflictColumns {
+		if !containsColumnName(columns, column) {
+			return nil, errors.New("upsertUpdateColumns-->冲突字段" + column + "没有值")
+		}
+	}
+	if updateColumns == nil {
+		result := make([]string, 0, len(columns))
+		for _, column := range columns {
+			if !containsColumnName(conflictColumns, column) && !containsColumnName(excludeColumns, column) {
+				result = append(result, column)
+			}
+		}
+		return result, nil
+	}
+	result := make([]string, 0, len(updateColumns))
+	for _, column := range updateColumns {
+		if !containsColumnName(columns, column) {
+			return nil, errors.New("upsertUpdateColumns-->更新字段" + column + "没有值")
+		}
+		if !containsColumnName(conflictColumns, column) {
+			result = append(result, column)
+		}
+	}
+	return result, nil
+}
+
+// containsColumnName 字段名数组是否包含column,不区分大小写
+// containsColumnName Whether the column name slice contains column, case insensitive
+func containsColumnName(columns []string, column string) bool {
+	for _, c := range columns {
+		if strings.EqualFold(c, column) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,435 @@
+package grm
+
+import (
+	"bufio"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// XALog XA分布式事务的恢复日志,记录已经决定提交的全局事务,用于Recover处理悬挂的分支事务
+// 没有提交记录的悬挂分支事务按照回滚处理(推定回滚)
+// XALog The recovery log of XA distributed transactions, records the global transactions that have been decided to commit,
+// used by Recover to process in-doubt branch transactions. In-doubt branches without a commit record are rolled back (presumed abort)
+type XALog interface {
+	// Commit 记录全局事务gid决定提交,必须持久化之后再返回
+	// Commit Record that the global transaction gid is decided to commit, it must be durable before returning
+	Commit(gid string) error
+	// Done 记录全局事务gid的所有分支事务已经提交
+	// Done Record that all branch transactions of the global transaction gid have been committed
+	Done(gid string) error
+	// Pending 返回已经决定提交,但是分支事务还没有全部提交的全局事务gid
+	// Pending Return the global transaction gids that have been decided to commit, but the branch transactions have not all been committed
+	Pending() (map[string]bool, error)
+}
+
+// fileXALog 基于本地文件的XALog,每行一条记录,"C gid"代表决定提交,"D gid"代表已经完成
+// fileXALog XALog based on local file, one record per line, "C gid" means decided to commit, "D gid" means completed
+type fileXALog struct {
+	lock    sync.Mutex
+	file    *os.File
+	pending map[string]bool
+}
+
+// NewFileXALog 创建基于本地文件的XALog,文件不存在会自动创建.多个应用实例不能使用同一个文件
+// NewFileXALog Create XALog based on local file, the file will be created automatically if it does not exist. Multiple application instances cannot use the same file
+func NewFileXALog(path string) (XALog, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, errors.New("NewFileXALog-->打开日志文件失败: " + err.Error())
+	}
+	pending := make(map[string]bool)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) < 3 {
+			continue
+		}
+		if line[0] == 'C' {
+			pending[line[2:]] = true
+		} else if line[0] == 'D' {
+			delete(pending, line[2:])
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		file.Close()
+		return nil, errors.New("NewFileXALog-->读取日志文件失败: " + err.Error())
+	}
+	return &fileXALog{file: file, pending: pending}, nil
+}
+
+// Commit 写入提交记录并同步到磁盘
+// Commit Write the commit record and sync to disk
+func (xaLog *fileXALog) Commit(gid string) error {
+	xaLog.lock.Lock()
+	defer xaLog.lock.Unlock()
+	if err := xaLog.write("C " + gid + "\n"); err != nil {
+		return err
+	}
+	xaLog.pending[gid] = true
+	return nil
+}
+
+// Done 写入完成记录,没有未完成的全局事务时清空日志文件
+// Done Write the completion record, and clear the log file when there are no unfinished global transactions
+func (xaLog *fileXALog) Done(gid string) error {
+	xaLog.lock.Lock()
+	defer xaLog.lock.Unlock()
+	delete(xaLog.pending, gid)
+	if len(xaLog.pending) < 1 {
+		if err := xaLog.file.Truncate(0); err != nil {
+			return errors.New("fileXALog-->Done清空日志文件失败: " + err.Error())
+		}
+		return nil
+	}
+	return xaLog.write("D " + gid + "\n")
+}
+
+// Pending 返回未完成的全局事务gid
+// Pending Return the unfinished global transaction gids
+func (xaLog *fileXALog) Pending() (map[string]bool, error) {
+	xaLog.lock.Lock()
+	defer xaLog.lock.Unlock()
+	pending := make(map[string]bool, len(xaLog.pending))
+	for gid := range xaLog.pending {
+		pending[gid] = true
+	}
+	return pending, nil
+}
+
+// write 追加一行记录并同步到磁盘
+// write Append a line of record and sync to disk
+func (xaLog *fileXALog) write(line string) error {
+	if _, err := xaLog.file.WriteString(line); err != nil {
+		return errors.New("fileXALog-->write写入日志文件失败: " + err.Error())
+	}
+	if err := xaLog.file.Sync(); err != nil {
+		return errors.New("fileXALog-->write同步日志文件失败: " + err.Error())
+	}
+	return nil
+}
+
+// xidRegexp xid只能包含字母,数字,下划线和中划线,避免拼接SQL的注入
+// xidRegexp xid can only contain letters, numbers, underscores and dashes, to avoid injection when splicing SQL
+var xidRegexp = regexp.MustCompile("^[0-9A-Za-z_\\-]+$")
+
+// XACoordinator XA分布式事务的协调者,把多个DBDao的数据库加入到一个全局事务,两阶段提交
+// 支持mysql(XA START/END/PREPARE/COMMIT)和postgresql(PREPARE TRANSACTION/COMMIT PREPARED),postgresql需要设置max_prepared_transactions
+// kingbase和opengauss使用postgresql的语法,其他数据库的方言实现XADialect后也可以使用
+// XACoordinator The coordinator of XA distributed transactions, enlists the databases of multiple DBDao into a global transaction, two-phase commit
+// Support mysql (XA START/END/PREPARE/COMMIT) and postgresql (PREPARE TRANSACTION/COMMIT PREPARED), postgresql needs to set max_prepared_transactions
+// kingbase and opengauss use the syntax of postgresql, other databases can also be used after the dialect implements XADialect
+type XACoordinator struct {
+	// xid的前缀,Recover只处理本协调者的xid
+	// The prefix of xid, Recover only processes the xid of this coordinator
+	prefix string
+	xaLog  XALog
+}
+
+// NewXACoordinator 创建XA分布式事务的协调者.name用于区分不同的应用实例,不能为空,只能包含字母和数字,最长16位
+// 不同的应用实例必须使用不同的name,否则Recover会处理其他应用实例的分支事务
+// NewXACoordinator Create the coordinator of XA distributed transactions. name is used to distinguish different application instances,
+// it cannot be empty and can only contain letters and numbers, up to 16 characters.
+// Different application instances must use different names, otherwise Recover will process the branch transactions of other application instances
+func NewXACoordinator(name string, xaLog XALog) (*XACoordinator, error) {
+	if xaLog == nil {
+		return nil, errors.New("NewXACoordinator-->xaLog不能为nil")
+	}
+	if name == "" || len(name) > 16 || !xidRegexp.MatchString(name) || strings.ContainsAny(name, "_-") {
+		return nil, errors.New("NewXACoordinator-->name不能为空,只能包含字母和数字,最长16位")
+	}
+	return &XACoordinator{prefix: "grm_" + name + "_", xaLog: xaLog}, nil
+}
+
+/*
+XACoordinator.Transaction 的示例代码
+  coordinator.Transaction(ctx, []*grm.DBDao{orderDao, stockDao}, func(ctxs []context.Context) (interface{}, error) {
+	  //ctxs[0]是orderDao的分支事务,ctxs[1]是stockDao的分支事务
+	  _, err := grm.Insert(ctxs[0], &order)
+	  if err != nil {
+		  return nil, err
+	  }
+	  _, err = grm.UpdateFinder(ctxs[1], finder)
+	  //return的error如果不为nil,全局事务就会回滚
+	  return nil, err
+  })
+*/
+
+// Transaction 开启全局事务,daos的每个数据库开启一个XA分支事务,ctxs和daos的顺序一致,分支ctx内的grm.Transaction会加入分支事务
+// doTransaction返回的error不为nil或者出现异常,回滚所有分支事务.否则先prepare所有分支事务,记录提交日志,再提交所有分支事务
+// 如果记录提交日志之后有分支事务提交失败,返回错误,需要调用Recover完成提交
+// OnCommit和OnRollback注册的函数在分支事务结束后执行
+// Transaction Start a global transaction, each database of daos starts an XA branch transaction, ctxs are in the same order as daos,
+// grm.Transaction in the branch ctx joins the branch transaction.
+// If the error returned by doTransaction is not nil or panic, roll back all branch transactions. Otherwise, prepare all branch transactions first,
+// record the commit log, and then commit all branch transactions.
+// If a branch transaction fails to commit after the commit log is recorded, an error is returned, and Recover needs to be called to complete the commit
+// The functions registered by OnCommit and OnRollback are executed after the branch transaction ends
+func (coordinator *XACoordinator) Transaction(ctx context.Context, daos []*DBDao, doTransaction func(ctxs []context.Context) (interface{}, error)) (info interface{}, err error) {
+	if ctx == nil || len(daos) < 1 || doTransaction == nil {
+		return nil, errors.New("XACoordinator.Transaction-->ctx,daos和doTransaction不能为空")
+	}
+	gid := coordinator.prefix + FuncGenerateStringID()
+	if !xidRegexp.MatchString(gid) {
+		return nil, errors.New("XACoordinator.Transaction-->FuncGenerateStringID生成的ID不能作为xid:" + gid)
+	}
+
+	branches := make([]*xaBranch, 0, len(daos))
+	ctxs := make([]context.Context, len(daos))
+	for i, dao := range daos {
+		dbConn, connErr := dao.newDBConn()
+		if connErr != nil {
+			rollbackXABranches(ctx, branches)
+			return nil, connErr
+		}
+		conn, connErr := dao.dataSource.Conn(ctx)
+		if connErr != nil {
+			rollbackXABranches(ctx, branches)
+			return nil, errors.New("XACoordinator.Transaction-->获取数据库连接失败: " + connErr.Error())
+		}
+		dbConn.xaConn = conn
+		dbConn.xid = gid + "_" + strconv.Itoa(i)
+		branch := &xaBranch{dbConn: dbConn, state: -1}
+		branches = append(branches, branch)
+		if startErr := branch.exec(ctx, xaStart); startErr != nil {
+			rollbackXABranches(ctx, branches)
+			return nil, errors.New("XACoordinator.Transaction-->开启分支事务失败: " + startErr.Error())
+		}
+		branch.state = xaStart
+		registerTx(ctx, dbConn)
+		ctxs[i] = context.WithValue(ctx, ctxConnKey, dbConn)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			LogErr(fmt.Sprintf("XACoordinator.Transaction-->recover error: %v", r))
+			rollbackXABranches(ctx, branches)
+			err = fmt.Errorf("XACoordinator.Transaction-->doTransaction业务执行异常: %v", r)
+		}
+	}()
+
+	//执行业务的事务函数
+	info, err = doTransaction(ctxs)
+	if err != nil {
+		rollbackXABranches(ctx, branches)
+		return info, errors.New("XACoordinator.Transaction-->doTransaction业务执行异常: " + err.Error())
+	}
+
+	//第一阶段,prepare所有分支事务
+	//The first phase, prepare all branch transactions
+	for _, branch := range branches {
+		if prepareErr := branch.prepare(ctx); prepareErr != nil {
+			rollbackXABranches(ctx, branches)
+			return info, errors.New("XACoordinator.Transaction-->分支事务prepare失败: " + prepareErr.Error())
+		}
+	}
+	//记录提交的决定,之后的分支事务只能提交
+	//Record the commit decision, the subsequent branch transactions can only be committed
+	if logErr := coordinator.xaLog.Commit(gid); logErr != nil {
+		rollbackXABranches(ctx, branches)
+		return info, errors.New("XACoordinator.Transaction-->记录提交日志失败: " + logErr.Error())
+	}
+
+	//第二阶段,提交所有分支事务,某个分支提交失败也继续提交其他分支
+	//The second phase, commit all branch transactions, continue to commit other branches even if a branch fails to commit
+	var commitErr error
+	for _, branch := range branches {
+		if branchErr := branch.commit(ctx); branchErr != nil {
+			commitErr = branchErr
+		}
+	}
+	if commitErr != nil {
+		return info, errors.New("XACoordinator.Transaction-->分支事务提交失败,需要调用Recover完成提交: " + commitErr.Error())
+	}
+	if doneErr := coordinator.xaLog.Done(gid); doneErr != nil {
+		LogErr("XACoordinator.Transaction-->记录完成日志失败: " + doneErr.Error())
+	}
+	return info, nil
+}
+
+// Recover 处理悬挂(已prepare未提交)的XA分支事务,一般在应用启动时调用,daos必须包含所有参与全局事务的数据库
+// 日志中决定提交的全局事务提交分支事务,其他悬挂的分支事务回滚.只处理本协调者name前缀的xid
+// 必须在本协调者开启新的全局事务之前调用,否则可能回滚正在prepare的分支事务
+// Recover Process in-doubt (prepared but not committed) XA branch transactions, generally called when the application starts,
+// daos must contain all databases participating in the global transaction.
+// The global transactions decided to commit in the log commit the branch transactions, and other in-doubt branch transactions are rolled back.
+// Only the xid with the name prefix of this coordinator is processed.
+// It must be called before this coordinator starts a new global transaction, otherwise the branch transaction being prepared may be rolled back
+func (coordinator *XACoordinator) Recover(ctx context.Context, daos []*DBDao) error {
+	pending, err := coordinator.xaLog.Pending()
+	if err != nil {
+		return errors.New("XACoordinator.Recover-->读取提交日志失败: " + err.Error())
+	}
+	//处理失败的全局事务,不能记录为完成
+	//Global transactions that failed to process cannot be recorded as completed
+	failed := make(map[string]bool)
+	var recoverErr error
+	for _, dao := range daos {
+		dbConn, connErr := dao.newDBConn()
+		if connErr != nil {
+			return connErr
+		}
+		xids, listErr := listXIDs(ctx, dbConn)
+		if listErr != nil {
+			return errors.New("XACoordinator.Recover-->查询悬挂的分支事务失败: " + listErr.Error())
+		}
+		for _, xid := range xids {
+			index := strings.LastIndex(xid, "_")
+			if !strings.HasPrefix(xid, coordinator.prefix) || index < len(coordinator.prefix) || !xidRegexp.MatchString(xid) {
+				continue
+			}
+			gid := xid[:index]
+			xaType := xaRollback
+			if pending[gid] {
+				xaType = xaCommit
+			}
+			xaSQL, sqlErr := wrapXASQL(dbConn.cfg, xaType, xid)
+			if sqlErr == nil {
+				_, sqlErr = dbConn.execCtx(ctx, &xaSQL, nil)
+			}
+			if sqlErr != nil {
+				failed[gid] = true
+				recoverErr = errors.New("XACoordinator.Recover-->处理悬挂的分支事务" + xid + "失败: " + sqlErr.Error())
+			}
+		}
+	}
+	for gid := range pending {
+		if failed[gid] {
+			continue
+		}
+		if doneErr := coordinator.xaLog.Done(gid); doneErr != nil {
+			recoverErr = doneErr
+		}
+	}
+	return recoverErr
+}
+
+// listXIDs 查询数据库中悬挂的分支事务的xid
+// listXIDs Query the xids of the in-doubt branch transactions in the database
+func listXIDs(ctx context.Context, dbConn *dbConnection) ([]string, error) {
+	xaDialect, err := getXADialect(dbConn.cfg)
+	if err != nil {
+		return nil, err
+	}
+	recoverSQL := xaDialect.XARecoverSQL()
+	rows, err := dbConn.queryCtx(ctx, &recoverSQL, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	xids := make([]string, 0)
+	for rows.Next() {
+		xid, scanErr := xaDialect.ScanXID(rows)
+		if scanErr != nil {
+			return nil, scanErr
+		}
+		xids = append(xids, xid)
+	}
+	return xids, rows.Err()
+}
+
+// xaBranch XA分支事务
+// xaBranch XA branch transaction
+type xaBranch struct {
+	dbConn *dbConnection
+	// 分支事务的状态,xaStart,xaEnd,xaPrepare,-1代表还没有开启
+	// The state of the branch transaction, xaStart,xaEnd,xaPrepare, -1 means it has not been started
+	state int
+}
+
+// exec 执行分支事务的语句
+// exec Execute the statement of the branch transaction
+func (branch *xaBranch) exec(ctx context.Context, xaType int) error {
+	xaSQL, err := wrapXASQL(branch.dbConn.cfg, xaType, branch.dbConn.xid)
+	if err != nil || xaSQL == "" {
+		return err
+	}
+	_, err = branch.dbConn.execCtx(ctx, &xaSQL, nil)
+	return err
+}
+
+// prepare 结束并prepare分支事务
+// prepare End and prepare the branch transaction
+func (branch *xaBranch) prepare(ctx context.Context) error {
+	if err := branch.exec(ctx, xaEnd); err != nil {
+		return err
+	}
+	branch.state = xaEnd
+	if err := branch.exec(ctx, xaPrepare); err != nil {
+		return err
+	}
+	branch.state = xaPrepare
+	return nil
+}
+
+// commit 提交已经prepare的分支事务.提交失败时分支事务处于悬挂状态,由Recover完成提交,不执行注册的函数
+// commit Commit the prepared branch transaction. When the commit fails, the branch transaction is in doubt,
+// Recover completes the commit, and the registered functions are not executed
+func (branch *xaBranch) commit(ctx context.Context) error {
+	dbConn := branch.dbConn
+	if err := branch.exec(ctx, xaCommit); err != nil {
+		branch.release(false)
+		dbConn.commitFuncs = nil
+		dbConn.rollbackFuncs = nil
+		return err
+	}
+	branch.release(true)
+	dbConn.afterCommit(ctx)
+	return nil
+}
+
+// rollback 根据分支事务的状态回滚,释放连接,执行OnRollback注册的函数
+// rollback Roll back according to the state of the branch transaction, release the connection, and execute the functions registered by OnRollback
+func (branch *xaBranch) rollback(ctx context.Context) {
+	if branch.state == xaStart {
+		if err := branch.exec(ctx, xaEnd); err != nil {
+			LogErr("xaBranch-->rollback结束分支事务失败: " + err.Error())
+		}
+		branch.state = xaEnd
+	}
+	var err error
+	if branch.state == xaEnd {
+		err = branch.exec(ctx, xaRollbackActive)
+	} else if branch.state == xaPrepare {
+		err = branch.exec(ctx, xaRollback)
+	}
+	if err != nil {
+		LogErr("xaBranch-->rollback回滚分支事务" + branch.dbConn.xid + "失败: " + err.Error())
+	}
+	branch.release(err == nil)
+	branch.dbConn.afterRollback(ctx)
+}
+
+// release 分支事务结束,释放连接.clean为false时,连接可能还处于XA状态,从连接池中丢弃
+// release The branch transaction ends and the connection is released. When clean is false,
+// the connection may still be in XA state and is discarded from the connection pool
+func (branch *xaBranch) release(clean bool) {
+	dbConn := branch.dbConn
+	if !clean {
+		dbConn.xaConn.Raw(func(driverConn interface{}) error {
+			return driver.ErrBadConn
+		})
+	}
+	dbConn.xaConn.Close()
+	dbConn.xaConn = nil
+	dbConn.xid = ""
+	unregisterTx(dbConn)
+	branch.state = -1
+}
+
+// rollbackXABranches 回滚所有分支事务,释放连接
+// rollbackXABranches Roll back all branch transactions and release the connections
+func rollbackXABranches(ctx context.Context, branches []*xaBranch) {
+	for _, branch := range branches {
+		if branch.dbConn.xaConn == nil {
+			continue
+		}
+		branch.rollback(ctx)
+	}
+}
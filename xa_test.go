@@ -0,0 +1,147 @@
+package grm
+
+import (
+	"context"
+	"database/sql/driver"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// tempXALogPath 返回临时目录中的日志文件路径,测试结束删除临时目录
+// tempXALogPath Return the log file path in the temporary directory, the temporary directory is deleted after the test
+func tempXALogPath(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "grmxa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dir)
+	})
+	return filepath.Join(dir, "xa.log")
+}
+
+func TestFileXALog(t *testing.T) {
+	path := tempXALogPath(t)
+	xaLog, err := NewFileXALog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = xaLog.Commit("g1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = xaLog.Commit("g2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = xaLog.Done("g1"); err != nil {
+		t.Fatal(err)
+	}
+	xaLog.(*fileXALog).file.Close()
+
+	//重新打开日志文件,恢复未完成的全局事务
+	//Reopen the log file to restore the unfinished global transactions
+	xaLog, err = NewFileXALog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending, err := xaLog.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pending, map[string]bool{"g2": true}) {
+		t.Errorf("pending = %v, want [g2]", pending)
+	}
+	//全部完成后清空日志文件
+	//The log file is cleared after all are completed
+	if err = xaLog.Done("g2"); err != nil {
+		t.Fatal(err)
+	}
+	xaLog.(*fileXALog).file.Close()
+	xaLog, err = NewFileXALog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer xaLog.(*fileXALog).file.Close()
+	if pending, _ = xaLog.Pending(); len(pending) != 0 {
+		t.Errorf("pending = %v, want empty", pending)
+	}
+}
+
+func TestNewXACoordinator(t *testing.T) {
+	xaLog, err := NewFileXALog(tempXALogPath(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer xaLog.(*fileXALog).file.Close()
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"order1", false},
+		{"", true},
+		{"order_1", true},
+		{"order-1", true},
+		{"abcdefghijklmnopq", true},
+	}
+	for _, tt := range tests {
+		coordinator, err := NewXACoordinator(tt.name, xaLog)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewXACoordinator(%q) err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if err == nil && coordinator.prefix != "grm_"+tt.name+"_" {
+			t.Errorf("prefix = %q", coordinator.prefix)
+		}
+	}
+	if _, err = NewXACoordinator("order1", nil); err == nil {
+		t.Error("NewXACoordinator没有检查xaLog")
+	}
+}
+
+func TestXARecover(t *testing.T) {
+	_, dao, db := newFakeDao(t, &DBConfig{Dialect: "postgresql"})
+	recoverSQL := PostgreSQLDialect{}.XARecoverSQL()
+	db.queryRows = func(query string) ([]string, [][]driver.Value) {
+		if query != recoverSQL {
+			return nil, nil
+		}
+		return []string{"gid"}, [][]driver.Value{
+			{"grm_order1_g1_0"},
+			{"grm_order1_g2_0"},
+			{"grm_order1_g2_1"},
+			{"grm_stock1_g3_0"},
+		}
+	}
+	xaLog, err := NewFileXALog(tempXALogPath(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer xaLog.(*fileXALog).file.Close()
+	//g1已经决定提交,g2没有提交记录
+	//g1 has been decided to commit, g2 has no commit record
+	if err = xaLog.Commit("grm_order1_g1"); err != nil {
+		t.Fatal(err)
+	}
+	coordinator, err := NewXACoordinator("order1", xaLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = coordinator.Recover(context.Background(), []*DBDao{dao}); err != nil {
+		t.Fatal(err)
+	}
+	//提交日志中的分支事务,回滚其他悬挂的分支事务,忽略其他协调者的xid
+	//Commit the branch transactions in the log, roll back other in-doubt branch transactions, and ignore the xids of other coordinators
+	statements := db.take()
+	sort.Strings(statements[1:])
+	assertStatements(t, statements, []string{
+		recoverSQL,
+		"COMMIT PREPARED 'grm_order1_g1_0'",
+		"ROLLBACK PREPARED 'grm_order1_g2_0'",
+		"ROLLBACK PREPARED 'grm_order1_g2_1'",
+	})
+	if pending, _ := xaLog.Pending(); len(pending) != 0 {
+		t.Errorf("pending = %v, want empty", pending)
+	}
+}